@@ -0,0 +1,340 @@
+package acl
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+	"github.com/mingforpc/fuse-go/fuse/perm"
+)
+
+/**
+ * The names of the xattrs which store the ACLs
+ *
+ * XattrAccess: the access ACL of a file
+ * XattrDefault: the default ACL of a directory, inherited by new files
+ */
+const (
+	XattrAccess  = "system.posix_acl_access"
+	XattrDefault = "system.posix_acl_default"
+)
+
+// Version : the version of the xattr format
+const Version = 2
+
+/* The tags of entry */
+const (
+	TagUserObj  = 0x01
+	TagUser     = 0x02
+	TagGroupObj = 0x04
+	TagGroup    = 0x08
+	TagMask     = 0x10
+	TagOther    = 0x20
+)
+
+/* The permissions of entry */
+const (
+	PermExecute = 0x01
+	PermWrite   = 0x02
+	PermRead    = 0x04
+)
+
+// UndefinedID : the ID of the entries other than TagUser and TagGroup
+const UndefinedID = 0xffffffff
+
+const (
+	headerSize = 4
+	entrySize  = 8
+)
+
+var (
+	// ErrVersion : the xattr is not Version
+	ErrVersion = errors.New("acl: unsupported version")
+	// ErrSize : the size of xattr is not a header and entries
+	ErrSize = errors.New("acl: invalid size")
+	// ErrInvalid : the entries are not a valid ACL
+	ErrInvalid = errors.New("acl: invalid entries")
+)
+
+// Entry : an entry of ACL
+type Entry struct {
+	Tag  uint16
+	Perm uint16
+	ID   uint32
+}
+
+// ACL : a POSIX ACL, a list of entries
+type ACL []Entry
+
+// Decode : decode the value of XattrAccess or XattrDefault
+func Decode(value []byte) (ACL, error) {
+
+	if len(value) < headerSize || (len(value)-headerSize)%entrySize != 0 {
+		return nil, ErrSize
+	}
+
+	if binary.LittleEndian.Uint32(value) != Version {
+		return nil, ErrVersion
+	}
+
+	acl := make(ACL, 0, (len(value)-headerSize)/entrySize)
+	for off := headerSize; off < len(value); off += entrySize {
+		acl = append(acl, Entry{
+			Tag:  binary.LittleEndian.Uint16(value[off:]),
+			Perm: binary.LittleEndian.Uint16(value[off+2:]),
+			ID:   binary.LittleEndian.Uint32(value[off+4:]),
+		})
+	}
+
+	return acl, nil
+}
+
+// Encode : encode to the value of XattrAccess or XattrDefault,
+// the entries are sorted as the kernel requires
+func (acl ACL) Encode() []byte {
+
+	sorted := make(ACL, len(acl))
+	copy(sorted, acl)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Tag != sorted[j].Tag {
+			return sorted[i].Tag < sorted[j].Tag
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	value := make([]byte, headerSize+len(sorted)*entrySize)
+	binary.LittleEndian.PutUint32(value, Version)
+
+	off := headerSize
+	for _, entry := range sorted {
+		binary.LittleEndian.PutUint16(value[off:], entry.Tag)
+		binary.LittleEndian.PutUint16(value[off+2:], entry.Perm)
+		binary.LittleEndian.PutUint32(value[off+4:], entry.ID)
+		off += entrySize
+	}
+
+	return value
+}
+
+// Valid : check the ACL has one TagUserObj, TagGroupObj and TagOther,
+// a TagMask if it has named entries, and no duplicated IDs
+func (acl ACL) Valid() error {
+
+	count := make(map[uint16]int)
+	ids := make(map[Entry]bool)
+
+	for _, entry := range acl {
+		switch entry.Tag {
+		case TagUserObj, TagGroupObj, TagMask, TagOther:
+			count[entry.Tag]++
+		case TagUser, TagGroup:
+			key := Entry{Tag: entry.Tag, ID: entry.ID}
+			if ids[key] {
+				return ErrInvalid
+			}
+			ids[key] = true
+			count[entry.Tag]++
+		default:
+			return ErrInvalid
+		}
+		if (entry.Perm &^ (PermRead | PermWrite | PermExecute)) != 0 {
+			return ErrInvalid
+		}
+	}
+
+	if count[TagUserObj] != 1 || count[TagGroupObj] != 1 || count[TagOther] != 1 || count[TagMask] > 1 {
+		return ErrInvalid
+	}
+	if count[TagUser]+count[TagGroup] > 0 && count[TagMask] == 0 {
+		return ErrInvalid
+	}
+
+	return nil
+}
+
+// FromMode : the minimal ACL of the permission bits of mode
+func FromMode(mode uint32) ACL {
+	return ACL{
+		{Tag: TagUserObj, Perm: uint16(mode>>6) & 07, ID: UndefinedID},
+		{Tag: TagGroupObj, Perm: uint16(mode>>3) & 07, ID: UndefinedID},
+		{Tag: TagOther, Perm: uint16(mode) & 07, ID: UndefinedID},
+	}
+}
+
+// IsMinimal : whether the ACL is the same as the permission bits,
+// then the xattr can be removed and only the mode kept
+func (acl ACL) IsMinimal() bool {
+	for _, entry := range acl {
+		if entry.Tag != TagUserObj && entry.Tag != TagGroupObj && entry.Tag != TagOther {
+			return false
+		}
+	}
+	return true
+}
+
+// find : the index of the first entry of tag, -1 if not found
+func (acl ACL) find(tag uint16) int {
+	for i, entry := range acl {
+		if entry.Tag == tag {
+			return i
+		}
+	}
+	return -1
+}
+
+// groupClass : the index of the entry of the group class bits,
+// TagMask if any, otherwise TagGroupObj
+func (acl ACL) groupClass() int {
+	if i := acl.find(TagMask); i >= 0 {
+		return i
+	}
+	return acl.find(TagGroupObj)
+}
+
+// Mode : the permission bits of the ACL, the group class is the TagMask if any
+func (acl ACL) Mode() uint32 {
+
+	var mode uint32
+	if i := acl.find(TagUserObj); i >= 0 {
+		mode |= uint32(acl[i].Perm&07) << 6
+	}
+	if i := acl.groupClass(); i >= 0 {
+		mode |= uint32(acl[i].Perm&07) << 3
+	}
+	if i := acl.find(TagOther); i >= 0 {
+		mode |= uint32(acl[i].Perm & 07)
+	}
+
+	return mode
+}
+
+// Chmod : the ACL after chmod to mode, the owner, group class and
+// other entries are set to the permission bits of mode
+func (acl ACL) Chmod(mode uint32) ACL {
+
+	res := make(ACL, len(acl))
+	copy(res, acl)
+
+	if i := res.find(TagUserObj); i >= 0 {
+		res[i].Perm = uint16(mode>>6) & 07
+	}
+	if i := res.groupClass(); i >= 0 {
+		res[i].Perm = uint16(mode>>3) & 07
+	}
+	if i := res.find(TagOther); i >= 0 {
+		res[i].Perm = uint16(mode) & 07
+	}
+
+	return res
+}
+
+// Inherit : the ACLs and mode of a new file created with mode in a
+// directory with the default ACL def (nil if none). Without def the umask
+// is applied, since the kernel does not apply it with FUSE_POSIX_ACL.
+// access is nil if it is minimal, dirDefault is the default ACL of the new
+// file, only for a directory.
+func Inherit(def ACL, mode uint32, umask uint32, isDir bool) (access ACL, dirDefault ACL, newMode uint32) {
+
+	if len(def) == 0 {
+		return nil, nil, mode &^ (umask & 0777)
+	}
+
+	access = make(ACL, len(def))
+	copy(access, def)
+
+	if i := access.find(TagUserObj); i >= 0 {
+		access[i].Perm &= uint16(mode>>6) & 07
+	}
+	if i := access.groupClass(); i >= 0 {
+		access[i].Perm &= uint16(mode>>3) & 07
+	}
+	if i := access.find(TagOther); i >= 0 {
+		access[i].Perm &= uint16(mode) & 07
+	}
+
+	newMode = (mode &^ 0777) | access.Mode()
+
+	if access.IsMinimal() {
+		access = nil
+	}
+	if isDir {
+		dirDefault = def
+	}
+
+	return access, dirDefault, newMode
+}
+
+// Check : check the access of the caller of req to the file with the
+// access ACL acl, by the algorithm of acl(5). mask is the mask of
+// Opt.Access, perm.XOK|perm.WOK|perm.ROK or perm.FOK. root and a nil ACL
+// are checked by perm.Check. Return errno.SUCCESS or errno.EACCES.
+func Check(acl ACL, fsStat *fuse.FileStat, req fuse.Req, mask uint32) int32 {
+
+	if fsStat == nil || len(acl) == 0 || req.UID == 0 {
+		return perm.Check(fsStat, req, mask)
+	}
+
+	want := uint16(mask) & (PermRead | PermWrite | PermExecute)
+	if want == 0 {
+		return errno.SUCCESS
+	}
+
+	stat := fsStat.Stat
+
+	var maskPerm uint16 = PermRead | PermWrite | PermExecute
+	if i := acl.find(TagMask); i >= 0 {
+		maskPerm = acl[i].Perm
+	}
+
+	allowed := func(p uint16) int32 {
+		if (p & want) == want {
+			return errno.SUCCESS
+		}
+		return errno.EACCES
+	}
+
+	if req.UID == stat.Uid {
+		if i := acl.find(TagUserObj); i >= 0 {
+			return allowed(acl[i].Perm)
+		}
+		return errno.EACCES
+	}
+
+	for _, entry := range acl {
+		if entry.Tag == TagUser && entry.ID == req.UID {
+			return allowed(entry.Perm & maskPerm)
+		}
+	}
+
+	// any matching group entry which grants the access is enough
+	found := false
+	for _, entry := range acl {
+		var gid uint32
+		switch entry.Tag {
+		case TagGroupObj:
+			gid = stat.Gid
+		case TagGroup:
+			gid = entry.ID
+		default:
+			continue
+		}
+		if !req.InGroup(gid) {
+			continue
+		}
+		found = true
+		if allowed(entry.Perm&maskPerm) == errno.SUCCESS {
+			return errno.SUCCESS
+		}
+	}
+	if found {
+		return errno.EACCES
+	}
+
+	if i := acl.find(TagOther); i >= 0 {
+		return allowed(acl[i].Perm)
+	}
+
+	return errno.EACCES
+}
@@ -0,0 +1,44 @@
+package cuse
+
+import (
+	"syscall"
+
+	"github.com/mingforpc/fuse-go/fuse"
+)
+
+// CuseDev : the device to create character device in userspace
+const CuseDev = "/dev/cuse"
+
+// NewCuseSession : create a session to serve the character device "/dev/{devname}".
+// Only the file operations (Open, Read, Write, Ioctl, Poll, Flush, Release, Fsync)
+// in opts make sense for a character device.
+// To set MaxRead or kernel.CuseUnrestrictedIoctl, call Session.SetCuseInfo instead.
+func NewCuseSession(devname string, major uint32, minor uint32, opts *fuse.Opt, maxGoro int) *fuse.Session {
+
+	se := fuse.NewFuseSession("", opts, maxGoro)
+
+	info := &fuse.CuseInfo{}
+	info.DevName = devname
+	info.DevMajor = major
+	info.DevMinor = minor
+
+	se.SetCuseInfo(info)
+
+	return se
+}
+
+// Open : open "/dev/cuse" for the session, the kernel sends CUSE_INIT
+// as the first request, and the device appears after FuseLoop replies it.
+// Closing the session removes the device.
+// Opening "/dev/cuse" usually needs root.
+func Open(se *fuse.Session) error {
+
+	fd, err := syscall.Open(CuseDev, syscall.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+
+	se.SetDev(fd)
+
+	return nil
+}
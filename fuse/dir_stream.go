@@ -0,0 +1,56 @@
+package fuse
+
+import (
+	"github.com/mingforpc/fuse-go/fuse/errno"
+)
+
+// DirStream : the directory stream returned by Opt.ReaddirStream.
+// The library reads entries until the reply buffer is full, the entries
+// read but not sent are returned again by the next stream, which starts
+// from the cookie (Dirent.Off) of the last sent entry.
+type DirStream interface {
+	// HasNext : whether there are more entries in the stream
+	HasNext() bool
+
+	// Next : return the next entry, res other than errno.SUCCESS stops the
+	// listing, and it is returned to the kernel if no entry has been sent
+	Next() (dirent Dirent, res int32)
+
+	// Close : called once the reply buffer is full or the stream ends
+	Close()
+}
+
+// sliceDirStream : DirStream of the list returned by Opt.Readdir
+type sliceDirStream struct {
+	list []Dirent
+	idx  int
+}
+
+func newSliceDirStream(list []Dirent) *sliceDirStream {
+	return &sliceDirStream{list: list}
+}
+
+func (stream *sliceDirStream) HasNext() bool {
+	return stream.idx < len(stream.list)
+}
+
+func (stream *sliceDirStream) Next() (Dirent, int32) {
+	dirent := stream.list[stream.idx]
+	stream.idx++
+	return dirent, errno.SUCCESS
+}
+
+func (stream *sliceDirStream) Close() {
+}
+
+// direntCookie : the cookie of the index-th entry read from offset.
+// Dirent.Off is the offset of the next entry, if it is 0 the library
+// uses the position of the entry in the directory, offset+index+1.
+func direntCookie(dirent Dirent, offset uint64, index int) uint64 {
+
+	if dirent.Off != 0 {
+		return dirent.Off
+	}
+
+	return offset + uint64(index) + 1
+}
@@ -0,0 +1,224 @@
+package errno
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// Errno : an errno of the package, as res of the handlers.
+// The constants are untyped, convert res to print its name,
+// e.g. errno.Errno(res).String() is "ENOENT" for errno.ENOENT.
+type Errno int32
+
+// names : the names of errno, the aliases such as EWOULDBLOCK are not in it
+var names = map[Errno]string{
+	SUCCESS:         "SUCCESS",
+	EPERM:           "EPERM",
+	ENOENT:          "ENOENT",
+	ESRCH:           "ESRCH",
+	EINTR:           "EINTR",
+	EIO:             "EIO",
+	ENXIO:           "ENXIO",
+	E2BIG:           "E2BIG",
+	ENOEXEC:         "ENOEXEC",
+	EBADF:           "EBADF",
+	ECHILD:          "ECHILD",
+	EAGAIN:          "EAGAIN",
+	ENOMEM:          "ENOMEM",
+	EACCES:          "EACCES",
+	EFAULT:          "EFAULT",
+	ENOTBLK:         "ENOTBLK",
+	EBUSY:           "EBUSY",
+	EEXIST:          "EEXIST",
+	EXDEV:           "EXDEV",
+	ENODEV:          "ENODEV",
+	ENOTDIR:         "ENOTDIR",
+	EISDIR:          "EISDIR",
+	EINVAL:          "EINVAL",
+	ENFILE:          "ENFILE",
+	EMFILE:          "EMFILE",
+	ENOTTY:          "ENOTTY",
+	ETXTBSY:         "ETXTBSY",
+	EFBIG:           "EFBIG",
+	ENOSPC:          "ENOSPC",
+	ESPIPE:          "ESPIPE",
+	EROFS:           "EROFS",
+	EMLINK:          "EMLINK",
+	EPIPE:           "EPIPE",
+	EDOM:            "EDOM",
+	ERANGE:          "ERANGE",
+	EDEADLK:         "EDEADLK",
+	ENAMETOOLONG:    "ENAMETOOLONG",
+	ENOLCK:          "ENOLCK",
+	ENOSYS:          "ENOSYS",
+	ENOTEMPTY:       "ENOTEMPTY",
+	ELOOP:           "ELOOP",
+	ENOMSG:          "ENOMSG",
+	EIDRM:           "EIDRM",
+	ECHRNG:          "ECHRNG",
+	EL2NSYNC:        "EL2NSYNC",
+	EL3HLT:          "EL3HLT",
+	EL3RST:          "EL3RST",
+	ELNRNG:          "ELNRNG",
+	EUNATCH:         "EUNATCH",
+	ENOCSI:          "ENOCSI",
+	EL2HLT:          "EL2HLT",
+	EBADE:           "EBADE",
+	EBADR:           "EBADR",
+	EXFULL:          "EXFULL",
+	ENOANO:          "ENOANO",
+	EBADRQC:         "EBADRQC",
+	EBADSLT:         "EBADSLT",
+	EBFONT:          "EBFONT",
+	ENOSTR:          "ENOSTR",
+	ENODATA:         "ENODATA",
+	ETIME:           "ETIME",
+	ENOSR:           "ENOSR",
+	ENONET:          "ENONET",
+	ENOPKG:          "ENOPKG",
+	EREMOTE:         "EREMOTE",
+	ENOLINK:         "ENOLINK",
+	EADV:            "EADV",
+	ESRMNT:          "ESRMNT",
+	ECOMM:           "ECOMM",
+	EPROTO:          "EPROTO",
+	EMULTIHOP:       "EMULTIHOP",
+	EDOTDOT:         "EDOTDOT",
+	EBADMSG:         "EBADMSG",
+	EOVERFLOW:       "EOVERFLOW",
+	ENOTUNIQ:        "ENOTUNIQ",
+	EBADFD:          "EBADFD",
+	EREMCHG:         "EREMCHG",
+	ELIBACC:         "ELIBACC",
+	ELIBBAD:         "ELIBBAD",
+	ELIBSCN:         "ELIBSCN",
+	ELIBMAX:         "ELIBMAX",
+	ELIBEXEC:        "ELIBEXEC",
+	EILSEQ:          "EILSEQ",
+	ERESTART:        "ERESTART",
+	ESTRPIPE:        "ESTRPIPE",
+	EUSERS:          "EUSERS",
+	ENOTSOCK:        "ENOTSOCK",
+	EDESTADDRREQ:    "EDESTADDRREQ",
+	EMSGSIZE:        "EMSGSIZE",
+	EPROTOTYPE:      "EPROTOTYPE",
+	ENOPROTOOPT:     "ENOPROTOOPT",
+	EPROTONOSUPPORT: "EPROTONOSUPPORT",
+	ESOCKTNOSUPPORT: "ESOCKTNOSUPPORT",
+	EOPNOTSUPP:      "EOPNOTSUPP",
+	EPFNOSUPPORT:    "EPFNOSUPPORT",
+	EAFNOSUPPORT:    "EAFNOSUPPORT",
+	EADDRINUSE:      "EADDRINUSE",
+	EADDRNOTAVAIL:   "EADDRNOTAVAIL",
+	ENETDOWN:        "ENETDOWN",
+	ENETUNREACH:     "ENETUNREACH",
+	ENETRESET:       "ENETRESET",
+	ECONNABORTED:    "ECONNABORTED",
+	ECONNRESET:      "ECONNRESET",
+	ENOBUFS:         "ENOBUFS",
+	EISCONN:         "EISCONN",
+	ENOTCONN:        "ENOTCONN",
+	ESHUTDOWN:       "ESHUTDOWN",
+	ETOOMANYREFS:    "ETOOMANYREFS",
+	ETIMEDOUT:       "ETIMEDOUT",
+	ECONNREFUSED:    "ECONNREFUSED",
+	EHOSTDOWN:       "EHOSTDOWN",
+	EHOSTUNREACH:    "EHOSTUNREACH",
+	EALREADY:        "EALREADY",
+	EINPROGRESS:     "EINPROGRESS",
+	ESTALE:          "ESTALE",
+	EUCLEAN:         "EUCLEAN",
+	ENOTNAM:         "ENOTNAM",
+	ENAVAIL:         "ENAVAIL",
+	EISNAM:          "EISNAM",
+	EREMOTEIO:       "EREMOTEIO",
+	EDQUOT:          "EDQUOT",
+	ENOMEDIUM:       "ENOMEDIUM",
+	EMEDIUMTYPE:     "EMEDIUMTYPE",
+	ECANCELED:       "ECANCELED",
+	ENOKEY:          "ENOKEY",
+	EKEYEXPIRED:     "EKEYEXPIRED",
+	EKEYREVOKED:     "EKEYREVOKED",
+	EKEYREJECTED:    "EKEYREJECTED",
+	EOWNERDEAD:      "EOWNERDEAD",
+	ENOTRECOVERABLE: "ENOTRECOVERABLE",
+	ERFKILL:         "ERFKILL",
+	EHWPOISON:       "EHWPOISON",
+}
+
+// String : the name of errno, or its number if unknown
+func (e Errno) String() string {
+
+	if name, ok := names[e]; ok {
+		return name
+	}
+
+	return "Errno(" + strconv.Itoa(int(e)) + ")"
+}
+
+// Error : the name and the description of errno, e.g. "ENOENT: no such file or directory"
+func (e Errno) Error() string {
+
+	if e == SUCCESS {
+		return e.String()
+	}
+
+	return e.String() + ": " + syscall.Errno(-e).Error()
+}
+
+// Name : the name of res, e.g. "ENOENT"
+func Name(res int32) string {
+	return Errno(res).String()
+}
+
+// FromError : the errno of err by errors.Is and errors.As, ok is false if
+// err is not known. A syscall.Errno in err (e.g. of *os.PathError) is used
+// as it is, the others are:
+//
+//	nil: SUCCESS
+//	Errno: itself
+//	os.ErrNotExist: ENOENT
+//	os.ErrExist: EEXIST
+//	os.ErrPermission: EACCES
+//	os.ErrInvalid: EINVAL
+//	os.ErrClosed: EBADF
+//	os.ErrDeadlineExceeded, context.DeadlineExceeded: ETIMEDOUT
+//	context.Canceled: EINTR, as an interrupted request
+func FromError(err error) (res int32, ok bool) {
+
+	if err == nil {
+		return SUCCESS, true
+	}
+
+	var e Errno
+	if errors.As(err, &e) {
+		return int32(e), true
+	}
+
+	var sysErr syscall.Errno
+	if errors.As(err, &sysErr) && sysErr != 0 {
+		return -int32(sysErr), true
+	}
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return ENOENT, true
+	case errors.Is(err, os.ErrExist):
+		return EEXIST, true
+	case errors.Is(err, os.ErrPermission):
+		return EACCES, true
+	case errors.Is(err, os.ErrInvalid):
+		return EINVAL, true
+	case errors.Is(err, os.ErrClosed):
+		return EBADF, true
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded):
+		return ETIMEDOUT, true
+	case errors.Is(err, context.Canceled):
+		return EINTR, true
+	}
+
+	return EIO, false
+}
@@ -0,0 +1,29 @@
+package fuse
+
+import (
+	"github.com/mingforpc/fuse-go/fuse/errno"
+	"github.com/mingforpc/fuse-go/fuse/log"
+)
+
+// ToErrno : the res of handler for the error err of a backend, such as
+// *os.PathError, syscall.Errno, os.ErrNotExist or context.Canceled,
+// see errno.FromError. The unknown errors are logged and become errno.EIO.
+//
+//	stat, err := os.Lstat(path)
+//	if err != nil {
+//		return nil, fuse.ToErrno(err)
+//	}
+func ToErrno(err error) int32 {
+
+	res, ok := errno.FromError(err)
+	if !ok {
+		log.Error.Printf("Unknown error[%s] is replied EIO \n", err)
+	}
+
+	return res
+}
+
+// ReplyError : reply the errno of err by ToErrno, nil is errno.SUCCESS
+func (reply *Reply) ReplyError(err error) error {
+	return reply.ReplyErr(ToErrno(err))
+}
@@ -162,3 +162,12 @@ const FuseCapPosixACL = (1 << 19)
 //
 // This feature is enabled by default when supported by the kernel.
 const FuseCapHandleKillpriv = (1 << 20)
+
+// FuseCapPassthrough : Indicates that the filesystem can hand a backing file
+// to the kernel in open and create, then read and write of the file are
+// passed to the backing file by the kernel, without calling the filesystem.
+//
+// Registering a backing file needs CAP_SYS_ADMIN.
+//
+// This feature is disabled by default, set Config.Passthrough to enable it.
+const FuseCapPassthrough = (1 << 37)
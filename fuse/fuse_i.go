@@ -1,6 +1,8 @@
 package fuse
 
 import (
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -23,11 +25,17 @@ const FuseEvLoopSize = 128
  * FOpenDirectIO: bypass page cache for this open file
  * FOpenKeepCache: don't invalidate the data cache on open
  * FOpenNonSeekable: the file is not seekable
+ * FOpenCacheDir: allow caching this directory
+ * FOpenNoflush: don't flush data cache on close (unless FUSE_WRITEBACK_CACHE)
+ * FOpenPassthrough: passthrough read/write io for this open file
  */
 const (
 	FOpenDirectIO    = (1 << 0)
 	FOpenKeepCache   = (1 << 1)
 	FOpenNonSeekable = (1 << 2)
+	FOpenCacheDir    = (1 << 3)
+	FOpenNoflush     = (1 << 5)
+	FOpenPassthrough = (1 << 7)
 )
 
 /* 'toSet' flags in setattr */
@@ -58,7 +66,7 @@ type ConnInfo struct {
 	/**
 	 * Capability flags that the kernel supports (read-only)
 	 */
-	Capable uint32
+	Capable uint64
 
 	/**
 	 * Capability flags that the filesystem wants to enable.
@@ -66,20 +74,49 @@ type ConnInfo struct {
 	 * libfuse attempts to initialize this field with
 	 * reasonable default values before calling the init() handler.
 	 */
-	Want uint32
+	Want uint64
 
 	MaxWrite            uint32
 	MaxBackground       uint16
 	CongestionThreshold uint16
 	TimeGran            uint32
+
+	/**
+	 * The max stacking depth of the backing files for passthrough,
+	 * 1 if the backing files are not on a stacked filesystem.
+	 */
+	MaxStackDepth uint32
+}
+
+// CuseInfo : the character device created in CUSE mode
+type CuseInfo struct {
+	// DevName : name of the device, it will be "/dev/{DevName}"
+	DevName string
+
+	DevMajor uint32
+	DevMinor uint32
+
+	// MaxRead : the max size of read request, default is 128k
+	MaxRead uint32
+
+	/**
+	 * Flags to the kernel, kernel.CuseUnrestrictedIoctl means
+	 * ioctl on the device is not restricted to well-formed ioctls
+	 */
+	Flags uint32
 }
 
 // Session : The main session to control fuse application
 type Session struct {
+	// usage : first for the 64-bit alignment of atomic
+	usage usage
+
 	Mountpoint string
 
 	devFd int // "dev/fuse" fd
 
+	transport Transport // read requests and write replies, devFd by default
+
 	inited bool // is inited or not
 
 	bufsize int // read buffser size (/dev/fuse)
@@ -88,18 +125,39 @@ type Session struct {
 
 	connInfo *ConnInfo // Fuse Connection Info
 
+	cuseInfo *CuseInfo // the character device, only for CUSE
+
 	FuseConfig *Config // fuse configuration
 
 	Opts *Opt
 
 	Debug bool
 
-	Running bool
+	running atomic.Bool // FuseLoop is serving the transport
+
+	readChan  chan *[]byte
+	writeChan chan [][]byte
+
+	bufPool sync.Pool // the read buffers of bufsize
+
+	closeCh   chan interface{}
+	closeOnce sync.Once // close closeCh once
 
-	readChan  chan []byte
-	writeChan chan []byte
+	transportOnce sync.Once // close the transport once, by Close or Takeover
 
-	closeCh chan interface{}
+	loopDone  chan interface{} // closed when FuseLoop returns
+	detaching atomic.Bool      // stop reading "/dev/fuse" for takeover
+	inflight  sync.WaitGroup   // requests that have not been replied yet
+	handlers  sync.WaitGroup   // goroutines handling the requests
+
+	reqs     map[uint64]*reqContext // requests being handled, for interrupt
+	reqsLock sync.Mutex
+
+	backings     map[uint64]*backing // backing files of passthrough, by nodeid
+	backingsLock sync.Mutex
+
+	generations     map[uint64]uint64 // generation of reused nodeid
+	generationsLock sync.Mutex
 
 	userdata interface{} // user data
 
@@ -142,9 +200,37 @@ func (se *Session) IsInited() bool {
 	return se.inited
 }
 
+// IsRunning : if FuseLoop is serving the session, false after it is
+// closed or taken over
+func (se *Session) IsRunning() bool {
+	return se.running.Load()
+}
+
+// SetRunning : replace the assignment of the former Running field,
+// setting false stops FuseLoop after the request being read.
+//
+// Deprecated: stop the session by Close, check it by IsRunning.
+func (se *Session) SetRunning(running bool) {
+	se.running.Store(running)
+}
+
+// WaitHandlers : wait until the goroutines handling the requests return,
+// call it after FuseLoop returns. A request answered later by Reply is
+// not waited once its handler returns.
+func (se *Session) WaitHandlers() {
+	se.handlers.Wait()
+}
+
 // SetDev : set "/dev/fuse", fd is the file descriptor of "/dev/fuse"
 func (se *Session) SetDev(fd int) {
 	se.devFd = fd
+	se.transport = devTransport{fd: fd}
+}
+
+// SetCuseInfo : set the character device to create,
+// the session will serve CUSE instead of FUSE
+func (se *Session) SetCuseInfo(info *CuseInfo) {
+	se.cuseInfo = info
 }
 
 // Req : struct of fuse req
@@ -157,7 +243,16 @@ type Req struct {
 	Pid     uint32
 	Padding uint32
 
+	// Umask : umask of the caller, only set in Create, Mknod and Mkdir.
+	// The mode is already masked by the kernel, unless kernel.FuseDontMask is wanted.
+	Umask uint32
+
 	Arg *interface{}
+
+	opcode uint32
+	nodeid uint64
+
+	ctx *reqContext
 }
 
 // Init : fuse req initialize function
@@ -168,6 +263,8 @@ func (req *Req) Init(se *Session, inheader kernel.FuseInHeader) {
 	req.Gid = inheader.Gid
 	req.Pid = inheader.Pid
 	req.Padding = inheader.Padding
+	req.opcode = inheader.Opcode
+	req.nodeid = inheader.Nodeid
 }
 
 // GetFuseConfig : return fuse configrtion
@@ -184,53 +281,134 @@ type Config struct {
 	 * (as returned by e.g. the `getattr` handler) are cached.
 	 */
 	AttrTimeout float64
+	/**
+	 * The timeout in seconds for which name lookups will be
+	 * cached.
+	 */
+	EntryTimeout float64
+	/**
+	 * The timeout in seconds for which a negative lookup will be
+	 * cached. This means, that if file did not exist (lookup
+	 * returned ENOENT), the lookup will only be redone after the
+	 * timeout, and the file/directory will be assumed to not
+	 * exist until then. A value of zero means that negative
+	 * lookups are not cached.
+	 */
+	NegativeTimeout float64
+	/**
+	 * Enable the writeback cache if the kernel supports it.
+	 * Writes are buffered in the kernel and sent later, maybe
+	 * merged, the kernel owns the file size and mtime of cached
+	 * files and sends Setattr to update them. Files opened
+	 * O_WRONLY are opened O_RDWR and O_APPEND is cleared, the
+	 * kernel may read a page before writing part of it.
+	 */
+	WritebackCache bool
+	/**
+	 * Enable the passthrough of read and write to the backing files
+	 * if the kernel supports it, set FileInfo.Passthrough and
+	 * BackingFd in Open or Create to use it. Needs CAP_SYS_ADMIN.
+	 * All the opens of a file share one backing file, a later open
+	 * with the fd of another file is opened without passthrough.
+	 * The registered backing files are handed over by Takeover.
+	 */
+	Passthrough bool
+	/**
+	 * Enable the export support, so the mount can be exported
+	 * over NFS. The kernel looks up "." and "..", the library
+	 * answers them by Lookup and GetParent.
+	 */
+	ExportSupport bool
+	/**
+	 * Enable the POSIX ACLs if the kernel supports it. The ACLs
+	 * are stored by the filesystem in the xattrs
+	 * "system.posix_acl_access" and "system.posix_acl_default",
+	 * see the package acl. The kernel checks the permissions by
+	 * the ACLs (default_permissions is implied) and does not apply the umask,
+	 * new files should inherit the default ACL or be masked by
+	 * Req.Umask (acl.Inherit).
+	 */
+	PosixACL bool
+	/**
+	 * The statistics of statfs(2) when Opt.Statfs is nil. If
+	 * StatfsPath is set, it is the statfs of that path, e.g. the
+	 * backing directory. Otherwise Capacity (in bytes) and
+	 * MaxInodes are the size of the filesystem, and the free space
+	 * and inodes are computed from the usage maintained by
+	 * Session.AddUsedBytes and Session.AddUsedInodes.
+	 */
+	StatfsPath string
+	Capacity   uint64
+	MaxInodes  uint64
+	/**
+	 * Record every request and reply to the file RecordPath when
+	 * FuseLoop starts, the file is truncated. Read it by the package
+	 * record, or replay it by fusetest.Replay to reproduce a bug.
+	 * The recording has the data of the files, keep it private.
+	 */
+	RecordPath string
 }
 
 // Init : fuse configuration initialize function
 func (config *Config) Init() {
 	config.FuseStartTime = time.Now().UnixNano()
 	config.AttrTimeout = 2
+	config.EntryTimeout = 2
 }
 
 // FileInfo : the fuse file infomation struct
 type FileInfo struct {
 
-	/** Open flags.	 Available in open() and release() */
+	/** Open flags.	 Available in open(), create() and release() */
 	Flags uint32
 
 	/** In case of a write operation indicates if this was caused by a
-	  writepage */
-	Writepage uint
+	  delayed write from the page cache (writepage) */
+	Writepage bool
 
 	/** Can be filled in by open, to use direct I/O on this file. */
-	DirectIo uint
+	DirectIo bool
 
 	/** Can be filled in by open, to indicate that currently
 	  cached file data (that the filesystem provided the last
 	  time the file was open) need not be invalidated. */
-	KeepCache uint
+	KeepCache bool
 
 	/** Indicates a flush operation.  Set in flush operation, also
-	  maybe set in highlevel lock operation and lowlevel release
-	  operation. */
-	Flush uint
+	  set in release operation if the file should be flushed. */
+	Flush bool
 
 	/** Can be filled in by open, to indicate that the file is not
 	  seekable. */
-	Nonseekable uint
+	Nonseekable bool
 
 	/* Indicates that flock locks for this file should be
 	   released.  If set, lock_owner shall contain a valid value.
 	   May only be set in ->release(). */
-	FlockRelease uint
+	FlockRelease bool
+
+	/** Can be filled in by opendir. It signals the kernel to
+	  enable caching of entries returned by readdir(). */
+	CacheDir bool
 
-	/** Padding.  Do not use*/
-	Padding uint
+	/** Can be filled in by open, to indicate that flush is not
+	  needed on close of this file. */
+	NoFlush bool
 
 	/** File handle.  May be filled in by filesystem in open().
 	  Available in all other file operations */
 	Fh uint64
 
+	/** Can be filled in by open and create with Config.Passthrough,
+	  to indicate that BackingFd is the backing file. The zero value
+	  is no backing file. */
+	Passthrough bool
+
+	/** The fd of the backing file to read and write by the kernel,
+	  used only if Passthrough is set. The library registers it, so
+	  the fd can be closed after open returns. */
+	BackingFd int
+
 	/** Lock owner id.  Available in locking operations and flush */
 	LockOwner uint64
 
@@ -241,40 +419,74 @@ type FileInfo struct {
 
 // NewFuseFileInfo : the function to new a fuse file info
 func NewFuseFileInfo() FileInfo {
-	info := FileInfo{}
-
-	info.Writepage = 1
-	info.DirectIo = 1
-	info.KeepCache = 1
-	info.Flush = 1
-	info.Nonseekable = 1
-	info.FlockRelease = 1
-	info.Padding = 27
-
-	return info
+	return FileInfo{BackingFd: -1}
 }
 
 // Pollhandle : poll handle
 type Pollhandle struct {
 	Kh uint64
-	Se Session
+	Se *Session
 }
 
 // FileStat : fuse file stat
 type FileStat struct {
-	Nodeid     uint64
+	// Nodeid : the inode number, Lookup returns 0 for a negative entry
+	Nodeid uint64
+	// Generation : nodeid:Generation must be unique for the fs's lifetime,
+	// 0 means Session.Generation(Nodeid)
 	Generation uint64
 	Stat       syscall.Stat_t
+
+	// EntryTimeout : validity timeout (in seconds) for the name,
+	// 0 is Config.EntryTimeout (Config.NegativeTimeout for negative entry),
+	// negative means no caching
+	EntryTimeout float64
+
+	// AttrTimeout : validity timeout (in seconds) for the attributes,
+	// 0 is Config.AttrTimeout, negative means no caching
+	AttrTimeout float64
 }
 
 // Dirent : The Dirent sturct provide to outside
 type Dirent kernel.FuseDirent
 
+// DirentPlus : The entry of readdirplus, Dirent with its attributes
+type DirentPlus struct {
+	Dirent Dirent
+
+	// FileStat : the attributes of entry, Nodeid 0 means no attributes are returned
+	FileStat FileStat
+
+	// EntryTimeout : validity timeout (in seconds) for the name, 0 is FileStat.EntryTimeout
+	EntryTimeout float64
+
+	// AttrTimeout : validity timeout (in seconds) for the attributes, 0 is FileStat.AttrTimeout
+	AttrTimeout float64
+}
+
 // Statfs : The FuseStatfs stuct provide to outside
 type Statfs kernel.FuseStatfs
 
-// Ioctl : The FuseIoctlOut struct provide to outside
-type Ioctl kernel.FuseIoctlOut
+// IoctlIovec : The FuseIoctlIovec struct provide to outside
+type IoctlIovec kernel.FuseIoctlIovec
+
+// Ioctl : the result of ioctl
+type Ioctl struct {
+	/** The return value of ioctl() to the caller */
+	Result int32
+
+	/** Data copied out to the caller, should not be larger than outbufsz */
+	OutBuf []byte
+
+	/** Ask the kernel to retry with the areas in InIovs and OutIovs.
+	  The caller's memory in InIovs will be fetched as inbuf,
+	  and outbufsz will be the total size of OutIovs.
+	  Only allowed for unrestricted ioctl (CUSE). */
+	Retry bool
+
+	InIovs  []IoctlIovec
+	OutIovs []IoctlIovec
+}
 
 // ForgetOne : The FuseForgetOne struct provide to outside
 type ForgetOne kernel.FuseForgetOne
@@ -2,7 +2,6 @@ package fuse
 
 import (
 	"bytes"
-	"errors"
 	"syscall"
 
 	"github.com/mingforpc/fuse-go/fuse/evloop"
@@ -19,11 +18,17 @@ func (se *Session) FuseLoop() {
 		panic(kernel.ErrNotInit)
 	}
 
-	se.Running = true
+	se.startRecord()
 
-	se.readChan = make(chan []byte, se.maxGoro)
-	se.writeChan = make(chan []byte, se.maxGoro)
+	se.running.Store(true)
+	se.detaching.Store(false)
+
+	se.readChan = make(chan *[]byte, se.maxGoro)
+	se.writeChan = make(chan [][]byte, se.maxGoro)
 	se.closeCh = make(chan interface{})
+	se.loopDone = make(chan interface{})
+
+	defer close(se.loopDone)
 
 	// Write goroutine
 	// 用来写"/dev/fuse"的goroutine
@@ -33,7 +38,7 @@ func (se *Session) FuseLoop() {
 	// 用来读取"/dev/fuse"的goroutine
 	go se.readGoro()
 
-	for se.Running {
+	for se.running.Load() {
 
 		brep, ok := <-se.readChan
 
@@ -41,25 +46,55 @@ func (se *Session) FuseLoop() {
 			break
 		}
 
-		inheader, buf, err := se.parseHeader(brep)
+		inheader, buf, err := se.parseHeader(*brep)
 
 		if err != nil {
-
+			// no request to reply, drop it
 			log.Error.Printf("Session parseHeader[%s] \n", err)
-			break
+			se.putBuf(brep)
+			continue
 		}
 
 		req := Req{}
 		req.Init(se, inheader)
 
+		// registered before the next read, so an interrupt always finds it
+		se.registerReq(&req)
+
+		// the arguments of request are in brep, it is held by the handler
+		// and by the Reply of Async until they are done
+		req.ctx.buf = brep
+		req.ctx.bufRefs = 1
+
+		// every request is done after its reply is written,
+		// Takeover waits for them before handing the fd over
+		se.inflight.Add(1)
+		se.handlers.Add(1)
+
 		// 用来处理各个请求的goroutine
 		go func() {
 
+			replied := false
+
+			defer se.handlers.Done()
+
 			defer func() {
 				if err := recover(); err != nil {
 					log.Error.Printf("Distribute goroutine error[%s] \n", err)
 				}
 
+				se.releaseBuf(req.ctx)
+
+				if req.isAsync() {
+					// done by its Reply
+					return
+				}
+
+				se.unregisterReq(&req)
+
+				if !replied {
+					se.inflight.Done()
+				}
 			}()
 
 			res, err := distribute(&req, inheader, buf)
@@ -79,6 +114,7 @@ func (se *Session) FuseLoop() {
 					return
 				// TODO: 依然会存在触发关闭closed channel状态
 				case se.writeChan <- res:
+					replied = true
 				}
 
 			}
@@ -89,31 +125,29 @@ func (se *Session) FuseLoop() {
 }
 
 func (se *Session) readGoro() {
+	// FuseLoop returns after the read goroutine exits, even on error
+	defer close(se.readChan)
+
 	defer func() {
 		if err := recover(); err != nil {
 			log.Error.Printf("Read goroutine error[%s] \n", err)
+			se.running.Store(false)
 		}
 	}()
 
-	el := se.evloop
+	if !se.isDev() {
+		// ReadFrame of other transport blocks until a request comes
+		for se.running.Load() && !se.detaching.Load() {
+			se.readReq()
+		}
 
-	handler := func(el *evloop.EvLoop, fd int, eventmask int, privdata interface{}) {
-		breq, err := se.readCmd()
-		if err != nil {
+		return
+	}
 
-			if err == syscall.ENODEV || err == syscall.EBADF {
-				se.Running = false
-			} else {
-				// 读出错退出
-				log.Error.Printf("err: %+v \n", err)
-				panic(err)
-			}
-		}
+	el := se.evloop
 
-		// Read 可能block很久，所以再判断一次
-		if se.Running {
-			se.readChan <- breq
-		}
+	handler := func(el *evloop.EvLoop, fd int, eventmask int, privdata interface{}) {
+		se.readReq()
 	}
 
 	err := el.Register(se.devFd, evloop.EPOLLIN, handler, nil)
@@ -122,17 +156,35 @@ func (se *Session) readGoro() {
 		panic(err)
 	}
 
-	for se.Running {
+	for se.running.Load() && !se.detaching.Load() {
 		// wait 1 second
 		el.Process(1000)
 	}
 
-	close(se.readChan)
+}
 
+// readReq : read a request and pass it to FuseLoop
+func (se *Session) readReq() {
+	breq, err := se.readCmd()
+	if err != nil {
+
+		if err == syscall.ENODEV || err == syscall.EBADF {
+			se.running.Store(false)
+		} else {
+			// 读出错退出
+			log.Error.Printf("err: %+v \n", err)
+			panic(err)
+		}
+	}
+
+	// Read 可能block很久，所以再判断一次
+	if se.running.Load() {
+		se.readChan <- breq
+	}
 }
 
 func (se *Session) writeGoro() {
-	for se.Running {
+	for se.running.Load() {
 
 		res, ok := <-se.writeChan
 
@@ -140,71 +192,116 @@ func (se *Session) writeGoro() {
 			break
 		}
 
-		if se.Running {
+		if se.running.Load() {
 			err := se.writeCmd(res)
 			if err != nil {
 				log.Error.Println(err)
 			}
 		}
 
+		se.inflight.Done()
 	}
 
 }
 
-// Close : close fuse session
+// Close : close fuse session, it can be called more than once,
+// and after Takeover
 func (se *Session) Close() {
-	se.Running = false
+	se.running.Store(false)
 
-	syscall.Close(se.devFd)
+	se.closeTransport()
 
-	close(se.closeCh)
+	se.closeOnce.Do(func() {
+		close(se.closeCh)
+	})
 	// close(se.writeChan)
 
 }
 
-// Read event from '/dev/fuse'
-func (se *Session) readCmd() ([]byte, error) {
-	var cmdLenBytes = make([]byte, se.bufsize)
+// closeTransport : close the transport only once, the fd number of
+// "/dev/fuse" may be reused by another file after it is closed
+func (se *Session) closeTransport() {
+
+	se.transportOnce.Do(func() {
+		se.transport.Close()
+		se.devFd = -1
+	})
+}
+
+// Read event from '/dev/fuse' (the transport),
+// the buffer is released as described in getBuf
+func (se *Session) readCmd() (*[]byte, error) {
+	cmdLenBytes := se.getBuf()
 
-	n, err := syscall.Read(se.devFd, cmdLenBytes)
+	n, err := se.transport.ReadFrame(*cmdLenBytes)
 
 	if err != nil {
+		se.putBuf(cmdLenBytes)
 		return nil, err
 	}
 
-	cmdLenBytes = cmdLenBytes[0:n]
+	*cmdLenBytes = (*cmdLenBytes)[0:n]
 
 	return cmdLenBytes, err
 }
 
+// getBuf : get a read buffer of bufsize from the pool.
+// The arguments parsed from the buffer may point into it, only the Buf
+// of Write does, the others are copied. So the buffer of a registered
+// request is released by releaseBuf, after the handler has returned and,
+// if it called Async, after the reply is sent. putBuf is only for the
+// buffer not held by any request.
+func (se *Session) getBuf() *[]byte {
+
+	if b, ok := se.bufPool.Get().(*[]byte); ok && cap(*b) >= se.bufsize {
+		*b = (*b)[:se.bufsize]
+		return b
+	}
+
+	b := make([]byte, se.bufsize)
+	return &b
+}
+
+// putBuf : put the read buffer back to the pool, see getBuf
+func (se *Session) putBuf(b *[]byte) {
+	se.bufPool.Put(b)
+}
+
+// parseHeader : parse the header of request, the length in it should
+// match the bytes read, the bytes after it are the argument
 func (se *Session) parseHeader(bcontent []byte) (kernel.FuseInHeader, []byte, error) {
 	var inheader = kernel.FuseInHeader{}
 
-	headerbytes := bcontent[:40]
-	opsbytes := bcontent[40:]
+	err := inheader.ParseBinary(bcontent)
+	if err != nil {
+		return inheader, nil, err
+	}
+
+	if inheader.Len < kernel.InHeaderLen || uint64(inheader.Len) > uint64(len(bcontent)) {
+		return inheader, nil, kernel.ErrDataLen
+	}
 
-	err := inheader.ParseBinary(headerbytes)
+	opsbytes := bcontent[kernel.InHeaderLen:inheader.Len]
 
 	if se.Debug {
 		log.Trace.Printf("cmdLenBytes[%+v]", bcontent)
 		log.Trace.Printf("inheader: %+v, content[%+v]", inheader, opsbytes)
 	}
 
-	return inheader, opsbytes, err
+	return inheader, opsbytes, nil
 }
 
-// Write response to '/dev/fuse'
-func (se *Session) writeCmd(resp []byte) error {
+// Write response to '/dev/fuse' (the transport),
+// resp is the header and the data of the reply
+func (se *Session) writeCmd(resp [][]byte) error {
 	if se.Debug {
 		log.Trace.Printf("resp[%+v] \n", resp)
 	}
-	_, err := syscall.Write(se.devFd, resp)
-
-	return err
+	return se.transport.WriteFrame(resp)
 }
 
 // Distribute event to earch function
-func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte, error) {
+func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([][]byte, error) {
 
 	var arg interface{}
 	var errnum int32
@@ -216,7 +313,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpInit:
 		// Init event
 		var initIn = kernel.FuseInitIn{}
-		initIn.ParseBinary(bcontent)
+		if err := initIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = initIn
 		req.Arg = &arg
 		var initOut = kernel.FuseInitOut{}
@@ -225,6 +326,22 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 
 		resp = initOut
 
+	case kernel.CuseInit:
+		// CUSE init event
+		var cuseInitIn = kernel.CuseInitIn{}
+		if err := cuseInitIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
+		arg = cuseInitIn
+		req.Arg = &arg
+		var cuseInitOut = kernel.CuseInitResp{}
+
+		errnum = doCuseInit(*req, &cuseInitOut)
+
+		resp = cuseInitOut
+
 	case kernel.FuseOpDestory:
 		// Destory event
 
@@ -235,7 +352,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpForget:
 		// Forget event
 		var fotgetIn = kernel.FuseForgetIn{}
-		fotgetIn.ParseBinary(bcontent)
+		if err := fotgetIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			noreply = true
+			break
+		}
 		arg = fotgetIn
 		req.Arg = &arg
 
@@ -246,7 +367,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpLookup:
 		// lookup event
 		var lookupIn = kernel.FuseLookupIn{}
-		lookupIn.ParseBinary(bcontent)
+		if err := lookupIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = lookupIn
 		req.Arg = &arg
 
@@ -259,7 +384,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpGetattr:
 		// Getattr event
 		var getattrIn = kernel.FuseGetattrIn{}
-		getattrIn.ParseBinary(bcontent)
+		if err := getattrIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = getattrIn
 		req.Arg = &arg
 		var getattrOut = kernel.FuseAttrOut{}
@@ -268,10 +397,30 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 
 		resp = getattrOut
 
+	case kernel.FuseOpStatx:
+		// Statx event
+		var statxIn = kernel.FuseStatxIn{}
+		if err := statxIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
+		arg = statxIn
+		req.Arg = &arg
+		var statxOut = kernel.FuseStatxOut{}
+
+		errnum = doStatx(*req, inHeader.Nodeid, &statxOut)
+
+		resp = statxOut
+
 	case kernel.FuseOpSetattr:
 		// Setattr event
 		var setattrIn = kernel.FuseSetattrIn{}
-		setattrIn.ParseBinary(bcontent)
+		if err := setattrIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = setattrIn
 		req.Arg = &arg
 		var setattrOut = kernel.FuseAttrOut{}
@@ -290,7 +439,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpMknod:
 		// Mknod event
 		var mknodIn = kernel.FuseMknodIn{}
-		mknodIn.ParseBinary(bcontent)
+		if err := mknodIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = mknodIn
 		req.Arg = &arg
 
@@ -303,7 +456,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpMkdir:
 		// Mkdir event
 		var mkdirIn = kernel.FuseMkdirIn{}
-		mkdirIn.ParseBinary(bcontent)
+		if err := mkdirIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = mkdirIn
 		req.Arg = &arg
 
@@ -316,7 +473,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpUnlink:
 		// Unlink event
 		var unlinkIn = kernel.FuseUnlinkIn{}
-		unlinkIn.ParseBinary(bcontent)
+		if err := unlinkIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = unlinkIn
 		req.Arg = &arg
 
@@ -325,7 +486,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpRmdir:
 		// Rmdir event
 		var rmdirIn = kernel.FuseRmdirIn{}
-		rmdirIn.ParseBinary(bcontent)
+		if err := rmdirIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = rmdirIn
 		req.Arg = &arg
 
@@ -333,7 +498,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpSymlink:
 		// Symlink event
 		var symlinkIn = kernel.FuseSymlinkIn{}
-		symlinkIn.ParseBinary(bcontent)
+		if err := symlinkIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = symlinkIn
 		req.Arg = &arg
 
@@ -346,7 +515,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpRename:
 		// Rename event
 		var renameIn = kernel.FuseRenameIn{}
-		renameIn.ParseBinary(bcontent)
+		if err := renameIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = renameIn
 		req.Arg = &arg
 
@@ -355,7 +528,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpRename2:
 		// Rename2 event
 		var renameIn = kernel.FuseRename2In{}
-		renameIn.ParseBinary(bcontent)
+		if err := renameIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = renameIn
 		req.Arg = &arg
 
@@ -363,7 +540,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpLink:
 		// Link event
 		var linkIn = kernel.FuseLinkIn{}
-		linkIn.ParseBinary(bcontent)
+		if err := linkIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = linkIn
 		req.Arg = &arg
 
@@ -376,7 +557,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpOpen:
 		// Open event
 		var openIn = kernel.FuseOpenIn{}
-		openIn.ParseBinary(bcontent)
+		if err := openIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = openIn
 		req.Arg = &arg
 
@@ -388,7 +573,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpRead:
 		// Read event
 		var readIn = kernel.FuseReadIn{}
-		readIn.ParseBinary(bcontent)
+		if err := readIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = readIn
 		req.Arg = &arg
 
@@ -401,7 +590,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpWrite:
 		// Write event
 		var writeIn = kernel.FuseWriteIn{}
-		writeIn.ParseBinary(bcontent)
+		if err := writeIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = writeIn
 		req.Arg = &arg
 
@@ -414,7 +607,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpFsync:
 		// Fsync event
 		var fsyncIn = kernel.FuseFsyncIn{}
-		fsyncIn.ParseBinary(bcontent)
+		if err := fsyncIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = fsyncIn
 		req.Arg = &arg
 
@@ -423,7 +620,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpOpendir:
 		// Opendir event
 		var openIn = kernel.FuseOpenIn{}
-		openIn.ParseBinary(bcontent)
+		if err := openIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = openIn
 		req.Arg = &arg
 
@@ -436,7 +637,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpReaddir:
 		// Readdir event
 		var readIn = kernel.FuseReadIn{}
-		readIn.ParseBinary(bcontent)
+		if err := readIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = readIn
 		req.Arg = &arg
 
@@ -449,7 +654,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpRelease:
 		// Release event
 		var releaseIn = kernel.FuseReleaseIn{}
-		releaseIn.ParseBinary(bcontent)
+		if err := releaseIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = releaseIn
 		req.Arg = &arg
 
@@ -458,7 +667,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpReleasedir:
 		// Releasedir event
 		var releasedirIn = kernel.FuseReleaseIn{}
-		releasedirIn.ParseBinary(bcontent)
+		if err := releasedirIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = releasedirIn
 		req.Arg = &arg
 
@@ -466,7 +679,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 
 	case kernel.FuseOpFlush:
 		var flushIn = kernel.FuseFlushIn{}
-		flushIn.ParseBinary(bcontent)
+		if err := flushIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = flushIn
 		req.Arg = &arg
 
@@ -475,7 +692,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	case kernel.FuseOpFsyncdir:
 		// Fsyncdir event
 		var fsyncdirIn = kernel.FuseFsyncIn{}
-		fsyncdirIn.ParseBinary(bcontent)
+		if err := fsyncdirIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = fsyncdirIn
 		req.Arg = &arg
 
@@ -494,7 +715,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 		// Setxattr event
 
 		var setxattrIn = kernel.FuseSetxattrIn{}
-		setxattrIn.ParseBinary(bcontent)
+		if err := setxattrIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = setxattrIn
 		req.Arg = &arg
 
@@ -504,41 +729,56 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 		// Getxattr event
 
 		var getxattrIn = kernel.FuseGetxattrIn{}
-		getxattrIn.ParseBinary(bcontent)
+		if err := getxattrIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = getxattrIn
 		req.Arg = &arg
 
 		var getxattrOut = kernel.FuseGetxattrOut{}
-		errnum = doGetxattr(*req, inHeader.Nodeid, &getxattrOut)
+		var valueOut = kernel.XattrVal{}
+		errnum = doGetxattr(*req, inHeader.Nodeid, &getxattrOut, &valueOut)
 
-		if getxattrOut.Value == "" {
+		// the size probe is replied the size, otherwise the value
+		if getxattrIn.Size == 0 {
 			resp = getxattrOut
 		} else {
-			resp = getxattrOut.Value
+			resp = valueOut
 		}
 
 	case kernel.FuseOpListxattr:
 		// Listxattr event
 
 		var listxattrIn = kernel.FuseGetxattrIn{}
-		listxattrIn.ParseBinary(bcontent)
+		if err := listxattrIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = listxattrIn
 		req.Arg = &arg
 
 		var listxattrOut = kernel.FuseGetxattrOut{}
-		errnum = doListxattr(*req, inHeader.Nodeid, &listxattrOut)
+		var listOut = kernel.XattrVal{}
+		errnum = doListxattr(*req, inHeader.Nodeid, &listxattrOut, &listOut)
 
-		if listxattrOut.Value == "" {
+		if listxattrIn.Size == 0 {
 			resp = listxattrOut
 		} else {
-			resp = listxattrOut.Value
+			resp = listOut
 		}
 
 	case kernel.FuseOpRemovexattr:
 		// Removexattr event
 
 		var removexattrIn = kernel.FuseRemovexattrIn{}
-		removexattrIn.ParseBinary(bcontent)
+		if err := removexattrIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = removexattrIn
 		req.Arg = &arg
 
@@ -548,7 +788,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 		// Access event
 
 		var accessIn = kernel.FuseAccessIn{}
-		accessIn.ParseBinary(bcontent)
+		if err := accessIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = accessIn
 		req.Arg = &arg
 
@@ -558,7 +802,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 		// Create event
 
 		var createIn = kernel.FuseCreateIn{}
-		createIn.ParseBinary(bcontent)
+		if err := createIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = createIn
 		req.Arg = &arg
 
@@ -576,7 +824,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 		// Getlk event
 
 		var getlkIn = kernel.FuseLkIn{}
-		getlkIn.ParseBinary(bcontent)
+		if err := getlkIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = getlkIn
 		req.Arg = &arg
 
@@ -590,7 +842,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 		// Setlk event
 
 		var setlkIn = kernel.FuseLkIn{}
-		setlkIn.ParseBinary(bcontent)
+		if err := setlkIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = setlkIn
 		req.Arg = &arg
 
@@ -600,7 +856,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 		// Getlkw event
 
 		var getlkIn = kernel.FuseLkIn{}
-		getlkIn.ParseBinary(bcontent)
+		if err := getlkIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = getlkIn
 		req.Arg = &arg
 
@@ -609,7 +869,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 		// Bmap event
 
 		var bmapIn = kernel.FuseBmapIn{}
-		bmapIn.ParseBinary(bcontent)
+		if err := bmapIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = bmapIn
 		req.Arg = &arg
 
@@ -623,7 +887,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 		// Ioctl event
 
 		var ioctlIn = kernel.FuseIoctlIn{}
-		ioctlIn.ParseBinary(bcontent)
+		if err := ioctlIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = ioctlIn
 		req.Arg = &arg
 
@@ -637,7 +905,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 		// Poll event
 
 		var pollIn = kernel.FusePollIn{}
-		pollIn.ParseBinary(bcontent)
+		if err := pollIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = pollIn
 		req.Arg = &arg
 
@@ -651,7 +923,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 		// Fallocate event
 
 		var fallocateIn = kernel.FuseFallocateIn{}
-		fallocateIn.ParseBinary(bcontent)
+		if err := fallocateIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = fallocateIn
 		req.Arg = &arg
 
@@ -661,7 +937,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 		// Batch forget event
 
 		var batchForgetIn = kernel.FuseBatchForgetIn{}
-		batchForgetIn.ParseBinary(bcontent)
+		if err := batchForgetIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			noreply = true
+			break
+		}
 		arg = batchForgetIn
 		req.Arg = &arg
 
@@ -673,7 +953,11 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 		// readdirplus event
 
 		var readIn = kernel.FuseReadIn{}
-		readIn.ParseBinary(bcontent)
+		if err := readIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			errnum = errno.EINVAL
+			break
+		}
 		arg = readIn
 		req.Arg = &arg
 
@@ -685,17 +969,38 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 
 	case kernel.FuseOpInterrupt:
 		// interrupt event
+		var interruptIn = kernel.FuseInterruptIn{}
+		if err := interruptIn.ParseBinary(bcontent); err != nil {
+			logParseError(inHeader, err)
+			noreply = true
+			break
+		}
+		arg = interruptIn
+		req.Arg = &arg
 
 		doInterrupt(*req)
-		req.session.Close()
+
+		noreply = true
 
 	default:
-		panic(errors.New("未实现的操作！！！"))
+		// not implemented, the kernel will not send it again
+		log.Warning.Printf("Unknown opcode[%d] \n", inHeader.Opcode)
+		errnum = errno.ENOSYS
 	}
 
-	var bresp []byte
+	var bresp [][]byte
 	var err error
 
+	if req.isAsync() {
+		// the handler replies later by Reply
+		return nil, kernel.ErrNoNeedReply
+	}
+
+	if errnum == ReplyLater {
+		log.Error.Printf("Opcode[%d] unique[%d] returns ReplyLater without Async \n", inHeader.Opcode, inHeader.Unique)
+		errnum = errno.EIO
+	}
+
 	if noreply {
 		// means this request no need to reply
 		bresp = nil
@@ -709,14 +1014,14 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 		if errnum == errno.SUCCESS {
 
 			if req.session.Debug {
-				log.Trace.Printf("errnum[%d], outHeader[%+v], resp[%+v]", errnum, outHeader, resp)
+				log.Trace.Printf("errnum[%d %s], outHeader[%+v], resp[%+v]", errnum, errno.Name(errnum), outHeader, resp)
 			}
 
 			bresp, err = generateResp(outHeader, resp)
 		} else {
 
 			if req.session.Debug {
-				log.Trace.Printf("errnum[%d], outHeader[%+v]", errnum, outHeader)
+				log.Trace.Printf("errnum[%d %s], outHeader[%+v]", errnum, errno.Name(errnum), outHeader)
 			}
 
 			bresp, err = generateResp(outHeader, nil)
@@ -727,10 +1032,26 @@ func distribute(req *Req, inHeader kernel.FuseInHeader, bcontent []byte) ([]byte
 	return bresp, err
 }
 
-// Function to generate bytes response
-func generateResp(outHeader kernel.FuseOutHeader, resp kernel.FuseResponsor) ([]byte, error) {
+// Function to generate bytes response, the header and the data of resp.
+// The response of fixed size is encoded with the header in one buffer,
+// the others (and the pieces of kernel.FuseVecResponsor) are not copied,
+// they are written by writev.
+func generateResp(outHeader kernel.FuseOutHeader, resp kernel.FuseResponsor) ([][]byte, error) {
+
+	if fixed, ok := resp.(kernel.FuseFixedResponsor); ok {
 
-	buf := bytes.NewBuffer(nil)
+		bresp := make([]byte, kernel.OutHeaderLen+fixed.BinarySize())
+
+		outHeader.Len = uint32(len(bresp))
+		outHeader.MarshalTo(bresp)
+		fixed.MarshalTo(bresp[kernel.OutHeaderLen:])
+
+		return [][]byte{bresp}, nil
+	}
+
+	if vec, ok := resp.(kernel.FuseVecResponsor); ok {
+		return generateVecResp(outHeader, vec.Buffers()), nil
+	}
 
 	var bresp []byte
 	var err error
@@ -744,13 +1065,47 @@ func generateResp(outHeader kernel.FuseOutHeader, resp kernel.FuseResponsor) ([]
 
 	outHeader.Len = uint32(kernel.OutHeaderLen + len(bresp))
 
-	bheader, err := outHeader.ToBinary()
-	if err != nil {
-		return nil, err
+	bheader := make([]byte, kernel.OutHeaderLen)
+	outHeader.MarshalTo(bheader)
+
+	if len(bresp) == 0 {
+		return [][]byte{bheader}, nil
 	}
 
-	buf.Write(bheader)
-	buf.Write(bresp)
+	return [][]byte{bheader, bresp}, nil
+}
+
+// maxIovecs : the max number of iovec in a writev, as IOV_MAX
+const maxIovecs = 1024
+
+// generateVecResp : the header and the data in pieces as an iovec,
+// the pieces are not copied unless there are more than maxIovecs
+func generateVecResp(outHeader kernel.FuseOutHeader, bufs [][]byte) [][]byte {
+
+	frame := make([][]byte, 1, 1+len(bufs))
+	length := kernel.OutHeaderLen
+
+	for _, buf := range bufs {
+		if len(buf) > 0 {
+			frame = append(frame, buf)
+			length += len(buf)
+		}
+	}
+
+	if len(frame) > maxIovecs {
+		tail := bytes.Join(frame[maxIovecs-1:], nil)
+		frame = append(frame[:maxIovecs-1], tail)
+	}
+
+	outHeader.Len = uint32(length)
+
+	frame[0] = make([]byte, kernel.OutHeaderLen)
+	outHeader.MarshalTo(frame[0])
+
+	return frame
+}
 
-	return buf.Bytes(), nil
+// logParseError : the argument of request can not be parsed
+func logParseError(inHeader kernel.FuseInHeader, err error) {
+	log.Error.Printf("Parse opcode[%d] unique[%d] err: %s \n", inHeader.Opcode, inHeader.Unique, err)
 }
@@ -2,6 +2,7 @@ package fuse
 
 import (
 	"bytes"
+	"math"
 	"os"
 	"syscall"
 
@@ -25,6 +26,8 @@ func doInit(req Req, initOut *kernel.FuseInitOut) int32 {
 	se.connInfo.Minor = initIn.Minor
 	se.connInfo.MaxReadahead = initIn.MaxReadahead
 
+	initFlags := initIn.AllFlags()
+
 	if bufsize < kernel.FuseMinReadBuffer {
 		log.Warning.Printf("fuse: warning: buffer size too small: %d\n", bufsize)
 		bufsize = kernel.FuseMinReadBuffer
@@ -43,69 +46,72 @@ func doInit(req Req, initOut *kernel.FuseInitOut) int32 {
 	initOut.CongestionThreshold = se.connInfo.CongestionThreshold
 
 	// To remember what fuse kernel can do
-	if initIn.Flags&kernel.FuseAsyncRead > 0 {
+	if initFlags&kernel.FuseAsyncRead > 0 {
 		se.connInfo.Capable |= kernel.FuseAsyncRead
 	}
-	if initIn.Flags&kernel.FusePosixLocks > 0 {
+	if initFlags&kernel.FusePosixLocks > 0 {
 		se.connInfo.Capable |= kernel.FusePosixLocks
 	}
-	if initIn.Flags&kernel.FuseFileOps > 0 {
+	if initFlags&kernel.FuseFileOps > 0 {
 		se.connInfo.Capable |= kernel.FuseFileOps
 	}
-	if initIn.Flags&kernel.FuseAtomicOTrunc > 0 {
+	if initFlags&kernel.FuseAtomicOTrunc > 0 {
 		se.connInfo.Capable |= kernel.FuseAtomicOTrunc
 	}
-	if initIn.Flags&kernel.FuseExportSupport > 0 {
+	if initFlags&kernel.FuseExportSupport > 0 {
 		se.connInfo.Capable |= kernel.FuseExportSupport
 	}
-	if initIn.Flags&kernel.FuseBigWrites > 0 {
+	if initFlags&kernel.FuseBigWrites > 0 {
 		se.connInfo.Capable |= kernel.FuseBigWrites
 	}
-	if initIn.Flags&kernel.FuseDontMask > 0 {
+	if initFlags&kernel.FuseDontMask > 0 {
 		se.connInfo.Capable |= kernel.FuseDontMask
 	}
-	if initIn.Flags&kernel.FuseSpliceWrite > 0 {
+	if initFlags&kernel.FuseSpliceWrite > 0 {
 		se.connInfo.Capable |= kernel.FuseSpliceWrite
 	}
-	if initIn.Flags&kernel.FuseSpliceMove > 0 {
+	if initFlags&kernel.FuseSpliceMove > 0 {
 		se.connInfo.Capable |= kernel.FuseSpliceMove
 	}
-	if initIn.Flags&kernel.FuseSpliceRead > 0 {
+	if initFlags&kernel.FuseSpliceRead > 0 {
 		se.connInfo.Capable |= kernel.FuseSpliceRead
 	}
-	if initIn.Flags&kernel.FuseFlockLocks > 0 {
+	if initFlags&kernel.FuseFlockLocks > 0 {
 		se.connInfo.Capable |= kernel.FuseFlockLocks
 	}
-	if initIn.Flags&kernel.FuseHasIoCtlDir > 0 {
+	if initFlags&kernel.FuseHasIoCtlDir > 0 {
 		se.connInfo.Capable |= kernel.FuseHasIoCtlDir
 	}
-	if initIn.Flags&kernel.FuseAutoInvalData > 0 {
+	if initFlags&kernel.FuseAutoInvalData > 0 {
 		se.connInfo.Capable |= kernel.FuseAutoInvalData
 	}
-	if initIn.Flags&kernel.FuseDoReaddirplus > 0 {
+	if initFlags&kernel.FuseDoReaddirplus > 0 {
 		se.connInfo.Capable |= kernel.FuseDoReaddirplus
 	}
-	if initIn.Flags&kernel.FuseReaddirplusAuto > 0 {
+	if initFlags&kernel.FuseReaddirplusAuto > 0 {
 		se.connInfo.Capable |= kernel.FuseReaddirplusAuto
 	}
-	if initIn.Flags&kernel.FuseAsyncDio > 0 {
+	if initFlags&kernel.FuseAsyncDio > 0 {
 		se.connInfo.Capable |= kernel.FuseAsyncDio
 	}
-	if initIn.Flags&kernel.FuseWritebackCache > 0 {
+	if initFlags&kernel.FuseWritebackCache > 0 {
 		se.connInfo.Capable |= kernel.FuseWritebackCache
 	}
-	if initIn.Flags&kernel.FuseNoOpenSupport > 0 {
+	if initFlags&kernel.FuseNoOpenSupport > 0 {
 		se.connInfo.Capable |= kernel.FuseNoOpenSupport
 	}
-	if initIn.Flags&kernel.FuseParallelDirops > 0 {
+	if initFlags&kernel.FuseParallelDirops > 0 {
 		se.connInfo.Capable |= kernel.FuseParallelDirops
 	}
-	if initIn.Flags&kernel.FuseHandleKillPriv > 0 {
+	if initFlags&kernel.FuseHandleKillPriv > 0 {
 		se.connInfo.Capable |= kernel.FuseHandleKillPriv
 	}
-	if initIn.Flags&kernel.FuseCapPosixACL > 0 {
+	if initFlags&kernel.FuseCapPosixACL > 0 {
 		se.connInfo.Capable |= kernel.FuseCapPosixACL
 	}
+	if initFlags&kernel.FusePassthrough > 0 {
+		se.connInfo.Capable |= kernel.FusePassthrough
+	}
 
 	// Default settings for modern filesystems.
 	// TODO: support write_buf, flock
@@ -133,10 +139,29 @@ func doInit(req Req, initOut *kernel.FuseInitOut) int32 {
 	if se.Opts.Getlk != nil && se.Opts.Setlk != nil {
 		se.connInfo.Want |= FuseCapPosixLocks
 	}
-	if se.Opts.Readdirplus != nil {
+	if se.FuseConfig.WritebackCache && (se.connInfo.Capable&FuseCapWritebackCache) > 0 {
+		se.connInfo.Want |= FuseCapWritebackCache
+	}
+	if se.Opts.Flock != nil && (se.connInfo.Capable&FuseCapFlockLocks) > 0 {
+		se.connInfo.Want |= FuseCapFlockLocks
+	}
+	// without Readdirplus, the entries are built from Readdir and Lookup
+	if se.Opts.Readdirplus != nil || ((se.Opts.Readdir != nil || se.Opts.ReaddirStream != nil) && se.Opts.Lookup != nil) {
 		se.connInfo.Want |= FuseCapReaddirplus
 		se.connInfo.Want |= FuseCapReaddirplusAuto
 	}
+	if se.FuseConfig.ExportSupport && (se.connInfo.Capable&FuseCapExportSupport) > 0 {
+		se.connInfo.Want |= FuseCapExportSupport
+	}
+	if se.FuseConfig.PosixACL && (se.connInfo.Capable&FuseCapPosixACL) > 0 {
+		se.connInfo.Want |= FuseCapPosixACL
+	}
+	if se.FuseConfig.Passthrough && (se.connInfo.Capable&FuseCapPassthrough) > 0 {
+		se.connInfo.Want |= FuseCapPassthrough
+		if se.connInfo.MaxStackDepth == 0 {
+			se.connInfo.MaxStackDepth = 1
+		}
+	}
 
 	if se.Opts != nil && se.Opts.Init != nil {
 		userdata := (*se.Opts.Init)(se.connInfo)
@@ -209,6 +234,71 @@ func doInit(req Req, initOut *kernel.FuseInitOut) int32 {
 
 		initOut.Flags |= kernel.FuseCapPosixACL
 	}
+	if se.connInfo.Want&kernel.FusePassthrough > 0 {
+		initOut.MaxStackDepth = se.connInfo.MaxStackDepth
+	}
+
+	// the flags over 32 bits are sent in Flags2
+	if (se.connInfo.Want>>32) > 0 && (initIn.Flags&kernel.FuseInitExt) > 0 {
+		initOut.Flags |= kernel.FuseInitExt
+		initOut.Flags2 = uint32(se.connInfo.Want >> 32)
+	}
+
+	return errno.SUCCESS
+}
+
+func doCuseInit(req Req, cuseInitOut *kernel.CuseInitResp) int32 {
+
+	se := req.session
+	cuseInitIn := (*req.Arg).(kernel.CuseInitIn)
+	if se.Debug {
+		log.Trace.Printf("CUSE INIT: %+v \n", cuseInitIn)
+	}
+
+	if se.cuseInfo == nil {
+		log.Error.Println("cuse: device info not set")
+		return errno.ENODEV
+	}
+
+	bufsize := se.bufsize
+
+	se.connInfo.Major = cuseInitIn.Major
+	se.connInfo.Minor = cuseInitIn.Minor
+
+	if bufsize < kernel.FuseMinReadBuffer {
+		log.Warning.Printf("cuse: warning: buffer size too small: %d\n", bufsize)
+		bufsize = kernel.FuseMinReadBuffer
+	}
+
+	bufsize -= 4096
+	if se.connInfo.MaxWrite == 0 || uint32(bufsize) < se.connInfo.MaxWrite {
+		se.connInfo.MaxWrite = uint32(bufsize)
+	}
+
+	if se.Opts != nil && se.Opts.Init != nil {
+		userdata := (*se.Opts.Init)(se.connInfo)
+		se.userdata = userdata
+	}
+
+	devinfo := "DEVNAME=" + se.cuseInfo.DevName
+	if len(devinfo) >= kernel.CuseInitInfoMax {
+		log.Error.Printf("cuse: device info too long: %s\n", devinfo)
+		return errno.EINVAL
+	}
+
+	out := &cuseInitOut.Out
+	out.Major = kernel.FuseKernelVersion
+	out.Minor = kernel.FuseKernelMinorVersion
+	out.Flags = se.cuseInfo.Flags & kernel.CuseUnrestrictedIoctl
+	out.MaxRead = se.cuseInfo.MaxRead
+	if out.MaxRead == 0 {
+		out.MaxRead = 131072
+	}
+	out.MaxWrite = se.connInfo.MaxWrite
+	out.DevMajor = se.cuseInfo.DevMajor
+	out.DevMinor = se.cuseInfo.DevMinor
+
+	cuseInitOut.DevInfo = devinfo
 
 	return errno.SUCCESS
 }
@@ -236,20 +326,48 @@ func doLookup(req Req, nodeid uint64, entryOut *kernel.FuseEntryOut) int32 {
 		log.Trace.Printf("Lookup: %+v \n", lookupIn)
 	}
 
-	if se.Opts != nil && se.Opts.Lookup != nil {
+	if se.Opts == nil {
+		return res
+	}
+
+	// "." and ".." are only looked up with export support, e.g. by nfsd,
+	// the kernel counts the reply as a lookup and sends FORGET later
+	if lookupIn.Name == "." || lookupIn.Name == ".." {
+		var fsStat *FileStat
+
+		if lookupIn.Name == ".." && se.Opts.GetParent != nil {
+			fsStat, res = (*se.Opts.GetParent)(req, nodeid)
+		} else if se.Opts.Lookup != nil {
+			fsStat, res = (*se.Opts.Lookup)(req, nodeid, lookupIn.Name)
+		}
+
+		if res == errno.SUCCESS {
+			if fsStat == nil || fsStat.Nodeid == 0 {
+				return errno.ENOENT
+			}
+			setEntryOut(se, entryOut, fsStat)
+		}
+
+		return res
+	}
+
+	if se.Opts.Lookup != nil {
 
 		var fsStat *FileStat
 
 		fsStat, res = (*se.Opts.Lookup)(req, nodeid, lookupIn.Name)
 
+		if res == errno.ENOENT && se.FuseConfig.NegativeTimeout > 0 {
+			// let the kernel cache that the name does not exist
+			fsStat = &FileStat{}
+			res = errno.SUCCESS
+		}
+
 		if res == errno.SUCCESS {
-			entryOut.NodeID = fsStat.Nodeid
-			entryOut.Generation = fsStat.Generation
-			entryOut.AttrValid = common.CalcTimeoutSec(se.FuseConfig.AttrTimeout)
-			entryOut.AttrValidNsec = common.CalcTimeoutNsec(se.FuseConfig.AttrTimeout)
-			entryOut.EntryValid = common.CalcTimeoutSec(se.FuseConfig.AttrTimeout)
-			entryOut.EntryValidNsec = common.CalcTimeoutNsec(se.FuseConfig.AttrTimeout)
-			setFuseAttr(&entryOut.Attr, fsStat.Stat)
+			if fsStat == nil {
+				return errno.ENOENT
+			}
+			setEntryOut(se, entryOut, fsStat)
 		}
 
 	}
@@ -286,12 +404,62 @@ func doGetattr(req Req, nodeid uint64, attrOut *kernel.FuseAttrOut) int32 {
 		fsStat, res = (*se.Opts.Getattr)(req, nodeid)
 
 		if res == errno.SUCCESS {
-			attrOut.AttrValid = common.CalcTimeoutSec(se.FuseConfig.AttrTimeout)
-			attrOut.AttrValidNsec = common.CalcTimeoutNsec(se.FuseConfig.AttrTimeout)
 			attrOut.Dummp = getattrIn.Dummy
-			setFuseAttr(&attrOut.Attr, fsStat.Stat)
+			setAttrOut(se, attrOut, fsStat)
+		}
+
+	}
+
+	return res
+}
+
+func doStatx(req Req, nodeid uint64, statxOut *kernel.FuseStatxOut) int32 {
+
+	statxIn := (*req.Arg).(kernel.FuseStatxIn)
+	se := req.session
+	var res int32 = errno.ENOSYS
+
+	if se.Debug {
+		log.Trace.Printf("Statx: %+v \n", statxIn)
+	}
+
+	if se.Opts == nil {
+		return res
+	}
+
+	var attr Attr
+
+	if se.Opts.Statx != nil {
+
+		fi := NewFuseFileInfo()
+		if (statxIn.GetattrFlags & kernel.FuseGetattrFh) != 0 {
+			fi.Fh = statxIn.Fh
+		}
+
+		var pattr *Attr
+		pattr, res = (*se.Opts.Statx)(req, nodeid, statxIn.SxFlags, statxIn.SxMask, fi)
+		if res == errno.SUCCESS && pattr == nil {
+			res = errno.EIO
+		}
+		if res == errno.SUCCESS {
+			attr = *pattr
 		}
 
+	} else if se.Opts.Getattr != nil {
+
+		// no birth time and file attributes without Statx
+		var fsStat *FileStat
+		fsStat, res = (*se.Opts.Getattr)(req, nodeid)
+		if res == errno.SUCCESS && fsStat == nil {
+			res = errno.EIO
+		}
+		if res == errno.SUCCESS {
+			attr = AttrFromStat(*fsStat)
+		}
+	}
+
+	if res == errno.SUCCESS {
+		setStatxOut(se, statxOut, attr)
 	}
 
 	return res
@@ -315,9 +483,18 @@ func doSetattr(req Req, nodeid uint64, attrOut *kernel.FuseAttrOut) int32 {
 		res = (*se.Opts.Setattr)(req, nodeid, fsStat, setattrIn.Valid)
 
 		if res == errno.SUCCESS {
-			attrOut.AttrValid = common.CalcTimeoutSec(se.FuseConfig.AttrTimeout)
-			attrOut.AttrValidNsec = common.CalcTimeoutNsec(se.FuseConfig.AttrTimeout)
-			setFuseAttr(&attrOut.Attr, fsStat.Stat)
+
+			// the kernel of writeback cache keeps the attributes replied,
+			// so reply all of them, not only the changed ones
+			if (se.connInfo.Want&FuseCapWritebackCache) > 0 && se.Opts.Getattr != nil {
+				newStat, getattrRes := (*se.Opts.Getattr)(req, nodeid)
+				if getattrRes == errno.SUCCESS && newStat != nil {
+					fsStat = *newStat
+					kernelOwnedAttr(setattrIn, &fsStat.Stat)
+				}
+			}
+
+			setAttrOut(se, attrOut, &fsStat)
 		}
 
 	}
@@ -359,6 +536,8 @@ func doMknod(req Req, nodeid uint64, entryOut *kernel.FuseEntryOut) int32 {
 		log.Trace.Printf("Mknod: %+v \n", mknodIn)
 	}
 
+	req.Umask = mknodIn.Umask
+
 	if se.Opts != nil && se.Opts.Mknod != nil {
 
 		var stat *FileStat
@@ -366,13 +545,7 @@ func doMknod(req Req, nodeid uint64, entryOut *kernel.FuseEntryOut) int32 {
 		stat, res = (*se.Opts.Mknod)(req, nodeid, mknodIn.Name, mknodIn.Mode, mknodIn.Rdev)
 
 		if res == errno.SUCCESS {
-			entryOut.NodeID = stat.Nodeid
-			entryOut.Generation = stat.Generation
-			entryOut.AttrValid = common.CalcTimeoutSec(se.FuseConfig.AttrTimeout)
-			entryOut.AttrValidNsec = common.CalcTimeoutNsec(se.FuseConfig.AttrTimeout)
-			entryOut.EntryValid = common.CalcTimeoutSec(se.FuseConfig.AttrTimeout)
-			entryOut.EntryValidNsec = common.CalcTimeoutNsec(se.FuseConfig.AttrTimeout)
-			setFuseAttr(&entryOut.Attr, stat.Stat)
+			setEntryOut(se, entryOut, stat)
 		}
 
 	}
@@ -390,6 +563,8 @@ func doMkdir(req Req, nodeid uint64, entryOut *kernel.FuseEntryOut) int32 {
 		log.Trace.Printf("Mkdir: %+v \n", mkdirIn)
 	}
 
+	req.Umask = mkdirIn.Umask
+
 	if se.Opts != nil && se.Opts.Mkdir != nil {
 
 		var stat *FileStat
@@ -397,13 +572,7 @@ func doMkdir(req Req, nodeid uint64, entryOut *kernel.FuseEntryOut) int32 {
 		stat, res = (*se.Opts.Mkdir)(req, nodeid, mkdirIn.Name, mkdirIn.Mode)
 
 		if res == errno.SUCCESS {
-			entryOut.NodeID = stat.Nodeid
-			entryOut.Generation = stat.Generation
-			entryOut.AttrValid = common.CalcTimeoutSec(se.FuseConfig.AttrTimeout)
-			entryOut.AttrValidNsec = common.CalcTimeoutNsec(se.FuseConfig.AttrTimeout)
-			entryOut.EntryValid = common.CalcTimeoutSec(se.FuseConfig.AttrTimeout)
-			entryOut.EntryValidNsec = common.CalcTimeoutNsec(se.FuseConfig.AttrTimeout)
-			setFuseAttr(&entryOut.Attr, stat.Stat)
+			setEntryOut(se, entryOut, stat)
 		}
 
 	}
@@ -466,13 +635,7 @@ func doSymlink(req Req, nodeid uint64, entryOut *kernel.FuseEntryOut) int32 {
 		stat, res = (*se.Opts.Symlink)(req, nodeid, symlinkIn.LinkName, symlinkIn.Name)
 
 		if res == errno.SUCCESS {
-			entryOut.NodeID = stat.Nodeid
-			entryOut.Generation = stat.Generation
-			entryOut.AttrValid = common.CalcTimeoutSec(se.FuseConfig.AttrTimeout)
-			entryOut.AttrValidNsec = common.CalcTimeoutNsec(se.FuseConfig.AttrTimeout)
-			entryOut.EntryValid = common.CalcTimeoutSec(se.FuseConfig.AttrTimeout)
-			entryOut.EntryValidNsec = common.CalcTimeoutNsec(se.FuseConfig.AttrTimeout)
-			setFuseAttr(&entryOut.Attr, stat.Stat)
+			setEntryOut(se, entryOut, stat)
 		}
 
 	}
@@ -535,13 +698,7 @@ func doLink(req Req, nodeid uint64, entryOut *kernel.FuseEntryOut) int32 {
 		stat, res = (*se.Opts.Link)(req, linklIn.OldNodeid, nodeid, linklIn.NewName)
 
 		if res == errno.SUCCESS {
-			entryOut.NodeID = stat.Nodeid
-			entryOut.Generation = stat.Generation
-			entryOut.AttrValid = common.CalcTimeoutSec(se.FuseConfig.AttrTimeout)
-			entryOut.AttrValidNsec = common.CalcTimeoutNsec(se.FuseConfig.AttrTimeout)
-			entryOut.EntryValid = common.CalcTimeoutSec(se.FuseConfig.AttrTimeout)
-			entryOut.EntryValidNsec = common.CalcTimeoutNsec(se.FuseConfig.AttrTimeout)
-			setFuseAttr(&entryOut.Attr, stat.Stat)
+			setEntryOut(se, entryOut, stat)
 		}
 
 	}
@@ -560,7 +717,7 @@ func doOpen(req Req, nodeid uint64, openOut *kernel.FuseOpenOut) int32 {
 	}
 
 	fi := NewFuseFileInfo()
-	fi.Flags = openIn.Flags
+	fi.Flags = writebackOpenFlags(se, openIn.Flags)
 
 	if se.Opts != nil && se.Opts.Open != nil {
 
@@ -569,6 +726,10 @@ func doOpen(req Req, nodeid uint64, openOut *kernel.FuseOpenOut) int32 {
 
 	setOpenOut(openOut, fi)
 
+	if res == errno.SUCCESS {
+		setPassthrough(se, nodeid, openOut, fi)
+	}
+
 	return res
 }
 
@@ -582,16 +743,31 @@ func doRead(req Req, nodeid uint64, readOut *kernel.FuseReadOut) int32 {
 		log.Trace.Printf("Read: %v \n", readIn)
 	}
 
-	if se.Opts != nil && se.Opts.Read != nil {
+	if se.Opts != nil && (se.Opts.Read != nil || se.Opts.ReadVec != nil) {
 
 		fi := NewFuseFileInfo()
 
 		fi.Fh = readIn.Fh
 		if req.session.connInfo.Minor >= 9 {
-			fi.LockOwner = readIn.LockOwner
+			if (readIn.ReadFlags & kernel.FuseReadLockowner) > 0 {
+				fi.LockOwner = readIn.LockOwner
+			}
 			fi.Flags = readIn.Flags
 		}
 
+		if se.Opts.ReadVec != nil {
+
+			var vec [][]byte
+
+			vec, res = (*se.Opts.ReadVec)(req, nodeid, readIn.Size, readIn.Offset, fi)
+
+			if res == errno.SUCCESS {
+				readOut.Vec = vec
+			}
+
+			return res
+		}
+
 		var buf []byte
 
 		buf, res = (*se.Opts.Read)(req, nodeid, readIn.Size, readIn.Offset, fi)
@@ -621,12 +797,13 @@ func doWrite(req Req, nodeid uint64, writeOut *kernel.FuseWriteOut) int32 {
 
 		fi.Fh = writeIn.Fh
 
-		if writeIn.WriteFlags&1 != 0 {
-			fi.Writepage = 1
-		}
+		// a delayed write from the page cache, fi.Fh maybe any handle of the file
+		fi.Writepage = (writeIn.WriteFlags & kernel.FuseWriteCache) > 0
 
 		if req.session.connInfo.Minor >= 9 {
-			fi.LockOwner = writeIn.LockOwner
+			if (writeIn.WriteFlags & kernel.FuseWriteLockowner) > 0 {
+				fi.LockOwner = writeIn.LockOwner
+			}
 			fi.Flags = writeIn.Flags
 		}
 
@@ -653,7 +830,7 @@ func doFlush(req Req, nodeid uint64) int32 {
 		fi := NewFuseFileInfo()
 
 		fi.Fh = flushIn.Fh
-		fi.Flush = 1
+		fi.Flush = true
 
 		if req.session.connInfo.Minor >= 9 {
 			fi.LockOwner = flushIn.LockOwner
@@ -723,49 +900,114 @@ func doReaddir(req Req, nodeid uint64, readOut *kernel.FuseReadOut) int32 {
 		log.Trace.Printf("Readdir: %+v \n", readIn)
 	}
 
-	if se.Opts != nil && se.Opts.Readdir != nil {
-
-		fi := NewFuseFileInfo()
-		fi.Fh = readIn.Fh
+	if se.Opts == nil {
+		return res
+	}
 
-		var dirList []Dirent
+	fi := NewFuseFileInfo()
+	fi.Fh = readIn.Fh
 
-		dirList, res = (*se.Opts.Readdir)(req, nodeid, readIn.Size, readIn.Offset, fi)
+	if se.Opts.Readdir == nil && se.Opts.ReaddirStream == nil && se.Opts.Readdirplus != nil {
+		// only readdirplus implemented, the kernel does not count lookups of readdir
+		var direntList []DirentPlus
+		direntList, res = (*se.Opts.Readdirplus)(req, nodeid, readIn.Size, readIn.Offset, fi)
 
 		if res == errno.SUCCESS {
 
 			buf := bytes.NewBuffer(nil)
+			full := false
 
-			if dirList != nil && len(dirList) > 0 {
+			for i, val := range direntList {
 
-				var preOff uint64
+				forgetDirentPlus(req, val)
 
-				for _, val := range dirList {
+				if full {
+					continue
+				}
 
-					dir := val
+				dirent := toFuseDirent(val.Dirent, readIn.Offset, i)
+				if uint64(buf.Len())+kernel.DirentSize(dirent.NameLen) > uint64(readIn.Size) {
+					full = true
+					continue
+				}
 
-					dirent := kernel.FuseDirent(dir)
+				dirb, _ := dirent.ToBinary()
+				buf.Write(dirb)
+			}
 
-					dirb, _ := dirent.ToBinary(&preOff)
-					// 判断是否超过readIn.Size的大小限制
-					if uint32(buf.Len()+len(dirb)) < readIn.Size {
-						buf.Write(dirb)
-					} else {
-						break
-					}
+			readOut.Content = buf.Bytes()
+		}
 
-				}
+		return res
+	}
+
+	var stream DirStream
+
+	stream, res = openDirStream(req, nodeid, readIn.Size, readIn.Offset, fi)
 
+	if res != errno.SUCCESS || stream == nil {
+		return res
+	}
+	defer stream.Close()
+
+	buf := bytes.NewBuffer(nil)
+
+	for i := 0; stream.HasNext(); i++ {
+
+		val, nextRes := stream.Next()
+		if nextRes != errno.SUCCESS {
+			if buf.Len() == 0 {
+				return nextRes
 			}
+			break
+		}
 
-			readOut.Content = buf.Bytes()
+		dirent := toFuseDirent(val, readIn.Offset, i)
+
+		// 判断是否超过readIn.Size的大小限制, 没发送的从下一个offset继续
+		if uint64(buf.Len())+kernel.DirentSize(dirent.NameLen) > uint64(readIn.Size) {
+			break
 		}
 
+		dirb, _ := dirent.ToBinary()
+		buf.Write(dirb)
 	}
 
+	readOut.Content = buf.Bytes()
+
 	return res
 }
 
+// openDirStream : the directory stream from Opt.ReaddirStream or Opt.Readdir
+func openDirStream(req Req, nodeid uint64, size uint32, offset uint64, fi FileInfo) (DirStream, int32) {
+
+	se := req.session
+
+	if se.Opts.ReaddirStream != nil {
+		return (*se.Opts.ReaddirStream)(req, nodeid, offset, fi)
+	}
+
+	if se.Opts.Readdir != nil {
+		dirList, res := (*se.Opts.Readdir)(req, nodeid, size, offset, fi)
+		if res != errno.SUCCESS {
+			return nil, res
+		}
+		return newSliceDirStream(dirList), res
+	}
+
+	return nil, errno.ENOSYS
+}
+
+// toFuseDirent : convert the index-th entry read from offset, with its cookie
+func toFuseDirent(dirent Dirent, offset uint64, index int) kernel.FuseDirent {
+
+	fuseDirent := kernel.FuseDirent(dirent)
+	fuseDirent.NameLen = uint32(len(dirent.Name))
+	fuseDirent.Off = direntCookie(dirent, offset, index)
+
+	return fuseDirent
+}
+
 func doRelease(req Req, nodeid uint64) int32 {
 
 	releaseIn := (*req.Arg).(kernel.FuseReleaseIn)
@@ -776,16 +1018,34 @@ func doRelease(req Req, nodeid uint64) int32 {
 		log.Trace.Printf("Release: %+v \n", releaseIn)
 	}
 
-	if se.Opts != nil && se.Opts.Release != nil {
+	if se.Opts == nil {
+		return res
+	}
 
-		fi := NewFuseFileInfo()
-		fi.Flags = releaseIn.Flags
-		fi.Fh = releaseIn.Fh
+	fi := NewFuseFileInfo()
+	fi.Flags = releaseIn.Flags
+	fi.Fh = releaseIn.Fh
+	fi.Flush = (releaseIn.ReleaseFlags & kernel.FuseReleaseFlush) > 0
+
+	if (releaseIn.ReleaseFlags & kernel.FuseReleaseFlockUnlock) > 0 {
+		// the last close of the file, drop the BSD locks of the owner
+		fi.FlockRelease = true
+		fi.LockOwner = releaseIn.LockOwner
+
+		if se.Opts.Flock != nil {
+			flockRes := (*se.Opts.Flock)(req, nodeid, fi, syscall.LOCK_UN)
+			if flockRes != errno.SUCCESS && se.Debug {
+				log.Trace.Printf("Release: unlock flock of owner[%d] failed: %d \n", fi.LockOwner, flockRes)
+			}
+		}
+	}
 
+	if se.Opts.Release != nil {
 		res = (*se.Opts.Release)(req, nodeid, fi)
-
 	}
 
+	releaseBacking(se, nodeid, fi.Fh)
+
 	return res
 }
 
@@ -804,6 +1064,7 @@ func doReleasedir(req Req, nodeid uint64) int32 {
 		fi := NewFuseFileInfo()
 		fi.Flags = releasedirIn.Flags
 		fi.Fh = releasedirIn.Fh
+		fi.Flush = (releasedirIn.ReleaseFlags & kernel.FuseReleaseFlush) > 0
 
 		res = (*se.Opts.Releasedir)(req, nodeid, fi)
 
@@ -856,12 +1117,7 @@ func doStatfs(req Req, nodeid uint64, statfsOut *kernel.FuseStatfsOut) int32 {
 		}
 
 	} else {
-		statfs := kernel.FuseStatfs{}
-		statfs.NameLen = 255
-		statfs.Bsize = 512
-
-		statfsOut.St = statfs
-
+		res = defaultStatfs(se, &statfsOut.St)
 	}
 
 	return res
@@ -877,16 +1133,20 @@ func doSetxattr(req Req, nodeid uint64) int32 {
 		log.Trace.Printf("Setxattr: %+v \n", setxattrIn)
 	}
 
-	if se.Opts != nil && se.Opts.Setxattr != nil {
+	if se.Opts != nil && se.Opts.SetxattrBytes != nil {
 
-		res = (*se.Opts.Setxattr)(req, nodeid, setxattrIn.Name, setxattrIn.Value, setxattrIn.Flags)
+		res = (*se.Opts.SetxattrBytes)(req, nodeid, setxattrIn.Name, setxattrIn.Value, setxattrIn.Flags)
+
+	} else if se.Opts != nil && se.Opts.Setxattr != nil {
+
+		res = (*se.Opts.Setxattr)(req, nodeid, setxattrIn.Name, string(setxattrIn.Value), setxattrIn.Flags)
 
 	}
 
 	return res
 }
 
-func doGetxattr(req Req, nodeid uint64, getxattrOut *kernel.FuseGetxattrOut) int32 {
+func doGetxattr(req Req, nodeid uint64, getxattrOut *kernel.FuseGetxattrOut, valueOut *kernel.XattrVal) int32 {
 
 	getxattrIn := (*req.Arg).(kernel.FuseGetxattrIn)
 	se := req.session
@@ -896,21 +1156,28 @@ func doGetxattr(req Req, nodeid uint64, getxattrOut *kernel.FuseGetxattrOut) int
 		log.Trace.Printf("Getxattr: %+v \n", getxattrIn)
 	}
 
-	if se.Opts != nil && se.Opts.Getxattr != nil {
+	if se.Opts == nil {
+		return res
+	}
 
-		var value string
-		value, res = (*se.Opts.Getxattr)(req, nodeid, getxattrIn.Name, getxattrIn.Size)
+	var value []byte
 
-		size := len(value)
-		getxattrOut.Size = uint32(size)
-		getxattrOut.Value = kernel.XattrVal(value)
+	if se.Opts.GetxattrBytes != nil {
+		value, res = (*se.Opts.GetxattrBytes)(req, nodeid, getxattrIn.Name, getxattrIn.Size)
+	} else if se.Opts.Getxattr != nil {
+		var str string
+		str, res = (*se.Opts.Getxattr)(req, nodeid, getxattrIn.Name, getxattrIn.Size)
+		value = []byte(str)
+	}
 
+	if res == errno.SUCCESS {
+		res = setXattrReply(getxattrIn.Size, value, getxattrOut, valueOut)
 	}
 
 	return res
 }
 
-func doListxattr(req Req, nodeid uint64, listxattrOut *kernel.FuseGetxattrOut) int32 {
+func doListxattr(req Req, nodeid uint64, listxattrOut *kernel.FuseGetxattrOut, listOut *kernel.XattrVal) int32 {
 
 	listxattrIn := (*req.Arg).(kernel.FuseGetxattrIn)
 	se := req.session
@@ -920,24 +1187,51 @@ func doListxattr(req Req, nodeid uint64, listxattrOut *kernel.FuseGetxattrOut) i
 		log.Trace.Printf("Listxattr: %+v \n", listxattrIn)
 	}
 
-	if se.Opts != nil && se.Opts.Listxattr != nil {
+	if se.Opts == nil {
+		return res
+	}
 
-		var attrlist string
-		attrlist, res = (*se.Opts.Listxattr)(req, nodeid, listxattrIn.Size)
+	var list []byte
 
-		size := len(attrlist)
-		if listxattrIn.Size == 0 {
-			listxattrOut.Size = uint32(size)
-		} else {
-			listxattrOut.Size = uint32(size)
-			listxattrOut.Value = kernel.XattrVal(attrlist)
+	if se.Opts.ListxattrNames != nil {
+		var names []string
+		names, res = (*se.Opts.ListxattrNames)(req, nodeid, listxattrIn.Size)
+
+		for _, name := range names {
+			list = append(list, name...)
+			list = append(list, 0)
 		}
+	} else if se.Opts.Listxattr != nil {
+		var str string
+		str, res = (*se.Opts.Listxattr)(req, nodeid, listxattrIn.Size)
+		list = []byte(str)
+	}
 
+	if res == errno.SUCCESS {
+		res = setXattrReply(listxattrIn.Size, list, listxattrOut, listOut)
 	}
 
 	return res
 }
 
+// setXattrReply : reply the size of value to the probe of size 0,
+// otherwise the value, or errno.ERANGE if it is larger than size
+func setXattrReply(size uint32, value []byte, sizeOut *kernel.FuseGetxattrOut, valueOut *kernel.XattrVal) int32 {
+
+	if size == 0 {
+		sizeOut.Size = uint32(len(value))
+		return errno.SUCCESS
+	}
+
+	if uint64(len(value)) > uint64(size) {
+		return errno.ERANGE
+	}
+
+	*valueOut = kernel.XattrVal(value)
+
+	return errno.SUCCESS
+}
+
 func doRemovexattr(req Req, nodeid uint64) int32 {
 
 	removexattrIn := (*req.Arg).(kernel.FuseRemovexattrIn)
@@ -986,10 +1280,12 @@ func doCreate(req Req, nodeid uint64, createOut *kernel.FuseCreateOut) int32 {
 		log.Trace.Printf("Create: %+v \n", createIn)
 	}
 
+	req.Umask = createIn.Umask
+
 	if se.Opts != nil && se.Opts.Create != nil {
 
 		fi := NewFuseFileInfo()
-		fi.Flags = createIn.Flags
+		fi.Flags = writebackOpenFlags(se, createIn.Flags)
 
 		var stat *FileStat
 
@@ -997,15 +1293,10 @@ func doCreate(req Req, nodeid uint64, createOut *kernel.FuseCreateOut) int32 {
 
 		if res == errno.SUCCESS {
 
-			createOut.Entry.NodeID = stat.Nodeid
-			createOut.Entry.Generation = stat.Generation
-			createOut.Entry.AttrValid = common.CalcTimeoutSec(se.FuseConfig.AttrTimeout)
-			createOut.Entry.AttrValidNsec = common.CalcTimeoutNsec(se.FuseConfig.AttrTimeout)
-			createOut.Entry.EntryValid = common.CalcTimeoutSec(se.FuseConfig.AttrTimeout)
-			createOut.Entry.EntryValidNsec = common.CalcTimeoutNsec(se.FuseConfig.AttrTimeout)
-			setFuseAttr(&createOut.Entry.Attr, stat.Stat)
+			setEntryOut(se, &createOut.Entry, stat)
 
 			setOpenOut(&createOut.Open, fi)
+			setPassthrough(se, stat.Nodeid, &createOut.Open, fi)
 		}
 
 	}
@@ -1059,39 +1350,43 @@ func doSetlkCommon(req Req, nodeid uint64, lksleep int) int32 {
 		log.Trace.Printf("Setlk: %+v \n", setlkIn)
 	}
 
-	if se.Opts != nil && se.Opts.Setlk != nil {
+	if se.Opts == nil {
+		return res
+	}
 
-		fi := NewFuseFileInfo()
-		var flock = Flock{}
+	fi := NewFuseFileInfo()
+	fi.Fh = setlkIn.Fh
+	fi.LockOwner = setlkIn.Owner
 
-		fi.Fh = setlkIn.Fh
-		fi.LockOwner = setlkIn.Owner
+	if (setlkIn.LkFlags & kernel.FuseLkFlock) > 0 {
 
-		if (setlkIn.LkFlags & kernel.FuseLkFlock) > 0 {
+		if se.Opts.Flock == nil {
+			return res
+		}
 
-			op := 0
+		op := 0
 
-			switch setlkIn.Lk.Type {
-			case syscall.F_RDLCK:
-				op = syscall.LOCK_SH
-			case syscall.F_WRLCK:
-				op = syscall.LOCK_EX
-			case syscall.F_UNLCK:
-				op = syscall.LOCK_UN
-			}
+		switch setlkIn.Lk.Type {
+		case syscall.F_RDLCK:
+			op = syscall.LOCK_SH
+		case syscall.F_WRLCK:
+			op = syscall.LOCK_EX
+		case syscall.F_UNLCK:
+			op = syscall.LOCK_UN
+		}
 
-			if lksleep == 0 {
-				op |= syscall.LOCK_NB
-			}
+		if lksleep == 0 {
+			op |= syscall.LOCK_NB
+		}
 
-			// TODO: flock
+		res = (*se.Opts.Flock)(req, nodeid, fi, op)
 
-		} else {
-			convertFuseFileLock(setlkIn.Lk, &flock)
+	} else if se.Opts.Setlk != nil {
 
-			res = (*se.Opts.Setlk)(req, nodeid, fi, flock, lksleep)
-		}
+		var flock = Flock{}
+		convertFuseFileLock(setlkIn.Lk, &flock)
 
+		res = (*se.Opts.Setlk)(req, nodeid, fi, flock, lksleep)
 	}
 
 	return res
@@ -1133,20 +1428,63 @@ func doIoctl(req Req, nodeid uint64, ioctlOut *kernel.FuseIoctlOut) int32 {
 	flags := ioctlIn.Flags
 
 	if (flags&kernel.FuseIoctlDir) > 0 && (se.connInfo.Want&FuseCapIoctlDir) == 0 {
-		return errno.ENOSYS
+		return errno.ENOTTY
 	}
 
 	if se.Opts != nil && se.Opts.Ioctl != nil {
 
 		fi := NewFuseFileInfo()
+		fi.Fh = ioctlIn.Fh
 
 		var ioctl *Ioctl
-		ioctl, res = (*se.Opts.Ioctl)(req, nodeid, ioctlIn.Cmd, ioctlIn.Arg, fi, ioctlIn.InBuf, ioctlIn.OutSize)
+		ioctl, res = (*se.Opts.Ioctl)(req, nodeid, ioctlIn.Cmd, ioctlIn.Arg, fi, flags, ioctlIn.InBuf, ioctlIn.OutSize)
+
+		if res != errno.SUCCESS || ioctl == nil {
+			return res
+		}
+
+		if ioctl.Retry {
+
+			if (flags & kernel.FuseIoctlUnrestricted) == 0 {
+				log.Error.Printf("Ioctl: retry is only allowed for unrestricted ioctl, cmd[%d] \n", ioctlIn.Cmd)
+				return errno.EIO
+			}
+
+			if len(ioctl.InIovs)+len(ioctl.OutIovs) > kernel.FuseIoctlMaxIov {
+				log.Error.Printf("Ioctl: too many iovecs, in[%d] out[%d] \n", len(ioctl.InIovs), len(ioctl.OutIovs))
+				return errno.EIO
+			}
+
+			// the kernel rejects the iovecs out of the 32bit address space of a compat caller
+			if (flags & kernel.FuseIoctlCompat) > 0 {
+				if !compatIovecs(ioctl.InIovs) || !compatIovecs(ioctl.OutIovs) {
+					log.Error.Printf("Ioctl: iovec out of 32bit address space for compat cmd[%d] \n", ioctlIn.Cmd)
+					return errno.EIO
+				}
+			}
+
+			ioctlOut.Flags = kernel.FuseIoctlRetry
+			ioctlOut.InIovs = uint32(len(ioctl.InIovs))
+			ioctlOut.OutIovs = uint32(len(ioctl.OutIovs))
+			ioctlOut.InIovecs = make([]kernel.FuseIoctlIovec, len(ioctl.InIovs))
+			for i, iov := range ioctl.InIovs {
+				ioctlOut.InIovecs[i] = kernel.FuseIoctlIovec(iov)
+			}
+			ioctlOut.OutIovecs = make([]kernel.FuseIoctlIovec, len(ioctl.OutIovs))
+			for i, iov := range ioctl.OutIovs {
+				ioctlOut.OutIovecs[i] = kernel.FuseIoctlIovec(iov)
+			}
+
+			return res
+		}
+
+		if uint32(len(ioctl.OutBuf)) > ioctlIn.OutSize {
+			log.Error.Printf("Ioctl: output data [%d] larger than outbufsz [%d] \n", len(ioctl.OutBuf), ioctlIn.OutSize)
+			return errno.EIO
+		}
 
 		ioctlOut.Result = ioctl.Result
-		ioctlOut.Flags = ioctl.Flags
-		ioctlOut.InIovs = ioctl.InIovs
-		ioctlOut.OutIovs = ioctl.OutIovs
+		ioctlOut.Buf = ioctl.OutBuf
 
 	}
 
@@ -1176,7 +1514,7 @@ func doPoll(req Req, nodeid uint64, pollOut *kernel.FusePollOut) int32 {
 			ph = &Pollhandle{}
 
 			ph.Kh = pollIn.Kh
-			ph.Se = *se
+			ph.Se = se
 		}
 
 		var revents uint32
@@ -1248,36 +1586,231 @@ func doReaddirplus(req Req, nodeid uint64, readOut *kernel.FuseReadOut) int32 {
 		log.Trace.Printf("Readdirplus: %+v \n", readIn)
 	}
 
-	if se.Opts != nil && se.Opts.Readdirplus != nil {
+	if se.Opts == nil {
+		return res
+	}
 
-		fi := NewFuseFileInfo()
-		fi.Fh = readIn.Fh
+	fi := NewFuseFileInfo()
+	fi.Fh = readIn.Fh
 
-		var content []byte
+	if se.Opts.Readdirplus != nil {
 
-		content, res = (*se.Opts.Readdirplus)(req, nodeid, readIn.Size, readIn.Offset, fi)
+		var direntList []DirentPlus
+
+		direntList, res = (*se.Opts.Readdirplus)(req, nodeid, readIn.Size, readIn.Offset, fi)
 
 		if res == errno.SUCCESS {
-			readOut.Content = content
+
+			buf := bytes.NewBuffer(nil)
+			full := false
+
+			for i, val := range direntList {
+
+				if full {
+					// not sent to kernel, drop the lookup count
+					forgetDirentPlus(req, val)
+					continue
+				}
+
+				direntplus := kernel.FuseDirentplus{Dirent: toFuseDirent(val.Dirent, readIn.Offset, i)}
+
+				if uint64(buf.Len())+kernel.DirentplusSize(direntplus.Dirent.NameLen) > uint64(readIn.Size) {
+					full = true
+					forgetDirentPlus(req, val)
+					continue
+				}
+
+				if val.FileStat.Nodeid != 0 {
+					fsStat := val.FileStat
+					if val.EntryTimeout != 0 {
+						fsStat.EntryTimeout = val.EntryTimeout
+					}
+					if val.AttrTimeout != 0 {
+						fsStat.AttrTimeout = val.AttrTimeout
+					}
+					setEntryOut(se, &direntplus.EntryOut, &fsStat)
+				}
+
+				b, _ := direntplus.ToBinary()
+				buf.Write(b)
+			}
+
+			readOut.Content = buf.Bytes()
+		}
+
+		return res
+	}
+
+	if se.Opts.Lookup == nil {
+		return res
+	}
+
+	// build the entries from readdir and lookup
+	var stream DirStream
+
+	stream, res = openDirStream(req, nodeid, readIn.Size, readIn.Offset, fi)
+
+	if res != errno.SUCCESS || stream == nil {
+		return res
+	}
+	defer stream.Close()
+
+	buf := bytes.NewBuffer(nil)
+
+	for i := 0; stream.HasNext(); i++ {
+
+		val, nextRes := stream.Next()
+		if nextRes != errno.SUCCESS {
+			if buf.Len() == 0 {
+				return nextRes
+			}
+			break
+		}
+
+		direntplus := kernel.FuseDirentplus{Dirent: toFuseDirent(val, readIn.Offset, i)}
+
+		// the size does not depend on entry_out, check it before lookup
+		if uint64(buf.Len())+kernel.DirentplusSize(direntplus.Dirent.NameLen) > uint64(readIn.Size) {
+			break
 		}
 
+		if !isDotName(val.Name) {
+			fsStat, lookupRes := (*se.Opts.Lookup)(req, nodeid, val.Name)
+			if lookupRes == errno.SUCCESS && fsStat != nil {
+				setEntryOut(se, &direntplus.EntryOut, fsStat)
+			}
+		}
+
+		b, _ := direntplus.ToBinary()
+		buf.Write(b)
 	}
 
+	readOut.Content = buf.Bytes()
+
 	return res
 }
 
+// writebackOpenFlags : adjust the open flags for writeback cache,
+// the kernel may read the file opened O_WRONLY to fill a page,
+// and it sends the writes of O_APPEND with the offset itself
+func writebackOpenFlags(se *Session, flags uint32) uint32 {
+
+	if (se.connInfo.Want & FuseCapWritebackCache) == 0 {
+		return flags
+	}
+
+	if (flags & syscall.O_ACCMODE) == syscall.O_WRONLY {
+		flags &^= syscall.O_ACCMODE
+		flags |= syscall.O_RDWR
+	}
+	flags &^= syscall.O_APPEND
+
+	return flags
+}
+
+// setEntryOut : fill the entry_out with fsStat, Nodeid 0 is a negative entry
+func setEntryOut(se *Session, entryOut *kernel.FuseEntryOut, fsStat *FileStat) {
+
+	entryTimeout := cacheTimeout(fsStat.EntryTimeout, se.FuseConfig.EntryTimeout)
+	if fsStat.Nodeid == 0 {
+		entryTimeout = cacheTimeout(fsStat.EntryTimeout, se.FuseConfig.NegativeTimeout)
+	}
+	attrTimeout := cacheTimeout(fsStat.AttrTimeout, se.FuseConfig.AttrTimeout)
+
+	entryOut.NodeID = fsStat.Nodeid
+	entryOut.Generation = fsStat.Generation
+	if entryOut.Generation == 0 && fsStat.Nodeid != 0 {
+		entryOut.Generation = se.Generation(fsStat.Nodeid)
+	}
+	entryOut.EntryValid = common.CalcTimeoutSec(entryTimeout)
+	entryOut.EntryValidNsec = common.CalcTimeoutNsec(entryTimeout)
+
+	if fsStat.Nodeid == 0 {
+		return
+	}
+
+	entryOut.AttrValid = common.CalcTimeoutSec(attrTimeout)
+	entryOut.AttrValidNsec = common.CalcTimeoutNsec(attrTimeout)
+	setFuseAttr(&entryOut.Attr, fsStat.Stat)
+}
+
+// setAttrOut : fill the attr_out with fsStat
+func setAttrOut(se *Session, attrOut *kernel.FuseAttrOut, fsStat *FileStat) {
+
+	attrTimeout := cacheTimeout(fsStat.AttrTimeout, se.FuseConfig.AttrTimeout)
+	attrOut.AttrValid = common.CalcTimeoutSec(attrTimeout)
+	attrOut.AttrValidNsec = common.CalcTimeoutNsec(attrTimeout)
+	setFuseAttr(&attrOut.Attr, fsStat.Stat)
+}
+
+// setStatxOut : fill the statx_out with attr
+func setStatxOut(se *Session, statxOut *kernel.FuseStatxOut, attr Attr) {
+
+	attrTimeout := cacheTimeout(attr.AttrTimeout, se.FuseConfig.AttrTimeout)
+	statxOut.AttrValid = common.CalcTimeoutSec(attrTimeout)
+	statxOut.AttrValidNsec = common.CalcTimeoutNsec(attrTimeout)
+	setFuseStatx(&statxOut.Stat, attr)
+}
+
+// cacheTimeout : timeout t, 0 is the default timeout def, negative means no caching
+func cacheTimeout(t float64, def float64) float64 {
+
+	if t == 0 {
+		return def
+	}
+	if t < 0 {
+		return 0
+	}
+
+	return t
+}
+
+// forgetDirentPlus : drop the lookup count of the entry which is not sent to kernel
+func forgetDirentPlus(req Req, dirent DirentPlus) {
+
+	se := req.session
+
+	if dirent.FileStat.Nodeid == 0 || isDotName(dirent.Dirent.Name) {
+		return
+	}
+
+	if se.Opts != nil && se.Opts.Forget != nil {
+		(*se.Opts.Forget)(req, dirent.FileStat.Nodeid, 1)
+	}
+}
+
+func isDotName(name string) bool {
+	return name == "." || name == ".."
+}
+
 func doInterrupt(req Req) {
+	interruptIn := (*req.Arg).(kernel.FuseInterruptIn)
 	se := req.session
 
 	if se.Debug {
-		log.Trace.Println("Interrupt")
+		log.Trace.Printf("Interrupt: %+v \n", interruptIn)
 	}
 
-	if se.Opts != nil && se.Opts.Readdirplus != nil {
+	// the request maybe already replied, then nothing to do
+	se.interruptReq(interruptIn.Unique)
+
+	if se.Opts != nil && se.Opts.Interrupt != nil {
 		(*se.Opts.Interrupt)(req)
 	}
 }
 
+// compatIovecs : whether the iovecs fit in the address space of a 32bit process
+func compatIovecs(iovs []IoctlIovec) bool {
+
+	for _, iov := range iovs {
+		if iov.Base > math.MaxUint32 || iov.Len > math.MaxUint32 {
+			return false
+		}
+	}
+
+	return true
+}
+
 const offsetMax = 0x7fffffffffffffff
 
 func convertFuseFileLock(lock kernel.FuseFileLock, flock *Flock) {
@@ -1338,18 +1871,44 @@ func setattrInToStat(setattrIn kernel.FuseSetattrIn, stat *syscall.Stat_t) {
 
 }
 
+// kernelOwnedAttr : the size and times set by the kernel of writeback
+// cache win over the ones of Getattr, which may not have the cached
+// writes yet
+func kernelOwnedAttr(setattrIn kernel.FuseSetattrIn, stat *syscall.Stat_t) {
+
+	if (setattrIn.Valid & FuseSetAttrSize) > 0 {
+		stat.Size = int64(setattrIn.Size)
+	}
+	if (setattrIn.Valid&FuseSetAttrMtime) > 0 && (setattrIn.Valid&FuseSetAttrMtimeNow) == 0 {
+		stat.Mtim.Sec = int64(setattrIn.Mtime)
+		stat.Mtim.Nsec = int64(setattrIn.MtimeNsec)
+	}
+	if (setattrIn.Valid & FuseSetAttrCtime) > 0 {
+		stat.Ctim.Sec = int64(setattrIn.Ctime)
+		stat.Ctim.Nsec = int64(setattrIn.CtimeNsec)
+	}
+}
+
 func setOpenOut(openOut *kernel.FuseOpenOut, fi FileInfo) {
 	openOut.Fh = fi.Fh
 
-	if fi.DirectIo > 0 {
+	if fi.DirectIo {
 		openOut.OpenFlags |= FOpenDirectIO
 	}
 
-	if fi.KeepCache > 0 {
+	if fi.KeepCache {
 		openOut.OpenFlags |= FOpenKeepCache
 	}
 
-	if fi.Nonseekable > 0 {
+	if fi.Nonseekable {
 		openOut.OpenFlags |= FOpenNonSeekable
 	}
+
+	if fi.CacheDir {
+		openOut.OpenFlags |= FOpenCacheDir
+	}
+
+	if fi.NoFlush {
+		openOut.OpenFlags |= FOpenNoflush
+	}
 }
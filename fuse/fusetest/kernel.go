@@ -0,0 +1,411 @@
+package fusetest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/common"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+	"github.com/mingforpc/fuse-go/fuse/kernel"
+)
+
+var (
+	// ErrClosed : the kernel is closed
+	ErrClosed = errors.New("fusetest: kernel is closed")
+	// ErrTimeout : no reply in Kernel.Timeout
+	ErrTimeout = errors.New("fusetest: reply timeout")
+	// ErrShortReply : the reply is shorter than the struct to decode
+	ErrShortReply = errors.New("fusetest: reply too short")
+)
+
+// DefaultTimeout : the default of Kernel.Timeout
+const DefaultTimeout = 10 * time.Second
+
+// Reply : a reply of the session
+type Reply struct {
+	Unique uint64
+
+	// Errno : errno.SUCCESS or the errno of the reply, as the errno package
+	Errno int32
+
+	// Data : the bytes after kernel.FuseOutHeader
+	Data []byte
+}
+
+// Decode : decode Data to out, a pointer to a kernel.*Out struct of fixed size
+func (reply Reply) Decode(out interface{}) error {
+
+	if len(reply.Data) < binary.Size(out) {
+		return ErrShortReply
+	}
+
+	return common.ParseBinary(reply.Data, out)
+}
+
+// Pending : a request sent and not replied yet
+type Pending struct {
+	Unique uint64
+
+	k     *Kernel
+	reply chan Reply
+}
+
+// Wait : wait the reply of the request
+func (p *Pending) Wait() (Reply, error) {
+
+	var timeout <-chan time.Time
+	if p.k.Timeout > 0 {
+		timer := time.NewTimer(p.k.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case reply := <-p.reply:
+		return reply, nil
+	case <-p.k.done:
+		p.k.forget(p.Unique)
+		return Reply{}, ErrClosed
+	case <-timeout:
+		p.k.forget(p.Unique)
+		return Reply{}, ErrTimeout
+	}
+}
+
+// Kernel : a fake kernel in memory, it sends the requests to a session
+// and receives the replies without "/dev/fuse", so Opt of a filesystem
+// can be tested in pure Go without mounting.
+//
+// 测试用的内核: 不需要挂载，直接向Session发送请求并接收回复
+type Kernel struct {
+	// unique : first for the 64-bit alignment of atomic
+	unique uint64
+
+	// UID, Gid, Pid : the caller of the requests, the current process by default
+	UID uint32
+	Gid uint32
+	Pid uint32
+
+	// Timeout : how long to wait a reply, 0 means forever
+	Timeout time.Duration
+
+	se *fuse.Session
+
+	reqs   chan []byte
+	closed chan interface{}
+	done   chan interface{} // closed when FuseLoop returns
+
+	closeOnce sync.Once
+
+	waits     map[uint64]chan Reply
+	waitsLock sync.Mutex
+}
+
+// NewKernel : serve se by a new fake kernel, FuseLoop of se is started.
+// Set se.FuseConfig and se.Opts before it, then call Init first as
+// the real kernel does.
+func NewKernel(se *fuse.Session) *Kernel {
+
+	k := &Kernel{}
+
+	k.UID = uint32(os.Getuid())
+	k.Gid = uint32(os.Getgid())
+	k.Pid = uint32(os.Getpid())
+	k.Timeout = DefaultTimeout
+
+	k.se = se
+	k.reqs = make(chan []byte)
+	k.closed = make(chan interface{})
+	k.done = make(chan interface{})
+	k.waits = make(map[uint64]chan Reply)
+
+	se.SetTransport(&transport{k: k})
+
+	go func() {
+		defer close(k.done)
+		se.FuseLoop()
+	}()
+
+	return k
+}
+
+// Session : the session served by k
+func (k *Kernel) Session() *fuse.Session {
+	return k.se
+}
+
+// Close : disconnect the session as unmounting, FuseLoop returns
+// and the session is closed. The requests not replied get ErrClosed.
+// It returns after the handlers of the session return, so se.Opts can
+// be changed after it.
+func (k *Kernel) Close() {
+	k.closeOnce.Do(func() {
+		close(k.closed)
+	})
+	<-k.done
+
+	k.se.Close()
+	k.se.WaitHandlers()
+}
+
+// Go : send the request of opcode to nodeid, without waiting the reply.
+// in is a kernel.*In struct, which is encoded in the order of its fields,
+// strings end with '\0'. It can also be []byte, or nil for no argument.
+func (k *Kernel) Go(opcode uint32, nodeid uint64, in interface{}) (*Pending, error) {
+
+	unique := atomic.AddUint64(&k.unique, 2)
+
+	frame, err := k.frame(opcode, unique, nodeid, in)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.goFrame(unique, frame)
+}
+
+// goFrame : send the request frame and wait the reply of unique
+func (k *Kernel) goFrame(unique uint64, frame []byte) (*Pending, error) {
+
+	p := &Pending{Unique: unique, k: k, reply: make(chan Reply, 1)}
+
+	k.waitsLock.Lock()
+	k.waits[unique] = p.reply
+	k.waitsLock.Unlock()
+
+	err := k.sendFrame(frame)
+	if err != nil {
+		k.forget(unique)
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Call : send the request of opcode to nodeid and wait the reply
+func (k *Kernel) Call(opcode uint32, nodeid uint64, in interface{}) (Reply, error) {
+
+	p, err := k.Go(opcode, nodeid, in)
+	if err != nil {
+		return Reply{}, err
+	}
+
+	return p.Wait()
+}
+
+// Send : send the request which has no reply, such as forget and interrupt
+func (k *Kernel) Send(opcode uint32, nodeid uint64, in interface{}) error {
+
+	frame, err := k.frame(opcode, atomic.AddUint64(&k.unique, 2), nodeid, in)
+	if err != nil {
+		return err
+	}
+
+	return k.sendFrame(frame)
+}
+
+// frame : the request frame with the header of k
+func (k *Kernel) frame(opcode uint32, unique uint64, nodeid uint64, in interface{}) ([]byte, error) {
+
+	arg, err := Encode(in)
+	if err != nil {
+		return nil, err
+	}
+
+	header := kernel.FuseInHeader{
+		Len:    uint32(kernel.InHeaderLen + len(arg)),
+		Opcode: opcode,
+		Unique: unique,
+		Nodeid: nodeid,
+		UID:    k.UID,
+		Gid:    k.Gid,
+		Pid:    k.Pid,
+	}
+
+	frame, err := common.ToBinary(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(frame, arg...), nil
+}
+
+// sendFrame : pass the request frame to the session
+func (k *Kernel) sendFrame(frame []byte) error {
+
+	select {
+	case k.reqs <- frame:
+		return nil
+	case <-k.closed:
+		return ErrClosed
+	case <-k.done:
+		return ErrClosed
+	}
+}
+
+func (k *Kernel) forget(unique uint64) {
+	k.waitsLock.Lock()
+	delete(k.waits, unique)
+	k.waitsLock.Unlock()
+}
+
+// reply : pass the reply written by the session to its waiter
+func (k *Kernel) reply(frame []byte) error {
+
+	reply, err := parseReply(frame)
+	if err != nil {
+		return err
+	}
+
+	k.waitsLock.Lock()
+	wait, ok := k.waits[reply.Unique]
+	delete(k.waits, reply.Unique)
+	k.waitsLock.Unlock()
+
+	if !ok {
+		// as the kernel, the reply of unknown request is an error
+		return syscall.ENOENT
+	}
+
+	wait <- reply
+
+	return nil
+}
+
+// fail : answer the request frame with errnum by the kernel itself
+func (k *Kernel) fail(frame []byte, errnum int32) {
+
+	header := kernel.FuseInHeader{}
+	if header.ParseBinary(frame) != nil {
+		return
+	}
+
+	k.waitsLock.Lock()
+	wait, ok := k.waits[header.Unique]
+	delete(k.waits, header.Unique)
+	k.waitsLock.Unlock()
+
+	if ok {
+		wait <- Reply{Unique: header.Unique, Errno: errnum}
+	}
+}
+
+// parseReply : parse the reply frame written by the session
+func parseReply(frame []byte) (Reply, error) {
+
+	if len(frame) < kernel.OutHeaderLen {
+		return Reply{}, syscall.EINVAL
+	}
+
+	header := kernel.FuseOutHeader{}
+	err := common.ParseBinary(frame[:kernel.OutHeaderLen], &header)
+	if err != nil {
+		return Reply{}, err
+	}
+	if int(header.Len) != len(frame) {
+		return Reply{}, syscall.EINVAL
+	}
+
+	data := make([]byte, len(frame)-kernel.OutHeaderLen)
+	copy(data, frame[kernel.OutHeaderLen:])
+
+	return Reply{Unique: header.Unique, Errno: header.Error, Data: data}, nil
+}
+
+// transport : the fuse.Transport of Kernel
+type transport struct {
+	k *Kernel
+}
+
+// ReadFrame : the next request sent to the kernel
+func (t *transport) ReadFrame(buf []byte) (int, error) {
+
+	for {
+		select {
+		case frame := <-t.k.reqs:
+			if len(frame) > len(buf) {
+				// as the kernel, the request larger than the read
+				// buffer is answered EIO and not passed to the session
+				t.k.fail(frame, errno.EIO)
+				continue
+			}
+			return copy(buf, frame), nil
+		case <-t.k.closed:
+			return 0, syscall.ENODEV
+		}
+	}
+}
+
+// WriteFrame : a reply of the session
+func (t *transport) WriteFrame(frame [][]byte) error {
+	return t.k.reply(bytes.Join(frame, nil))
+}
+
+// Close : close the kernel
+func (t *transport) Close() error {
+	t.k.closeOnce.Do(func() {
+		close(t.k.closed)
+	})
+	return nil
+}
+
+// Encode : encode in as the argument of a request, the fields of a
+// struct are in order, numbers are little endian, strings end with '\0',
+// []byte is copied as it is
+func Encode(in interface{}) ([]byte, error) {
+
+	if in == nil {
+		return nil, nil
+	}
+
+	buf := bytes.NewBuffer(nil)
+
+	err := encode(buf, reflect.ValueOf(in))
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func encode(buf *bytes.Buffer, v reflect.Value) error {
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		return encode(buf, v.Elem())
+
+	case reflect.String:
+		buf.WriteString(v.String())
+		buf.WriteByte(0)
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			buf.Write(v.Bytes())
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := encode(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if err := encode(buf, v.Field(i)); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return binary.Write(buf, binary.LittleEndian, v.Interface())
+	}
+
+	return nil
+}
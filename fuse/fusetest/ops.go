@@ -0,0 +1,403 @@
+package fusetest
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/mingforpc/fuse-go/fuse/common"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+	"github.com/mingforpc/fuse-go/fuse/kernel"
+)
+
+// RootID : the nodeid of the root directory
+const RootID = 1
+
+// sizeIn : the argument of listxattr, FuseGetxattrIn without name
+type sizeIn struct {
+	Size    uint32
+	Padding uint32
+}
+
+// call : send the request and decode the reply to out if it succeeds
+func (k *Kernel) call(opcode uint32, nodeid uint64, in interface{}, out interface{}) (int32, error) {
+
+	reply, err := k.Call(opcode, nodeid, in)
+	if err != nil {
+		return errno.SUCCESS, err
+	}
+
+	if reply.Errno != errno.SUCCESS || out == nil {
+		return reply.Errno, nil
+	}
+
+	return reply.Errno, reply.Decode(out)
+}
+
+// data : send the request and return the data of the reply
+func (k *Kernel) data(opcode uint32, nodeid uint64, in interface{}) ([]byte, int32, error) {
+
+	reply, err := k.Call(opcode, nodeid, in)
+	if err != nil {
+		return nil, errno.SUCCESS, err
+	}
+
+	return reply.Data, reply.Errno, nil
+}
+
+// Init : the INIT handshake, in.Major and in.Minor are the version of
+// this library if they are 0
+func (k *Kernel) Init(in kernel.FuseInitIn) (out kernel.FuseInitOut, res int32, err error) {
+
+	if in.Major == 0 {
+		in.Major = kernel.FuseKernelVersion
+		in.Minor = kernel.FuseKernelMinorVersion
+	}
+
+	res, err = k.call(kernel.FuseOpInit, 0, in, &out)
+	return out, res, err
+}
+
+// Lookup : look up name in the directory parent
+func (k *Kernel) Lookup(parent uint64, name string) (out kernel.FuseEntryOut, res int32, err error) {
+	res, err = k.call(kernel.FuseOpLookup, parent, kernel.FuseLookupIn{Name: name}, &out)
+	return out, res, err
+}
+
+// Forget : forget nlookup lookups of nodeid, it has no reply
+func (k *Kernel) Forget(nodeid uint64, nlookup uint64) error {
+	return k.Send(kernel.FuseOpForget, nodeid, kernel.FuseForgetIn{Nlookup: nlookup})
+}
+
+// Getattr : get the attributes of nodeid
+func (k *Kernel) Getattr(nodeid uint64) (out kernel.FuseAttrOut, res int32, err error) {
+	res, err = k.call(kernel.FuseOpGetattr, nodeid, kernel.FuseGetattrIn{}, &out)
+	return out, res, err
+}
+
+// Setattr : set the attributes of nodeid, in.Valid is the fields to set
+func (k *Kernel) Setattr(nodeid uint64, in kernel.FuseSetattrIn) (out kernel.FuseAttrOut, res int32, err error) {
+	res, err = k.call(kernel.FuseOpSetattr, nodeid, in, &out)
+	return out, res, err
+}
+
+// Readlink : read the target of the symlink nodeid
+func (k *Kernel) Readlink(nodeid uint64) (target string, res int32, err error) {
+
+	data, res, err := k.data(kernel.FuseOpReadlink, nodeid, nil)
+
+	return string(bytes.TrimRight(data, "\x00")), res, err
+}
+
+// Symlink : create the symlink name to target in parent
+func (k *Kernel) Symlink(parent uint64, name string, target string) (out kernel.FuseEntryOut, res int32, err error) {
+	res, err = k.call(kernel.FuseOpSymlink, parent, kernel.FuseSymlinkIn{Name: name, LinkName: target}, &out)
+	return out, res, err
+}
+
+// Mknod : create the node name in parent
+func (k *Kernel) Mknod(parent uint64, name string, mode uint32, rdev uint32, umask uint32) (out kernel.FuseEntryOut, res int32, err error) {
+	in := kernel.FuseMknodIn{Mode: mode, Rdev: rdev, Umask: umask, Name: name}
+	res, err = k.call(kernel.FuseOpMknod, parent, in, &out)
+	return out, res, err
+}
+
+// Mkdir : create the directory name in parent
+func (k *Kernel) Mkdir(parent uint64, name string, mode uint32, umask uint32) (out kernel.FuseEntryOut, res int32, err error) {
+	in := kernel.FuseMkdirIn{Mode: mode, Umask: umask, Name: name}
+	res, err = k.call(kernel.FuseOpMkdir, parent, in, &out)
+	return out, res, err
+}
+
+// Unlink : remove the file name in parent
+func (k *Kernel) Unlink(parent uint64, name string) (res int32, err error) {
+	return k.call(kernel.FuseOpUnlink, parent, kernel.FuseUnlinkIn{Path: name}, nil)
+}
+
+// Rmdir : remove the directory name in parent
+func (k *Kernel) Rmdir(parent uint64, name string) (res int32, err error) {
+	return k.call(kernel.FuseOpRmdir, parent, kernel.FuseRmdirIn{Path: name}, nil)
+}
+
+// Rename : rename name in parent to newname in newparent
+func (k *Kernel) Rename(parent uint64, name string, newparent uint64, newname string) (res int32, err error) {
+	in := kernel.FuseRenameIn{NewDir: newparent, OldName: name, NewName: newname}
+	return k.call(kernel.FuseOpRename, parent, in, nil)
+}
+
+// Link : create the hard link newname in newparent to nodeid
+func (k *Kernel) Link(nodeid uint64, newparent uint64, newname string) (out kernel.FuseEntryOut, res int32, err error) {
+	in := kernel.FuseLinkIn{OldNodeid: nodeid, NewName: newname}
+	res, err = k.call(kernel.FuseOpLink, newparent, in, &out)
+	return out, res, err
+}
+
+// Open : open nodeid with the flags of open(2)
+func (k *Kernel) Open(nodeid uint64, flags uint32) (out kernel.FuseOpenOut, res int32, err error) {
+	res, err = k.call(kernel.FuseOpOpen, nodeid, kernel.FuseOpenIn{Flags: flags}, &out)
+	return out, res, err
+}
+
+// Create : create and open the file name in parent
+func (k *Kernel) Create(parent uint64, name string, flags uint32, mode uint32, umask uint32) (out kernel.FuseCreateOut, res int32, err error) {
+	in := kernel.FuseCreateIn{Flags: flags, Mode: mode, Umask: umask, Name: name}
+	res, err = k.call(kernel.FuseOpCreate, parent, in, &out)
+	return out, res, err
+}
+
+// Read : read size bytes at offset of the file handle fh
+func (k *Kernel) Read(nodeid uint64, fh uint64, offset uint64, size uint32) (data []byte, res int32, err error) {
+	in := kernel.FuseReadIn{Fh: fh, Offset: offset, Size: size}
+	return k.data(kernel.FuseOpRead, nodeid, in)
+}
+
+// Write : write data at offset of the file handle fh
+func (k *Kernel) Write(nodeid uint64, fh uint64, offset uint64, data []byte) (out kernel.FuseWriteOut, res int32, err error) {
+	in := kernel.FuseWriteIn{Fh: fh, Offset: offset, Size: uint32(len(data)), Buf: data}
+	res, err = k.call(kernel.FuseOpWrite, nodeid, in, &out)
+	return out, res, err
+}
+
+// Flush : flush the file handle fh, on each close(2)
+func (k *Kernel) Flush(nodeid uint64, fh uint64) (res int32, err error) {
+	return k.call(kernel.FuseOpFlush, nodeid, kernel.FuseFlushIn{Fh: fh}, nil)
+}
+
+// Fsync : fsync the file handle fh, datasync is the flags of fdatasync
+func (k *Kernel) Fsync(nodeid uint64, fh uint64, datasync uint32) (res int32, err error) {
+	return k.call(kernel.FuseOpFsync, nodeid, kernel.FuseFsyncIn{Fh: fh, FsyncFlags: datasync}, nil)
+}
+
+// Release : release the file handle fh
+func (k *Kernel) Release(nodeid uint64, fh uint64, flags uint32) (res int32, err error) {
+	return k.call(kernel.FuseOpRelease, nodeid, kernel.FuseReleaseIn{Fh: fh, Flags: flags}, nil)
+}
+
+// Opendir : open the directory nodeid
+func (k *Kernel) Opendir(nodeid uint64) (out kernel.FuseOpenOut, res int32, err error) {
+	res, err = k.call(kernel.FuseOpOpendir, nodeid, kernel.FuseOpenIn{}, &out)
+	return out, res, err
+}
+
+// Readdir : read the entries of the directory handle fh from offset,
+// in a reply buffer of size bytes
+func (k *Kernel) Readdir(nodeid uint64, fh uint64, offset uint64, size uint32) (dirents []kernel.FuseDirent, res int32, err error) {
+
+	in := kernel.FuseReadIn{Fh: fh, Offset: offset, Size: size}
+
+	data, res, err := k.data(kernel.FuseOpReaddir, nodeid, in)
+	if err != nil || res != errno.SUCCESS {
+		return nil, res, err
+	}
+
+	dirents, err = ParseDirents(data)
+
+	return dirents, res, err
+}
+
+// Readdirplus : read the entries of the directory handle fh from offset
+// with their attributes, in a reply buffer of size bytes
+func (k *Kernel) Readdirplus(nodeid uint64, fh uint64, offset uint64, size uint32) (direntpluses []kernel.FuseDirentplus, res int32, err error) {
+
+	in := kernel.FuseReadIn{Fh: fh, Offset: offset, Size: size}
+
+	data, res, err := k.data(kernel.FuseOpReaddirplus, nodeid, in)
+	if err != nil || res != errno.SUCCESS {
+		return nil, res, err
+	}
+
+	direntpluses, err = ParseDirentpluses(data)
+
+	return direntpluses, res, err
+}
+
+// Releasedir : release the directory handle fh
+func (k *Kernel) Releasedir(nodeid uint64, fh uint64) (res int32, err error) {
+	return k.call(kernel.FuseOpReleasedir, nodeid, kernel.FuseReleaseIn{Fh: fh}, nil)
+}
+
+// Statfs : the statistics of the filesystem
+func (k *Kernel) Statfs(nodeid uint64) (out kernel.FuseStatfsOut, res int32, err error) {
+	res, err = k.call(kernel.FuseOpStatfs, nodeid, nil, &out)
+	return out, res, err
+}
+
+// Access : check the access of mask to nodeid
+func (k *Kernel) Access(nodeid uint64, mask uint32) (res int32, err error) {
+	return k.call(kernel.FuseOpAccess, nodeid, kernel.FuseAccessIn{Mask: mask}, nil)
+}
+
+// Setxattr : set the xattr name of nodeid
+func (k *Kernel) Setxattr(nodeid uint64, name string, value []byte, flags uint32) (res int32, err error) {
+	in := kernel.FuseSetxattrIn{Size: uint32(len(value)), Flags: flags, Name: name, Value: value}
+	return k.call(kernel.FuseOpSetxattr, nodeid, in, nil)
+}
+
+// Getxattr : get the xattr name of nodeid, size 0 returns the length of the value
+func (k *Kernel) Getxattr(nodeid uint64, name string, size uint32) (value []byte, length uint32, res int32, err error) {
+
+	data, res, err := k.data(kernel.FuseOpGetxattr, nodeid, kernel.FuseGetxattrIn{Size: size, Name: name})
+	if err != nil || res != errno.SUCCESS {
+		return nil, 0, res, err
+	}
+
+	if size == 0 {
+		length, err = xattrSize(data)
+		return nil, length, res, err
+	}
+
+	return data, uint32(len(data)), res, nil
+}
+
+// Listxattr : list the xattr names of nodeid, size 0 returns the length of the list
+func (k *Kernel) Listxattr(nodeid uint64, size uint32) (names []string, length uint32, res int32, err error) {
+
+	data, res, err := k.data(kernel.FuseOpListxattr, nodeid, sizeIn{Size: size})
+	if err != nil || res != errno.SUCCESS {
+		return nil, 0, res, err
+	}
+
+	if size == 0 {
+		length, err = xattrSize(data)
+		return nil, length, res, err
+	}
+
+	for _, name := range bytes.Split(data, []byte{0}) {
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+
+	return names, uint32(len(data)), res, nil
+}
+
+// Removexattr : remove the xattr name of nodeid
+func (k *Kernel) Removexattr(nodeid uint64, name string) (res int32, err error) {
+	return k.call(kernel.FuseOpRemovexattr, nodeid, kernel.FuseRemovexattrIn{Name: name}, nil)
+}
+
+// Ioctl : the ioctl on nodeid, out has the iovecs if out.Flags has
+// kernel.FuseIoctlRetry, otherwise the output data in out.Buf
+func (k *Kernel) Ioctl(nodeid uint64, in kernel.FuseIoctlIn) (out kernel.FuseIoctlOut, res int32, err error) {
+
+	in.InSize = uint32(len(in.InBuf))
+
+	reply, err := k.Call(kernel.FuseOpIoctl, nodeid, in)
+	if err != nil || reply.Errno != errno.SUCCESS {
+		return out, reply.Errno, err
+	}
+
+	data := reply.Data
+	if len(data) < 16 {
+		return out, reply.Errno, ErrShortReply
+	}
+
+	common.ParseBinary(data[0:4], &out.Result)
+	common.ParseBinary(data[4:8], &out.Flags)
+	common.ParseBinary(data[8:12], &out.InIovs)
+	common.ParseBinary(data[12:16], &out.OutIovs)
+	data = data[16:]
+
+	if (out.Flags & kernel.FuseIoctlRetry) == 0 {
+		out.Buf = data
+		return out, reply.Errno, nil
+	}
+
+	if uint64(len(data)) != 16*(uint64(out.InIovs)+uint64(out.OutIovs)) {
+		return out, reply.Errno, ErrShortReply
+	}
+
+	out.InIovecs = make([]kernel.FuseIoctlIovec, out.InIovs)
+	out.OutIovecs = make([]kernel.FuseIoctlIovec, out.OutIovs)
+	common.ParseBinary(data[:16*out.InIovs], out.InIovecs)
+	common.ParseBinary(data[16*out.InIovs:], out.OutIovecs)
+
+	return out, reply.Errno, nil
+}
+
+// Interrupt : interrupt the request unique, it has no reply
+func (k *Kernel) Interrupt(unique uint64) error {
+	return k.Send(kernel.FuseOpInterrupt, 0, kernel.FuseInterruptIn{Unique: unique})
+}
+
+// Destroy : the last request before unmounting, it has no reply
+func (k *Kernel) Destroy() error {
+	return k.Send(kernel.FuseOpDestory, 0, nil)
+}
+
+func xattrSize(data []byte) (uint32, error) {
+
+	out := kernel.FuseGetxattrOut{}
+	err := Reply{Data: data}.Decode(&out)
+
+	return out.Size, err
+}
+
+// ParseDirents : parse the reply of readdir
+func ParseDirents(data []byte) ([]kernel.FuseDirent, error) {
+
+	var dirents []kernel.FuseDirent
+
+	for len(data) > 0 {
+
+		dirent, size, err := parseDirent(data)
+		if err != nil {
+			return dirents, err
+		}
+		dirents = append(dirents, dirent)
+
+		data = data[size:]
+	}
+
+	return dirents, nil
+}
+
+// ParseDirentpluses : parse the reply of readdirplus
+func ParseDirentpluses(data []byte) ([]kernel.FuseDirentplus, error) {
+
+	var direntpluses []kernel.FuseDirentplus
+
+	for len(data) > 0 {
+
+		direntplus := kernel.FuseDirentplus{}
+		entrySize := binary.Size(direntplus.EntryOut)
+		if len(data) < entrySize {
+			return direntpluses, ErrShortReply
+		}
+
+		common.ParseBinary(data[:entrySize], &direntplus.EntryOut)
+
+		dirent, size, err := parseDirent(data[entrySize:])
+		if err != nil {
+			return direntpluses, err
+		}
+		direntplus.Dirent = dirent
+		direntpluses = append(direntpluses, direntplus)
+
+		data = data[uint64(entrySize)+size:]
+	}
+
+	return direntpluses, nil
+}
+
+// parseDirent : parse the first dirent of data and its aligned size
+func parseDirent(data []byte) (kernel.FuseDirent, uint64, error) {
+
+	dirent := kernel.FuseDirent{}
+	if len(data) < 24 {
+		return dirent, 0, ErrShortReply
+	}
+
+	common.ParseBinary(data[0:8], &dirent.Ino)
+	common.ParseBinary(data[8:16], &dirent.Off)
+	common.ParseBinary(data[16:20], &dirent.NameLen)
+	common.ParseBinary(data[20:24], &dirent.DirType)
+
+	size := kernel.DirentSize(dirent.NameLen)
+	if uint64(len(data)) < size {
+		return dirent, 0, ErrShortReply
+	}
+
+	dirent.Name = string(data[24 : 24+dirent.NameLen])
+
+	return dirent, size, nil
+}
@@ -0,0 +1,142 @@
+package fusetest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/kernel"
+	"github.com/mingforpc/fuse-go/fuse/record"
+)
+
+// noReplyOps : the opcodes which the session does not reply
+var noReplyOps = map[uint32]bool{
+	kernel.FuseOpForget:      true,
+	kernel.FuseOpBatckForget: true,
+	kernel.FuseOpInterrupt:   true,
+	kernel.FuseOpDestory:     true,
+}
+
+// Mismatch : a reply of the replay which is not the recorded one
+type Mismatch struct {
+	Unique uint64
+	Opcode uint32
+
+	// Want : the recorded reply
+	Want Reply
+
+	// Got : the reply of the replay, if Err is nil
+	Got Reply
+	Err error
+}
+
+// String : describe the difference
+func (m Mismatch) String() string {
+
+	if m.Err != nil {
+		return fmt.Sprintf("unique[%d] opcode[%d]: %s", m.Unique, m.Opcode, m.Err)
+	}
+	if m.Got.Errno != m.Want.Errno {
+		return fmt.Sprintf("unique[%d] opcode[%d]: errno %d, recorded %d", m.Unique, m.Opcode, m.Got.Errno, m.Want.Errno)
+	}
+
+	return fmt.Sprintf("unique[%d] opcode[%d]: data %x, recorded %x", m.Unique, m.Opcode, m.Got.Data, m.Want.Data)
+}
+
+// Replay : send the requests recorded by Config.RecordPath to se with a
+// Kernel in the recorded order, and compare the replies with the recorded
+// ones. A request is sent before the replies recorded after it are waited,
+// so the interrupts and other concurrent requests are replayed as they
+// came. se should be new, the recording starts with INIT. The error is
+// about reading the recording, the differences are the mismatches,
+// including the requests whose reply is not recorded.
+//
+// 回放: 把录制的请求重新发送给文件系统，比较回复
+func Replay(se *fuse.Session, r io.Reader) ([]Mismatch, error) {
+
+	reader, err := record.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	k := NewKernel(se)
+	defer k.Close()
+
+	var mismatches []Mismatch
+
+	pendings := make(map[uint64]*Pending)
+	opcodes := make(map[uint64]uint32)
+
+	for {
+		rec, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return mismatches, err
+		}
+
+		unique := rec.Unique()
+
+		if rec.Kind == record.KindRequest && noReplyOps[rec.Opcode()] {
+			err := k.sendFrame(rec.Frame)
+			if err != nil {
+				return mismatches, err
+			}
+			continue
+		}
+
+		if rec.Kind == record.KindRequest {
+			p, err := k.goFrame(unique, rec.Frame)
+			if err != nil {
+				return mismatches, err
+			}
+			pendings[unique] = p
+			opcodes[unique] = rec.Opcode()
+			continue
+		}
+
+		want, err := parseReply(rec.Frame)
+		if err != nil {
+			return mismatches, err
+		}
+		if unique == 0 {
+			// notification, not a reply
+			continue
+		}
+
+		m := Mismatch{Unique: unique, Opcode: opcodes[unique], Want: want}
+
+		p, ok := pendings[unique]
+		if !ok {
+			m.Err = fmt.Errorf("no request recorded")
+			mismatches = append(mismatches, m)
+			continue
+		}
+		delete(pendings, unique)
+		delete(opcodes, unique)
+
+		m.Got, m.Err = p.Wait()
+		if m.Err != nil || m.Got.Errno != want.Errno || !bytes.Equal(m.Got.Data, want.Data) {
+			mismatches = append(mismatches, m)
+		}
+	}
+
+	// the requests recorded without reply, in the recorded order
+	var leftovers []uint64
+	for unique := range pendings {
+		leftovers = append(leftovers, unique)
+	}
+	sort.Slice(leftovers, func(i, j int) bool { return leftovers[i] < leftovers[j] })
+
+	for _, unique := range leftovers {
+		k.forget(unique)
+		m := Mismatch{Unique: unique, Opcode: opcodes[unique]}
+		m.Err = fmt.Errorf("no reply recorded")
+		mismatches = append(mismatches, m)
+	}
+
+	return mismatches, nil
+}
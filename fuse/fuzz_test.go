@@ -0,0 +1,52 @@
+package fuse
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/mingforpc/fuse-go/fuse/kernel"
+)
+
+// frame : the request of opcode with the argument, as read from '/dev/fuse'
+func frame(opcode uint32, arg []byte) []byte {
+
+	bcontent := make([]byte, kernel.InHeaderLen+len(arg))
+	binary.LittleEndian.PutUint32(bcontent[0:], uint32(len(bcontent)))
+	binary.LittleEndian.PutUint32(bcontent[4:], opcode)
+	binary.LittleEndian.PutUint64(bcontent[8:], 1)
+	binary.LittleEndian.PutUint64(bcontent[16:], 1)
+	copy(bcontent[kernel.InHeaderLen:], arg)
+
+	return bcontent
+}
+
+//FuzzDistribute : any request read from '/dev/fuse' should be replied or dropped, never panic
+func FuzzDistribute(f *testing.F) {
+
+	f.Add([]byte{})
+	f.Add(make([]byte, kernel.InHeaderLen-1))
+	for opcode := uint32(kernel.FuseOpLookup); opcode <= kernel.FuseOpStatx; opcode++ {
+		f.Add(frame(opcode, nil))
+		f.Add(frame(opcode, append(make([]byte, 88), "name\x00target\x00"...)))
+	}
+	f.Add(frame(kernel.CuseInit, make([]byte, 16)))
+
+	// one session for all inputs, a session holds a epoll fd
+	se := NewFuseSession("", &Opt{}, 1)
+
+	f.Fuzz(func(t *testing.T, bcontent []byte) {
+
+		inheader, buf, err := se.parseHeader(bcontent)
+		if err != nil {
+			return
+		}
+
+		req := Req{}
+		req.Init(se, inheader)
+
+		se.registerReq(&req)
+		defer se.unregisterReq(&req)
+
+		distribute(&req, inheader, buf)
+	})
+}
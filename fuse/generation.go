@@ -0,0 +1,58 @@
+package fuse
+
+// Generation : the generation of nodeid, 0 if it has never been bumped.
+// The library uses it in the replies if FileStat.Generation is 0.
+func (se *Session) Generation(nodeid uint64) uint64 {
+
+	se.generationsLock.Lock()
+	defer se.generationsLock.Unlock()
+
+	return se.generations[nodeid]
+}
+
+// BumpGeneration : increase the generation of nodeid and return it.
+// Call it when nodeid is reused for a different file after it is
+// forgotten, so the file handles of the old file (e.g. exported over NFS)
+// become stale instead of pointing to the new file.
+func (se *Session) BumpGeneration(nodeid uint64) uint64 {
+
+	se.generationsLock.Lock()
+	defer se.generationsLock.Unlock()
+
+	if se.generations == nil {
+		se.generations = make(map[uint64]uint64)
+	}
+	se.generations[nodeid]++
+
+	return se.generations[nodeid]
+}
+
+// exportGenerations : copy of the generation table, for takeover
+func (se *Session) exportGenerations() map[uint64]uint64 {
+
+	se.generationsLock.Lock()
+	defer se.generationsLock.Unlock()
+
+	if len(se.generations) == 0 {
+		return nil
+	}
+
+	dict := make(map[uint64]uint64, len(se.generations))
+	for nodeid, gen := range se.generations {
+		dict[nodeid] = gen
+	}
+
+	return dict
+}
+
+// importGenerations : restore the generation table handed over by takeover
+func (se *Session) importGenerations(dict map[uint64]uint64) {
+
+	se.generationsLock.Lock()
+	defer se.generationsLock.Unlock()
+
+	se.generations = make(map[uint64]uint64, len(dict))
+	for nodeid, gen := range dict {
+		se.generations[nodeid] = gen
+	}
+}
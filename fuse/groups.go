@@ -0,0 +1,87 @@
+package fuse
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+)
+
+// ErrNoGroups : the status of the caller has no "Groups:" line
+var ErrNoGroups = errors.New("no groups in process status")
+
+// Groups : return the supplementary groups of the caller, read from
+// "/proc/<Pid>/status" once for the request. The caller may have exited
+// or changed its groups since the request was sent, so it is only as good
+// as the pid. The pid is in the pid namespace of the fuse daemon.
+func (req Req) Groups() ([]uint32, error) {
+
+	if req.ctx == nil {
+		return readGroups(req.Pid)
+	}
+
+	req.ctx.groupsOnce.Do(func() {
+		req.ctx.groups, req.ctx.groupsErr = readGroups(req.Pid)
+	})
+
+	return req.ctx.groups, req.ctx.groupsErr
+}
+
+// InGroup : whether gid is the group or one of the supplementary groups of the caller
+func (req Req) InGroup(gid uint32) bool {
+
+	if req.Gid == gid {
+		return true
+	}
+
+	groups, err := req.Groups()
+	if err != nil {
+		return false
+	}
+
+	for _, g := range groups {
+		if g == gid {
+			return true
+		}
+	}
+
+	return false
+}
+
+func readGroups(pid uint32) ([]uint32, error) {
+
+	f, err := os.Open("/proc/" + strconv.FormatUint(uint64(pid), 10) + "/status")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	prefix := []byte("Groups:")
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !bytes.HasPrefix(line, prefix) {
+			continue
+		}
+
+		fields := bytes.Fields(line[len(prefix):])
+		groups := make([]uint32, 0, len(fields))
+		for _, field := range fields {
+			gid, err := strconv.ParseUint(string(field), 10, 32)
+			if err != nil {
+				return nil, err
+			}
+			groups = append(groups, uint32(gid))
+		}
+
+		return groups, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, ErrNoGroups
+}
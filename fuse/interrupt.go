@@ -0,0 +1,91 @@
+package fuse
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// reqContext : the state of a request shared with its interrupt and its copies
+type reqContext struct {
+	interrupted chan interface{}
+	once        sync.Once
+
+	// the supplementary groups of the caller, read once
+	groups     []uint32
+	groupsErr  error
+	groupsOnce sync.Once
+
+	// the reply of Async, set by the handler
+	reply *Reply
+
+	// the read buffer of the request, put back to the pool after
+	// the handler returns and, if it is async, the reply is sent
+	buf     *[]byte
+	bufRefs int32
+}
+
+// releaseBuf : drop a reference of the read buffer of request
+func (se *Session) releaseBuf(ctx *reqContext) {
+
+	if ctx == nil || ctx.buf == nil {
+		return
+	}
+
+	if atomic.AddInt32(&ctx.bufRefs, -1) == 0 {
+		se.putBuf(ctx.buf)
+	}
+}
+
+func newReqContext() *reqContext {
+	return &reqContext{interrupted: make(chan interface{})}
+}
+
+func (ctx *reqContext) interrupt() {
+	ctx.once.Do(func() {
+		close(ctx.interrupted)
+	})
+}
+
+// Interrupted : return a channel which is closed when the kernel
+// interrupts this request, e.g. the caller of a blocking lock got a signal.
+// Long running handlers should select on it and return errno.EINTR.
+func (req Req) Interrupted() <-chan interface{} {
+	if req.ctx == nil {
+		return nil
+	}
+	return req.ctx.interrupted
+}
+
+// registerReq : track the request until its handler returns, so that it can be interrupted
+func (se *Session) registerReq(req *Req) {
+
+	req.ctx = newReqContext()
+
+	se.reqsLock.Lock()
+	if se.reqs == nil {
+		se.reqs = make(map[uint64]*reqContext)
+	}
+	se.reqs[req.Unique] = req.ctx
+	se.reqsLock.Unlock()
+}
+
+func (se *Session) unregisterReq(req *Req) {
+
+	se.reqsLock.Lock()
+	delete(se.reqs, req.Unique)
+	se.reqsLock.Unlock()
+}
+
+// interruptReq : interrupt the request unique, return false if it is already done
+func (se *Session) interruptReq(unique uint64) bool {
+
+	se.reqsLock.Lock()
+	ctx, ok := se.reqs[unique]
+	se.reqsLock.Unlock()
+
+	if ok {
+		ctx.interrupt()
+	}
+
+	return ok
+}
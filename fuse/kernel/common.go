@@ -1,5 +1,7 @@
 package kernel
 
+import "encoding/binary"
+
 // FuseKernelVersion version number of this interface
 const FuseKernelVersion = 7
 
@@ -26,6 +28,31 @@ type FuseAttr struct {
 	Padding   uint32
 }
 
+// BinarySize : the length of FuseAttr in binary
+func (attr FuseAttr) BinarySize() int {
+	return 88
+}
+
+// MarshalTo : encode to b without allocation, b is at least BinarySize() bytes
+func (attr FuseAttr) MarshalTo(b []byte) {
+	binary.LittleEndian.PutUint64(b[0:], attr.Ino)
+	binary.LittleEndian.PutUint64(b[8:], attr.Size)
+	binary.LittleEndian.PutUint64(b[16:], attr.Blocks)
+	binary.LittleEndian.PutUint64(b[24:], attr.Atime)
+	binary.LittleEndian.PutUint64(b[32:], attr.Mtime)
+	binary.LittleEndian.PutUint64(b[40:], attr.Ctime)
+	binary.LittleEndian.PutUint32(b[48:], attr.AtimeNsec)
+	binary.LittleEndian.PutUint32(b[52:], attr.MtimeNsec)
+	binary.LittleEndian.PutUint32(b[56:], attr.CtimeNsec)
+	binary.LittleEndian.PutUint32(b[60:], attr.Mode)
+	binary.LittleEndian.PutUint32(b[64:], attr.Nlink)
+	binary.LittleEndian.PutUint32(b[68:], attr.UID)
+	binary.LittleEndian.PutUint32(b[72:], attr.GID)
+	binary.LittleEndian.PutUint32(b[76:], attr.Rdev)
+	binary.LittleEndian.PutUint32(b[80:], attr.Blksize)
+	binary.LittleEndian.PutUint32(b[84:], attr.Padding)
+}
+
 // FuseStatfs : the fuse statfs struct
 type FuseStatfs struct {
 	Blocks  uint64
@@ -40,6 +67,27 @@ type FuseStatfs struct {
 	Spare   [6]uint32
 }
 
+// BinarySize : the length of FuseStatfs in binary
+func (statfs FuseStatfs) BinarySize() int {
+	return 80
+}
+
+// MarshalTo : encode to b without allocation, b is at least BinarySize() bytes
+func (statfs FuseStatfs) MarshalTo(b []byte) {
+	binary.LittleEndian.PutUint64(b[0:], statfs.Blocks)
+	binary.LittleEndian.PutUint64(b[8:], statfs.Bfree)
+	binary.LittleEndian.PutUint64(b[16:], statfs.Bavail)
+	binary.LittleEndian.PutUint64(b[24:], statfs.Files)
+	binary.LittleEndian.PutUint64(b[32:], statfs.Ffree)
+	binary.LittleEndian.PutUint32(b[40:], statfs.Bsize)
+	binary.LittleEndian.PutUint32(b[44:], statfs.NameLen)
+	binary.LittleEndian.PutUint32(b[48:], statfs.Frsize)
+	binary.LittleEndian.PutUint32(b[52:], statfs.Padding)
+	for i, v := range statfs.Spare {
+		binary.LittleEndian.PutUint32(b[56+4*i:], v)
+	}
+}
+
 // FuseFileLock : the fuse file lock struct
 type FuseFileLock struct {
 	Start uint64
@@ -48,11 +96,120 @@ type FuseFileLock struct {
 	Pid   uint32 /* tgid */
 }
 
-// FluseIoctlIovec : the fuse ioctl iovec struct
-type FluseIoctlIovec struct {
-	base uint64
-	len  uint64
+// BinarySize : the length of FuseFileLock in binary
+func (lock FuseFileLock) BinarySize() int {
+	return 24
 }
 
+// MarshalTo : encode to b without allocation, b is at least BinarySize() bytes
+func (lock FuseFileLock) MarshalTo(b []byte) {
+	binary.LittleEndian.PutUint64(b[0:], lock.Start)
+	binary.LittleEndian.PutUint64(b[8:], lock.End)
+	binary.LittleEndian.PutUint32(b[16:], lock.Type)
+	binary.LittleEndian.PutUint32(b[20:], lock.Pid)
+}
+
+// FuseIoctlIovec : the fuse ioctl iovec struct, an area of the caller's memory
+type FuseIoctlIovec struct {
+	Base uint64
+	Len  uint64
+}
+
+// FluseIoctlIovec : Deprecated, use FuseIoctlIovec
+type FluseIoctlIovec = FuseIoctlIovec
+
 // FuseMinReadBuffer : The read buffer is required to be at least 8k, but may be much larger
 const FuseMinReadBuffer = 8192
+
+// FuseBackingMap : the argument of FuseDevIocBackingOpen
+type FuseBackingMap struct {
+	Fd      int32
+	Flags   uint32
+	Padding uint64
+}
+
+// FuseDevIocBackingOpen : ioctl of "/dev/fuse" to register a backing file, return the backing id
+const FuseDevIocBackingOpen = 0x4010e501
+
+// FuseDevIocBackingClose : ioctl of "/dev/fuse" to unregister a backing id
+const FuseDevIocBackingClose = 0x4004e502
+
+// FuseSxTime : the timestamp in FuseStatx
+type FuseSxTime struct {
+	TvSec    int64
+	TvNsec   uint32
+	Reserved int32
+}
+
+// BinarySize : the length of FuseSxTime in binary
+func (sxTime FuseSxTime) BinarySize() int {
+	return 16
+}
+
+// MarshalTo : encode to b without allocation, b is at least BinarySize() bytes
+func (sxTime FuseSxTime) MarshalTo(b []byte) {
+	binary.LittleEndian.PutUint64(b[0:], uint64(sxTime.TvSec))
+	binary.LittleEndian.PutUint32(b[8:], sxTime.TvNsec)
+	binary.LittleEndian.PutUint32(b[12:], uint32(sxTime.Reserved))
+}
+
+// FuseStatx : the fuse statx struct, the same layout as struct statx.
+// 256 bytes
+type FuseStatx struct {
+	Mask           uint32
+	Blksize        uint32
+	Attributes     uint64
+	Nlink          uint32
+	UID            uint32
+	GID            uint32
+	Mode           uint16
+	Spare0         uint16
+	Ino            uint64
+	Size           uint64
+	Blocks         uint64
+	AttributesMask uint64
+
+	Atime FuseSxTime
+	Btime FuseSxTime
+	Ctime FuseSxTime
+	Mtime FuseSxTime
+
+	RdevMajor uint32
+	RdevMinor uint32
+	DevMajor  uint32
+	DevMinor  uint32
+
+	Spare2 [14]uint64
+}
+
+// BinarySize : the length of FuseStatx in binary
+func (statx FuseStatx) BinarySize() int {
+	return 256
+}
+
+// MarshalTo : encode to b without allocation, b is at least BinarySize() bytes
+func (statx FuseStatx) MarshalTo(b []byte) {
+	binary.LittleEndian.PutUint32(b[0:], statx.Mask)
+	binary.LittleEndian.PutUint32(b[4:], statx.Blksize)
+	binary.LittleEndian.PutUint64(b[8:], statx.Attributes)
+	binary.LittleEndian.PutUint32(b[16:], statx.Nlink)
+	binary.LittleEndian.PutUint32(b[20:], statx.UID)
+	binary.LittleEndian.PutUint32(b[24:], statx.GID)
+	binary.LittleEndian.PutUint16(b[28:], statx.Mode)
+	binary.LittleEndian.PutUint16(b[30:], statx.Spare0)
+	binary.LittleEndian.PutUint64(b[32:], statx.Ino)
+	binary.LittleEndian.PutUint64(b[40:], statx.Size)
+	binary.LittleEndian.PutUint64(b[48:], statx.Blocks)
+	binary.LittleEndian.PutUint64(b[56:], statx.AttributesMask)
+	statx.Atime.MarshalTo(b[64:])
+	statx.Btime.MarshalTo(b[80:])
+	statx.Ctime.MarshalTo(b[96:])
+	statx.Mtime.MarshalTo(b[112:])
+	binary.LittleEndian.PutUint32(b[128:], statx.RdevMajor)
+	binary.LittleEndian.PutUint32(b[132:], statx.RdevMinor)
+	binary.LittleEndian.PutUint32(b[136:], statx.DevMajor)
+	binary.LittleEndian.PutUint32(b[140:], statx.DevMinor)
+	for i, v := range statx.Spare2 {
+		binary.LittleEndian.PutUint64(b[144+8*i:], v)
+	}
+}
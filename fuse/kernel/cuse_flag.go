@@ -0,0 +1,7 @@
+package kernel
+
+// CuseUnrestrictedIoctl : use unrestricted ioctl
+const CuseUnrestrictedIoctl = (1 << 0)
+
+// CuseInitInfoMax : the max length of device info in cuse_init response
+const CuseInitInfoMax = 4096
@@ -46,6 +46,7 @@ const (
 	FuseOpReaddirplus = 44
 	FuseOpRename2     = 45
 	FuseOpLseek       = 46
+	FuseOpStatx       = 52
 
 	/* CUSE specific operations */
 	CuseInit = 4096
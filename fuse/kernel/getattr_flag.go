@@ -0,0 +1,4 @@
+package kernel
+
+// FuseGetattrFh : getattr and statx flags, Fh is valid
+const FuseGetattrFh = (1 << 0)
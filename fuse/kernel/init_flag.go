@@ -24,6 +24,8 @@ package kernel
  * FuseParallelDirops: allow parallel lookups and readdir
  * FuseCapPosixACL: filesystem supports posix acls
  * FuseHandleKillPriv: fs handles killing suid/sgid/cap on write/chown/trunc
+ * FuseInitExt: extended fuse_init_in request, flags2 is valid
+ * FusePassthrough: passthrough read/write io for regular files to backing files
  */
 const (
 	FuseAsyncRead       = (1 << 0)
@@ -47,4 +49,6 @@ const (
 	FuseParallelDirops  = (1 << 18)
 	FuseCapPosixACL     = (1 << 19)
 	FuseHandleKillPriv  = (1 << 20)
+	FuseInitExt         = (1 << 30)
+	FusePassthrough     = (1 << 37)
 )
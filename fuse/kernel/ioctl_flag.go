@@ -15,5 +15,8 @@ const FuseIoctl32bit = (1 << 3)
 // FuseIoctlDir : is a directory
 const FuseIoctlDir = (1 << 4)
 
+// FuseIoctlCompatX32 : x32 compat ioctl on 64bit machine (64bit time_t)
+const FuseIoctlCompatX32 = (1 << 5)
+
 // FuseIoctlMaxIov : maximum of in_iovecs + out_iovecs
 const FuseIoctlMaxIov = 256
@@ -2,8 +2,7 @@ package kernel
 
 import (
 	"bytes"
-
-	"github.com/mingforpc/fuse-go/fuse/common"
+	"encoding/binary"
 )
 
 // FuseInHeader : each query starts with a FuseInHeader
@@ -18,11 +17,26 @@ type FuseInHeader struct {
 	Padding uint32
 }
 
+// InHeaderLen : length of FuseInHeader
+const InHeaderLen = 40
+
 // ParseBinary : Parse binary to FuseInHeader
 func (header *FuseInHeader) ParseBinary(bcontent []byte) error {
-	err := common.ParseBinary(bcontent, header)
 
-	return err
+	if len(bcontent) < InHeaderLen {
+		return ErrDataLen
+	}
+
+	header.Len = binary.LittleEndian.Uint32(bcontent[0:])
+	header.Opcode = binary.LittleEndian.Uint32(bcontent[4:])
+	header.Unique = binary.LittleEndian.Uint64(bcontent[8:])
+	header.Nodeid = binary.LittleEndian.Uint64(bcontent[16:])
+	header.UID = binary.LittleEndian.Uint32(bcontent[24:])
+	header.Gid = binary.LittleEndian.Uint32(bcontent[28:])
+	header.Pid = binary.LittleEndian.Uint32(bcontent[32:])
+	header.Padding = binary.LittleEndian.Uint32(bcontent[36:])
+
+	return nil
 }
 
 // FuseInitIn : init request
@@ -31,13 +45,39 @@ type FuseInitIn struct {
 	Minor        uint32
 	MaxReadahead uint32
 	Flags        uint32
+	Flags2       uint32 // only valid if Flags has FuseInitExt
+	Unused       [11]uint32
 }
 
 // ParseBinary : Parse binary to FuseInitIn
+// The kernel before 7.36 only sends the first 16 bytes
 func (init *FuseInitIn) ParseBinary(bcontent []byte) error {
-	err := common.ParseBinary(bcontent, init)
 
-	return err
+	length := len(bcontent)
+
+	if length < 16 {
+		return ErrDataLen
+	}
+
+	init.Major = binary.LittleEndian.Uint32(bcontent[0:])
+	init.Minor = binary.LittleEndian.Uint32(bcontent[4:])
+	init.MaxReadahead = binary.LittleEndian.Uint32(bcontent[8:])
+	init.Flags = binary.LittleEndian.Uint32(bcontent[12:])
+
+	if length >= 20 && (init.Flags&FuseInitExt) > 0 {
+		init.Flags2 = binary.LittleEndian.Uint32(bcontent[16:])
+	}
+
+	return nil
+}
+
+// AllFlags : Flags and Flags2 in one
+func (init *FuseInitIn) AllFlags() uint64 {
+	flags := uint64(init.Flags)
+	if (init.Flags & FuseInitExt) > 0 {
+		flags |= uint64(init.Flags2) << 32
+	}
+	return flags
 }
 
 // FuseGetattrIn : getattr request
@@ -49,9 +89,42 @@ type FuseGetattrIn struct {
 
 // ParseBinary : Parse binary to FuseGetattrIn
 func (getattr *FuseGetattrIn) ParseBinary(bcontent []byte) error {
-	err := common.ParseBinary(bcontent, getattr)
 
-	return err
+	if len(bcontent) < 16 {
+		return ErrDataLen
+	}
+
+	getattr.GetattrFlags = binary.LittleEndian.Uint32(bcontent[0:])
+	getattr.Dummy = binary.LittleEndian.Uint32(bcontent[4:])
+	getattr.Fh = binary.LittleEndian.Uint64(bcontent[8:])
+
+	return nil
+}
+
+// FuseStatxIn : statx request
+// 24 bytes
+type FuseStatxIn struct {
+	GetattrFlags uint32
+	Reserved     uint32
+	Fh           uint64
+	SxFlags      uint32 /* AT_STATX_* sync flags of statx(2) */
+	SxMask       uint32 /* STATX_* fields requested */
+}
+
+// ParseBinary : Parse binary to FuseStatxIn
+func (statx *FuseStatxIn) ParseBinary(bcontent []byte) error {
+
+	if len(bcontent) < 24 {
+		return ErrDataLen
+	}
+
+	statx.GetattrFlags = binary.LittleEndian.Uint32(bcontent[0:])
+	statx.Reserved = binary.LittleEndian.Uint32(bcontent[4:])
+	statx.Fh = binary.LittleEndian.Uint64(bcontent[8:])
+	statx.SxFlags = binary.LittleEndian.Uint32(bcontent[16:])
+	statx.SxMask = binary.LittleEndian.Uint32(bcontent[20:])
+
+	return nil
 }
 
 // FuseLookupIn : lookup request
@@ -61,14 +134,11 @@ type FuseLookupIn struct {
 
 // ParseBinary : Parse binary to FuseLoopIn
 func (lookup *FuseLookupIn) ParseBinary(bcontent []byte) error {
-	length := len(bcontent)
 
-	if length > 0 {
-		// avoid '\0'
-		lookup.Name = string(bcontent[:length-1])
-	}
+	name, _, err := parseName(bcontent)
+	lookup.Name = name
 
-	return nil
+	return err
 }
 
 // FuseForgetIn : forget request (should not send any reply)
@@ -78,9 +148,14 @@ type FuseForgetIn struct {
 
 // ParseBinary : Parse binary to FuseForgetIn
 func (forget *FuseForgetIn) ParseBinary(bcontent []byte) error {
-	err := common.ParseBinary(bcontent, forget)
 
-	return err
+	if len(bcontent) < 8 {
+		return ErrDataLen
+	}
+
+	forget.Nlookup = binary.LittleEndian.Uint64(bcontent[0:])
+
+	return nil
 }
 
 // FuseSetattrIn : setattr request
@@ -105,9 +180,29 @@ type FuseSetattrIn struct {
 
 // ParseBinary : Parse FuseInHeader to binary
 func (setattr *FuseSetattrIn) ParseBinary(bcontent []byte) error {
-	err := common.ParseBinary(bcontent, setattr)
 
-	return err
+	if len(bcontent) < 88 {
+		return ErrDataLen
+	}
+
+	setattr.Valid = binary.LittleEndian.Uint32(bcontent[0:])
+	setattr.Padding = binary.LittleEndian.Uint32(bcontent[4:])
+	setattr.Fh = binary.LittleEndian.Uint64(bcontent[8:])
+	setattr.Size = binary.LittleEndian.Uint64(bcontent[16:])
+	setattr.LockOwner = binary.LittleEndian.Uint64(bcontent[24:])
+	setattr.Atime = binary.LittleEndian.Uint64(bcontent[32:])
+	setattr.Mtime = binary.LittleEndian.Uint64(bcontent[40:])
+	setattr.Ctime = binary.LittleEndian.Uint64(bcontent[48:])
+	setattr.AtimeNsec = binary.LittleEndian.Uint32(bcontent[56:])
+	setattr.MtimeNsec = binary.LittleEndian.Uint32(bcontent[60:])
+	setattr.CtimeNsec = binary.LittleEndian.Uint32(bcontent[64:])
+	setattr.Mode = binary.LittleEndian.Uint32(bcontent[68:])
+	setattr.Unused4 = binary.LittleEndian.Uint32(bcontent[72:])
+	setattr.UID = binary.LittleEndian.Uint32(bcontent[76:])
+	setattr.Gid = binary.LittleEndian.Uint32(bcontent[80:])
+	setattr.Unused5 = binary.LittleEndian.Uint32(bcontent[84:])
+
+	return nil
 }
 
 // FuseMknodIn : mknod request
@@ -129,14 +224,15 @@ func (mknod *FuseMknodIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	common.ParseBinary(bcontent[0:4], &mknod.Mode)
-	common.ParseBinary(bcontent[4:8], &mknod.Rdev)
-	common.ParseBinary(bcontent[8:12], &mknod.Umask)
-	common.ParseBinary(bcontent[12:16], &mknod.Padding)
+	mknod.Mode = binary.LittleEndian.Uint32(bcontent[0:])
+	mknod.Rdev = binary.LittleEndian.Uint32(bcontent[4:])
+	mknod.Umask = binary.LittleEndian.Uint32(bcontent[8:])
+	mknod.Padding = binary.LittleEndian.Uint32(bcontent[12:])
 
-	mknod.Name = string(bcontent[16 : length-1])
+	name, _, err := parseName(bcontent[16:])
+	mknod.Name = name
 
-	return nil
+	return err
 }
 
 // FuseMkdirIn : mkdir request
@@ -156,12 +252,13 @@ func (mkdir *FuseMkdirIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	common.ParseBinary(bcontent[0:4], &mkdir.Mode)
-	common.ParseBinary(bcontent[4:8], &mkdir.Umask)
+	mkdir.Mode = binary.LittleEndian.Uint32(bcontent[0:])
+	mkdir.Umask = binary.LittleEndian.Uint32(bcontent[4:])
 
-	mkdir.Name = string(bcontent[8 : length-1])
+	name, _, err := parseName(bcontent[8:])
+	mkdir.Name = name
 
-	return nil
+	return err
 }
 
 // FuseRmdirIn : rmdir request
@@ -172,9 +269,10 @@ type FuseRmdirIn struct {
 // ParseBinary : Parse binary to FuseRmdirIn
 func (rmdir *FuseRmdirIn) ParseBinary(bcontent []byte) error {
 
-	length := len(bcontent)
-	rmdir.Path = string(bcontent[:length-1])
-	return nil
+	name, _, err := parseName(bcontent)
+	rmdir.Path = name
+
+	return err
 }
 
 // FuseUnlinkIn : unlink request
@@ -185,9 +283,10 @@ type FuseUnlinkIn struct {
 // ParseBinary : Parse binary to FuseUnlinkIn
 func (unlink *FuseUnlinkIn) ParseBinary(bcontent []byte) error {
 
-	length := len(bcontent)
-	unlink.Path = string(bcontent[:length-1])
-	return nil
+	name, _, err := parseName(bcontent)
+	unlink.Path = name
+
+	return err
 }
 
 // FuseSymlinkIn : symlink request
@@ -200,14 +299,18 @@ type FuseSymlinkIn struct {
 // ParseBinary : Parse binary to FuseSymlinkIn
 func (symlink *FuseSymlinkIn) ParseBinary(bcontent []byte) error {
 
-	array := bytes.Split(bcontent, []byte{0})
+	name, rest, err := parseName(bcontent)
+	if err != nil {
+		return err
+	}
 
-	if len(array) < 2 {
-		return ErrDataLen
+	linkName, _, err := parseName(rest)
+	if err != nil {
+		return err
 	}
 
-	symlink.Name = string(array[0])
-	symlink.LinkName = string(array[1])
+	symlink.Name = name
+	symlink.LinkName = linkName
 
 	return nil
 }
@@ -230,22 +333,9 @@ func (rename *FuseRenameIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	err := common.ParseBinary(bcontent[:8], &rename.NewDir)
+	rename.NewDir = binary.LittleEndian.Uint64(bcontent[0:])
 
-	if err != nil {
-		return err
-	}
-
-	array := bytes.Split(bcontent[8:], []byte{0})
-
-	if len(array) < 2 {
-		return ErrDataLen
-	}
-
-	rename.OldName = string(array[0])
-	rename.NewName = string(array[1])
-
-	return nil
+	return parseNames(bcontent[8:], &rename.OldName, &rename.NewName)
 }
 
 // FuseLinkIn : link request
@@ -264,15 +354,12 @@ func (link *FuseLinkIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	err := common.ParseBinary(bcontent[:8], &link.OldNodeid)
+	link.OldNodeid = binary.LittleEndian.Uint64(bcontent[0:])
 
-	if err != nil {
-		return err
-	}
-
-	link.NewName = string(bcontent[8 : length-1])
+	name, _, err := parseName(bcontent[8:])
+	link.NewName = name
 
-	return nil
+	return err
 }
 
 // FuseOpenIn : open, opendir request
@@ -290,9 +377,10 @@ func (open *FuseOpenIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	err := common.ParseBinary(bcontent, open)
+	open.Flags = binary.LittleEndian.Uint32(bcontent[0:])
+	open.Unused = binary.LittleEndian.Uint32(bcontent[4:])
 
-	return err
+	return nil
 }
 
 // FuseReadIn : read, readdir request
@@ -315,9 +403,15 @@ func (read *FuseReadIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	err := common.ParseBinary(bcontent, read)
+	read.Fh = binary.LittleEndian.Uint64(bcontent[0:])
+	read.Offset = binary.LittleEndian.Uint64(bcontent[8:])
+	read.Size = binary.LittleEndian.Uint32(bcontent[16:])
+	read.ReadFlags = binary.LittleEndian.Uint32(bcontent[20:])
+	read.LockOwner = binary.LittleEndian.Uint64(bcontent[24:])
+	read.Flags = binary.LittleEndian.Uint32(bcontent[32:])
+	read.Padding = binary.LittleEndian.Uint32(bcontent[36:])
 
-	return err
+	return nil
 }
 
 // FuseWriteIn : write request
@@ -342,15 +436,21 @@ func (write *FuseWriteIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	common.ParseBinary(bcontent[0:8], &write.Fh)
-	common.ParseBinary(bcontent[8:16], &write.Offset)
-	common.ParseBinary(bcontent[16:20], &write.Size)
-	common.ParseBinary(bcontent[20:24], &write.WriteFlags)
-	common.ParseBinary(bcontent[24:32], &write.LockOwner)
-	common.ParseBinary(bcontent[32:36], &write.Flags)
-	common.ParseBinary(bcontent[36:40], &write.Padding)
+	write.Fh = binary.LittleEndian.Uint64(bcontent[0:])
+	write.Offset = binary.LittleEndian.Uint64(bcontent[8:])
+	write.Size = binary.LittleEndian.Uint32(bcontent[16:])
+	write.WriteFlags = binary.LittleEndian.Uint32(bcontent[20:])
+	write.LockOwner = binary.LittleEndian.Uint64(bcontent[24:])
+	write.Flags = binary.LittleEndian.Uint32(bcontent[32:])
+	write.Padding = binary.LittleEndian.Uint32(bcontent[36:])
 
+	// not copied, Buf is in the request buffer, which is kept until the
+	// request is replied. Other parsers copy what they keep of it
 	write.Buf = bcontent[40:]
+	if uint64(len(write.Buf)) < uint64(write.Size) {
+		return ErrDataLen
+	}
+	write.Buf = write.Buf[:write.Size]
 
 	return nil
 }
@@ -372,9 +472,12 @@ func (release *FuseReleaseIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	err := common.ParseBinary(bcontent, release)
+	release.Fh = binary.LittleEndian.Uint64(bcontent[0:])
+	release.Flags = binary.LittleEndian.Uint32(bcontent[8:])
+	release.ReleaseFlags = binary.LittleEndian.Uint32(bcontent[12:])
+	release.LockOwner = binary.LittleEndian.Uint64(bcontent[16:])
 
-	return err
+	return nil
 }
 
 // FuseFsyncIn : fsync, fsyncdir request
@@ -393,9 +496,11 @@ func (fsync *FuseFsyncIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	err := common.ParseBinary(bcontent, fsync)
+	fsync.Fh = binary.LittleEndian.Uint64(bcontent[0:])
+	fsync.FsyncFlags = binary.LittleEndian.Uint32(bcontent[8:])
+	fsync.Padding = binary.LittleEndian.Uint32(bcontent[12:])
 
-	return err
+	return nil
 }
 
 // FuseSetxattrIn : setxattr
@@ -404,10 +509,11 @@ type FuseSetxattrIn struct {
 	Flags uint32
 
 	Name  string
-	Value string
+	Value []byte
 }
 
-// ParseBinary : Parse binary to FuseSetxattrIn
+// ParseBinary : Parse binary to FuseSetxattrIn,
+// the value is binary and its length is Size
 func (setxattr *FuseSetxattrIn) ParseBinary(bcontent []byte) error {
 
 	length := len(bcontent)
@@ -416,17 +522,21 @@ func (setxattr *FuseSetxattrIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	common.ParseBinary(bcontent[:4], &setxattr.Size)
-	common.ParseBinary(bcontent[4:8], &setxattr.Flags)
+	setxattr.Size = binary.LittleEndian.Uint32(bcontent[0:])
+	setxattr.Flags = binary.LittleEndian.Uint32(bcontent[4:])
 
-	array := bytes.Split(bcontent[8:], []byte{0})
+	name, value, err := parseName(bcontent[8:])
+	if err != nil {
+		return err
+	}
 
-	if len(array) < 2 {
+	if uint64(len(value)) < uint64(setxattr.Size) {
 		return ErrDataLen
 	}
 
-	setxattr.Name = string(array[0])
-	setxattr.Value = string(array[1])
+	setxattr.Name = name
+	setxattr.Value = make([]byte, setxattr.Size)
+	copy(setxattr.Value, value)
 
 	return nil
 }
@@ -448,11 +558,14 @@ func (getxattr *FuseGetxattrIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	common.ParseBinary(bcontent[:4], &getxattr.Size)
-	common.ParseBinary(bcontent[4:8], &getxattr.Padding)
+	getxattr.Size = binary.LittleEndian.Uint32(bcontent[0:])
+	getxattr.Padding = binary.LittleEndian.Uint32(bcontent[4:])
 
+	// listxattr has no name
 	if length > 8 {
-		getxattr.Name = string(bcontent[8 : length-1])
+		name, _, err := parseName(bcontent[8:])
+		getxattr.Name = name
+		return err
 	}
 
 	return nil
@@ -466,12 +579,10 @@ type FuseRemovexattrIn struct {
 // ParseBinary : Parse binary to FuseRemovexattrIn
 func (removexattr *FuseRemovexattrIn) ParseBinary(bcontent []byte) error {
 
-	length := len(bcontent)
-	if length > 0 {
-		removexattr.Name = string(bcontent[:length-1])
-	}
+	name, _, err := parseName(bcontent)
+	removexattr.Name = name
 
-	return nil
+	return err
 }
 
 // FuseFlushIn : flush request
@@ -491,7 +602,10 @@ func (flush *FuseFlushIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	common.ParseBinary(bcontent, flush)
+	flush.Fh = binary.LittleEndian.Uint64(bcontent[0:])
+	flush.Unused = binary.LittleEndian.Uint32(bcontent[8:])
+	flush.Padding = binary.LittleEndian.Uint32(bcontent[12:])
+	flush.LockOwner = binary.LittleEndian.Uint64(bcontent[16:])
 
 	return nil
 }
@@ -514,7 +628,14 @@ func (lk *FuseLkIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	common.ParseBinary(bcontent, lk)
+	lk.Fh = binary.LittleEndian.Uint64(bcontent[0:])
+	lk.Owner = binary.LittleEndian.Uint64(bcontent[8:])
+	lk.Lk.Start = binary.LittleEndian.Uint64(bcontent[16:])
+	lk.Lk.End = binary.LittleEndian.Uint64(bcontent[24:])
+	lk.Lk.Type = binary.LittleEndian.Uint32(bcontent[32:])
+	lk.Lk.Pid = binary.LittleEndian.Uint32(bcontent[36:])
+	lk.LkFlags = binary.LittleEndian.Uint32(bcontent[40:])
+	lk.Padding = binary.LittleEndian.Uint32(bcontent[44:])
 
 	return nil
 }
@@ -534,7 +655,8 @@ func (access *FuseAccessIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	common.ParseBinary(bcontent, access)
+	access.Mask = binary.LittleEndian.Uint32(bcontent[0:])
+	access.Padding = binary.LittleEndian.Uint32(bcontent[4:])
 
 	return nil
 }
@@ -558,15 +680,15 @@ func (create *FuseCreateIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	common.ParseBinary(bcontent[:4], &create.Flags)
-	common.ParseBinary(bcontent[4:8], &create.Mode)
-	common.ParseBinary(bcontent[8:12], &create.Umask)
-	common.ParseBinary(bcontent[12:16], &create.Padding)
+	create.Flags = binary.LittleEndian.Uint32(bcontent[0:])
+	create.Mode = binary.LittleEndian.Uint32(bcontent[4:])
+	create.Umask = binary.LittleEndian.Uint32(bcontent[8:])
+	create.Padding = binary.LittleEndian.Uint32(bcontent[12:])
 
-	// length-1 是为了避开最后一个'\0'字符
-	create.Name = string(bcontent[16 : length-1])
+	name, _, err := parseName(bcontent[16:])
+	create.Name = name
 
-	return nil
+	return err
 }
 
 // FuseInterruptIn : interrupt request
@@ -583,9 +705,9 @@ func (interrupt *FuseInterruptIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	err := common.ParseBinary(bcontent, interrupt)
+	interrupt.Unique = binary.LittleEndian.Uint64(bcontent[0:])
 
-	return err
+	return nil
 }
 
 // FuseBmapIn : bmap request
@@ -604,9 +726,11 @@ func (bmap *FuseBmapIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	err := common.ParseBinary(bcontent, bmap)
+	bmap.Block = binary.LittleEndian.Uint64(bcontent[0:])
+	bmap.BlockSize = binary.LittleEndian.Uint32(bcontent[8:])
+	bmap.Padding = binary.LittleEndian.Uint32(bcontent[12:])
 
-	return err
+	return nil
 }
 
 // FuseIoctlIn : ioctl request
@@ -630,14 +754,21 @@ func (ioctl *FuseIoctlIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	common.ParseBinary(bcontent[:8], &ioctl.Fh)
-	common.ParseBinary(bcontent[8:12], &ioctl.Flags)
-	common.ParseBinary(bcontent[12:16], &ioctl.Cmd)
-	common.ParseBinary(bcontent[16:24], &ioctl.Arg)
-	common.ParseBinary(bcontent[24:28], &ioctl.InSize)
-	common.ParseBinary(bcontent[28:32], &ioctl.OutSize)
+	ioctl.Fh = binary.LittleEndian.Uint64(bcontent[0:])
+	ioctl.Flags = binary.LittleEndian.Uint32(bcontent[8:])
+	ioctl.Cmd = binary.LittleEndian.Uint32(bcontent[12:])
+	ioctl.Arg = binary.LittleEndian.Uint64(bcontent[16:])
+	ioctl.InSize = binary.LittleEndian.Uint32(bcontent[24:])
+	ioctl.OutSize = binary.LittleEndian.Uint32(bcontent[28:])
 
-	ioctl.InBuf = bcontent[32:]
+	inBuf := bcontent[32:]
+	if uint32(len(inBuf)) > ioctl.InSize {
+		inBuf = inBuf[:ioctl.InSize]
+	}
+
+	// copied, the request buffer is reused
+	ioctl.InBuf = make([]byte, len(inBuf))
+	copy(ioctl.InBuf, inBuf)
 
 	return nil
 }
@@ -659,9 +790,12 @@ func (poll *FusePollIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	err := common.ParseBinary(bcontent, poll)
+	poll.Fh = binary.LittleEndian.Uint64(bcontent[0:])
+	poll.Kh = binary.LittleEndian.Uint64(bcontent[8:])
+	poll.Flags = binary.LittleEndian.Uint32(bcontent[16:])
+	poll.Events = binary.LittleEndian.Uint32(bcontent[20:])
 
-	return err
+	return nil
 }
 
 // FuseForgetOne : the inode to fotget
@@ -687,15 +821,20 @@ func (forget *FuseBatchForgetIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	common.ParseBinary(bcontent[4:8], &forget.Dummy)
+	forget.Count = binary.LittleEndian.Uint32(bcontent[0:])
+	forget.Dummy = binary.LittleEndian.Uint32(bcontent[4:])
+
+	// each FuseForgetOne is 16 bytes after the 8 bytes header
+	if uint64(length-8)/16 < uint64(forget.Count) {
+		return ErrDataLen
+	}
 
 	forget.NodeList = make([]FuseForgetOne, forget.Count)
-	var i uint32
-	for i = 0; i < forget.Count; i++ {
+	for i := range forget.NodeList {
 
-		var temp = FuseForgetOne{}
-		common.ParseBinary(bcontent[8*(i+1):16*(i+1)], &temp)
-		forget.NodeList[i] = temp
+		one := bcontent[8+16*i:]
+		forget.NodeList[i].Nodeid = binary.LittleEndian.Uint64(one[0:])
+		forget.NodeList[i].Nlookup = binary.LittleEndian.Uint64(one[8:])
 	}
 
 	return nil
@@ -719,9 +858,13 @@ func (fallocate *FuseFallocateIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	err := common.ParseBinary(bcontent, fallocate)
+	fallocate.Fh = binary.LittleEndian.Uint64(bcontent[0:])
+	fallocate.Offset = binary.LittleEndian.Uint64(bcontent[8:])
+	fallocate.Length = binary.LittleEndian.Uint64(bcontent[16:])
+	fallocate.Mode = binary.LittleEndian.Uint32(bcontent[24:])
+	fallocate.Padding = binary.LittleEndian.Uint32(bcontent[28:])
 
-	return err
+	return nil
 }
 
 // FuseRename2In : rename2 request
@@ -743,20 +886,11 @@ func (rename *FuseRename2In) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	common.ParseBinary(bcontent[:8], &rename.NewDir)
-	common.ParseBinary(bcontent[8:12], &rename.Flags)
-	common.ParseBinary(bcontent[12:16], &rename.Padding)
-
-	array := bytes.Split(bcontent[16:], []byte{0})
+	rename.NewDir = binary.LittleEndian.Uint64(bcontent[0:])
+	rename.Flags = binary.LittleEndian.Uint32(bcontent[8:])
+	rename.Padding = binary.LittleEndian.Uint32(bcontent[12:])
 
-	if len(array) < 2 {
-		return ErrDataLen
-	}
-
-	rename.OldName = string(array[0])
-	rename.NewName = string(array[1])
-
-	return nil
+	return parseNames(bcontent[16:], &rename.OldName, &rename.NewName)
 }
 
 // FuseLseekIn : lseek request
@@ -776,9 +910,12 @@ func (lseek *FuseLseekIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	err := common.ParseBinary(bcontent, lseek)
+	lseek.Fh = binary.LittleEndian.Uint64(bcontent[0:])
+	lseek.Offset = binary.LittleEndian.Uint64(bcontent[8:])
+	lseek.Whence = binary.LittleEndian.Uint32(bcontent[16:])
+	lseek.Padding = binary.LittleEndian.Uint32(bcontent[20:])
 
-	return err
+	return nil
 }
 
 // CuseInitIn : cuse_init request
@@ -798,7 +935,40 @@ func (cuseInit *CuseInitIn) ParseBinary(bcontent []byte) error {
 		return ErrDataLen
 	}
 
-	err := common.ParseBinary(bcontent, cuseInit)
+	cuseInit.Major = binary.LittleEndian.Uint32(bcontent[0:])
+	cuseInit.Minor = binary.LittleEndian.Uint32(bcontent[4:])
+	cuseInit.Unused = binary.LittleEndian.Uint32(bcontent[8:])
+	cuseInit.Flags = binary.LittleEndian.Uint32(bcontent[12:])
 
-	return err
+	return nil
+}
+
+// parseName : parse the name terminated by '\0' at the start of bcontent,
+// return the name and the bytes after '\0'
+func parseName(bcontent []byte) (string, []byte, error) {
+
+	end := bytes.IndexByte(bcontent, 0)
+	if end < 0 {
+		return "", nil, ErrDataLen
+	}
+
+	return string(bcontent[:end]), bcontent[end+1:], nil
+}
+
+// parseNames : parse the two names of rename
+func parseNames(bcontent []byte, oldName *string, newName *string) error {
+
+	name, rest, err := parseName(bcontent)
+	if err != nil {
+		return err
+	}
+
+	*newName, _, err = parseName(rest)
+	if err != nil {
+		return err
+	}
+
+	*oldName = name
+
+	return nil
 }
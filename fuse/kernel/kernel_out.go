@@ -15,6 +15,21 @@ type FuseResponsor interface {
 	ToBinary() ([]byte, error)
 }
 
+// FuseFixedResponsor : the response of fixed size, which is encoded
+// into the reply buffer by MarshalTo without allocation or reflection
+type FuseFixedResponsor interface {
+	FuseResponsor
+	BinarySize() int
+	MarshalTo(b []byte)
+}
+
+// FuseVecResponsor : the response of data in pieces, which are written
+// after the header as an iovec without being joined
+type FuseVecResponsor interface {
+	FuseResponsor
+	Buffers() [][]byte
+}
+
 // FuseOutHeader : the header of response,
 // each answer starts with this.
 // 16 bytes
@@ -26,7 +41,23 @@ type FuseOutHeader struct {
 
 // ToBinary : Parse to binary
 func (outHeader FuseOutHeader) ToBinary() ([]byte, error) {
-	return common.ToBinary(outHeader)
+
+	b := make([]byte, outHeader.BinarySize())
+	outHeader.MarshalTo(b)
+
+	return b, nil
+}
+
+// BinarySize : the length of FuseOutHeader in binary
+func (outHeader FuseOutHeader) BinarySize() int {
+	return 16
+}
+
+// MarshalTo : encode to b without allocation, b is at least BinarySize() bytes
+func (outHeader FuseOutHeader) MarshalTo(b []byte) {
+	binary.LittleEndian.PutUint32(b[0:], outHeader.Len)
+	binary.LittleEndian.PutUint32(b[4:], uint32(outHeader.Error))
+	binary.LittleEndian.PutUint64(b[8:], outHeader.Unique)
 }
 
 // FuseInitOut : init response
@@ -42,12 +73,44 @@ type FuseInitOut struct {
 	CongestionThreshold uint16
 	MaxWrite            uint32
 	TimeGran            uint32
-	Unused              [9]uint32
+	MaxPages            uint16
+	MapAlignment        uint16
+	Flags2              uint32 // the high 32 bits of flags, with FuseInitExt in Flags
+	MaxStackDepth       uint32
+	Unused              [6]uint32
 }
 
 // ToBinary : Parse to binary
 func (init FuseInitOut) ToBinary() ([]byte, error) {
-	return common.ToBinary(init)
+
+	b := make([]byte, init.BinarySize())
+	init.MarshalTo(b)
+
+	return b, nil
+}
+
+// BinarySize : the length of FuseInitOut in binary
+func (init FuseInitOut) BinarySize() int {
+	return 64
+}
+
+// MarshalTo : encode to b without allocation, b is at least BinarySize() bytes
+func (init FuseInitOut) MarshalTo(b []byte) {
+	binary.LittleEndian.PutUint32(b[0:], init.Major)
+	binary.LittleEndian.PutUint32(b[4:], init.Minor)
+	binary.LittleEndian.PutUint32(b[8:], init.MaxReadahead)
+	binary.LittleEndian.PutUint32(b[12:], init.Flags)
+	binary.LittleEndian.PutUint16(b[16:], init.MaxBackground)
+	binary.LittleEndian.PutUint16(b[18:], init.CongestionThreshold)
+	binary.LittleEndian.PutUint32(b[20:], init.MaxWrite)
+	binary.LittleEndian.PutUint32(b[24:], init.TimeGran)
+	binary.LittleEndian.PutUint16(b[28:], init.MaxPages)
+	binary.LittleEndian.PutUint16(b[30:], init.MapAlignment)
+	binary.LittleEndian.PutUint32(b[32:], init.Flags2)
+	binary.LittleEndian.PutUint32(b[36:], init.MaxStackDepth)
+	for i, v := range init.Unused {
+		binary.LittleEndian.PutUint32(b[40+4*i:], v)
+	}
 }
 
 // FuseAttrOut : getattr, setattr response
@@ -61,7 +124,60 @@ type FuseAttrOut struct {
 
 // ToBinary : Parse to binary
 func (attr FuseAttrOut) ToBinary() ([]byte, error) {
-	return common.ToBinary(attr)
+
+	b := make([]byte, attr.BinarySize())
+	attr.MarshalTo(b)
+
+	return b, nil
+}
+
+// BinarySize : the length of FuseAttrOut in binary
+func (attr FuseAttrOut) BinarySize() int {
+	return 104
+}
+
+// MarshalTo : encode to b without allocation, b is at least BinarySize() bytes
+func (attr FuseAttrOut) MarshalTo(b []byte) {
+	binary.LittleEndian.PutUint64(b[0:], attr.AttrValid)
+	binary.LittleEndian.PutUint32(b[8:], attr.AttrValidNsec)
+	binary.LittleEndian.PutUint32(b[12:], attr.Dummp)
+	attr.Attr.MarshalTo(b[16:])
+}
+
+// FuseStatxOut : statx response
+// 288 bytes
+type FuseStatxOut struct {
+	AttrValid     uint64 /* Cache timeout for the attributes */
+	AttrValidNsec uint32
+	Flags         uint32
+	Spare         [2]uint64
+
+	Stat FuseStatx
+}
+
+// ToBinary : Parse to binary
+func (statx FuseStatxOut) ToBinary() ([]byte, error) {
+
+	b := make([]byte, statx.BinarySize())
+	statx.MarshalTo(b)
+
+	return b, nil
+}
+
+// BinarySize : the length of FuseStatxOut in binary
+func (statx FuseStatxOut) BinarySize() int {
+	return 288
+}
+
+// MarshalTo : encode to b without allocation, b is at least BinarySize() bytes
+func (statx FuseStatxOut) MarshalTo(b []byte) {
+	binary.LittleEndian.PutUint64(b[0:], statx.AttrValid)
+	binary.LittleEndian.PutUint32(b[8:], statx.AttrValidNsec)
+	binary.LittleEndian.PutUint32(b[12:], statx.Flags)
+	for i, v := range statx.Spare {
+		binary.LittleEndian.PutUint64(b[16+8*i:], v)
+	}
+	statx.Stat.MarshalTo(b[32:])
 }
 
 // FuseReadlinkOut : readlink response
@@ -96,7 +212,26 @@ type FuseEntryOut struct {
 // ToBinary : Parse to binary
 func (entry FuseEntryOut) ToBinary() ([]byte, error) {
 
-	return common.ToBinary(entry)
+	b := make([]byte, entry.BinarySize())
+	entry.MarshalTo(b)
+
+	return b, nil
+}
+
+// BinarySize : the length of FuseEntryOut in binary
+func (entry FuseEntryOut) BinarySize() int {
+	return 128
+}
+
+// MarshalTo : encode to b without allocation, b is at least BinarySize() bytes
+func (entry FuseEntryOut) MarshalTo(b []byte) {
+	binary.LittleEndian.PutUint64(b[0:], entry.NodeID)
+	binary.LittleEndian.PutUint64(b[8:], entry.Generation)
+	binary.LittleEndian.PutUint64(b[16:], entry.EntryValid)
+	binary.LittleEndian.PutUint64(b[24:], entry.AttrValid)
+	binary.LittleEndian.PutUint32(b[32:], entry.EntryValidNsec)
+	binary.LittleEndian.PutUint32(b[36:], entry.AttrValidNsec)
+	entry.Attr.MarshalTo(b[40:])
 }
 
 // FuseCreateOut : create response
@@ -109,37 +244,86 @@ type FuseCreateOut struct {
 // ToBinary : Parse to binary
 func (create FuseCreateOut) ToBinary() ([]byte, error) {
 
-	return common.ToBinary(create)
+	b := make([]byte, create.BinarySize())
+	create.MarshalTo(b)
+
+	return b, nil
+}
+
+// BinarySize : the length of FuseCreateOut in binary
+func (create FuseCreateOut) BinarySize() int {
+	return 144
+}
+
+// MarshalTo : encode to b without allocation, b is at least BinarySize() bytes
+func (create FuseCreateOut) MarshalTo(b []byte) {
+	create.Entry.MarshalTo(b[0:])
+	create.Open.MarshalTo(b[128:])
 }
 
 // FuseOpenOut : open, opendir response
 type FuseOpenOut struct {
 	Fh        uint64
 	OpenFlags uint32
-	Padding   uint32
+	BackingID int32 // with FOPEN_PASSTHROUGH, the id of the backing file
 }
 
 // ToBinary : Parse to binary
 func (open FuseOpenOut) ToBinary() ([]byte, error) {
 
-	return common.ToBinary(open)
+	b := make([]byte, open.BinarySize())
+	open.MarshalTo(b)
+
+	return b, nil
+}
+
+// BinarySize : the length of FuseOpenOut in binary
+func (open FuseOpenOut) BinarySize() int {
+	return 16
+}
+
+// MarshalTo : encode to b without allocation, b is at least BinarySize() bytes
+func (open FuseOpenOut) MarshalTo(b []byte) {
+	binary.LittleEndian.PutUint64(b[0:], open.Fh)
+	binary.LittleEndian.PutUint32(b[8:], open.OpenFlags)
+	binary.LittleEndian.PutUint32(b[12:], uint32(open.BackingID))
 }
 
 // FuseReadOut : read, readdir response
 type FuseReadOut struct {
 	Content []byte
+
+	// Vec : the content in pieces, used instead of Content if not nil
+	Vec [][]byte
 }
 
 // ToBinary : Parse to binary
 func (read FuseReadOut) ToBinary() ([]byte, error) {
 
+	if read.Vec != nil {
+		return bytes.Join(read.Vec, nil), nil
+	}
+
 	return read.Content, nil
 }
 
+// Buffers : the content to write after the header
+func (read FuseReadOut) Buffers() [][]byte {
+
+	if read.Vec != nil {
+		return read.Vec
+	}
+	if len(read.Content) == 0 {
+		return nil
+	}
+
+	return [][]byte{read.Content}
+}
+
 // FuseDirent : 目录的结构体，二进制方式写入readdir的Content中
 type FuseDirent struct {
 	Ino     uint64
-	Off     uint64
+	Off     uint64 // the cookie to continue reading after this entry
 	NameLen uint32
 	DirType uint32
 	Name    string
@@ -154,26 +338,69 @@ func fuseDirentAlign(entlent uint64) uint64 {
 	return (entlent + 8 - 1) & (^uint64(7))
 }
 
+// DirentSize : the length of FuseDirent in binary with name length namelen
+func DirentSize(namelen uint32) uint64 {
+
+	return fuseDirentAlign(direntNameOffset + uint64(namelen))
+}
+
 // ToBinary : Parse to binary
-// ToBinary将FuseDirent转为二进制数据, preOff是在list里面，上一个的偏移量
-func (dirent *FuseDirent) ToBinary(preOff *uint64) ([]byte, error) {
+func (dirent *FuseDirent) ToBinary() ([]byte, error) {
 
-	entLen := fuseDirentAlign(direntNameOffset + uint64(dirent.NameLen))
+	b := make([]byte, DirentSize(dirent.NameLen))
+	dirent.MarshalTo(b)
 
-	buf := bytes.NewBuffer(nil)
+	return b, nil
+}
 
-	*preOff += entLen
+// MarshalTo : encode to b without allocation, b is at least
+// DirentSize(NameLen) bytes, the padding is zeroed
+func (dirent *FuseDirent) MarshalTo(b []byte) {
 
-	binary.Write(buf, binary.LittleEndian, dirent.Ino)
-	binary.Write(buf, binary.LittleEndian, *preOff)
-	binary.Write(buf, binary.LittleEndian, dirent.NameLen)
-	binary.Write(buf, binary.LittleEndian, dirent.DirType)
+	entLen := DirentSize(dirent.NameLen)
 
-	buf.WriteString(dirent.Name)
+	binary.LittleEndian.PutUint64(b[0:], dirent.Ino)
+	binary.LittleEndian.PutUint64(b[8:], dirent.Off)
+	binary.LittleEndian.PutUint32(b[16:], dirent.NameLen)
+	binary.LittleEndian.PutUint32(b[20:], dirent.DirType)
 
-	buf.Write(make([]byte, int(entLen)-buf.Len()))
+	n := direntNameOffset + copy(b[direntNameOffset:entLen], dirent.Name)
+	for i := n; i < int(entLen); i++ {
+		b[i] = 0
+	}
+}
 
-	return buf.Bytes(), nil
+// fuseEntryOutSize : the length of FuseEntryOut in binary
+const fuseEntryOutSize = 128
+
+// DirentplusSize : the length of FuseDirentplus in binary with name length namelen
+func DirentplusSize(namelen uint32) uint64 {
+
+	return fuseEntryOutSize + DirentSize(namelen)
+}
+
+// FuseDirentplus : 目录的结构体，二进制方式写入readdirplus的Content中
+type FuseDirentplus struct {
+	EntryOut FuseEntryOut
+	Dirent   FuseDirent
+}
+
+// ToBinary : Parse to binary
+// The entry_out is followed by the dirent, both are 8 bytes aligned.
+func (direntplus *FuseDirentplus) ToBinary() ([]byte, error) {
+
+	b := make([]byte, DirentplusSize(direntplus.Dirent.NameLen))
+	direntplus.MarshalTo(b)
+
+	return b, nil
+}
+
+// MarshalTo : encode to b without allocation, b is at least
+// DirentplusSize(Dirent.NameLen) bytes
+func (direntplus *FuseDirentplus) MarshalTo(b []byte) {
+
+	direntplus.EntryOut.MarshalTo(b)
+	direntplus.Dirent.MarshalTo(b[fuseEntryOutSize:])
 }
 
 // FuseWriteOut : write response
@@ -185,7 +412,21 @@ type FuseWriteOut struct {
 // ToBinary : Parse to binary
 func (write FuseWriteOut) ToBinary() ([]byte, error) {
 
-	return common.ToBinary(write)
+	b := make([]byte, write.BinarySize())
+	write.MarshalTo(b)
+
+	return b, nil
+}
+
+// BinarySize : the length of FuseWriteOut in binary
+func (write FuseWriteOut) BinarySize() int {
+	return 8
+}
+
+// MarshalTo : encode to b without allocation, b is at least BinarySize() bytes
+func (write FuseWriteOut) MarshalTo(b []byte) {
+	binary.LittleEndian.PutUint32(b[0:], write.Size)
+	binary.LittleEndian.PutUint32(b[4:], write.Padding)
 }
 
 // FuseStatfsOut : statfs response
@@ -196,46 +437,54 @@ type FuseStatfsOut struct {
 // ToBinary : Parse to binary
 func (statfs FuseStatfsOut) ToBinary() ([]byte, error) {
 
-	return common.ToBinary(statfs)
-}
+	b := make([]byte, statfs.BinarySize())
+	statfs.MarshalTo(b)
 
-// XattrVal : value of xattr
-type XattrVal string
+	return b, nil
+}
 
-// ToBinary : Parse to binary
-func (val XattrVal) ToBinary() ([]byte, error) {
+// BinarySize : the length of FuseStatfsOut in binary
+func (statfs FuseStatfsOut) BinarySize() int {
+	return 80
+}
 
-	buf := bytes.NewBuffer(nil)
+// MarshalTo : encode to b without allocation, b is at least BinarySize() bytes
+func (statfs FuseStatfsOut) MarshalTo(b []byte) {
+	statfs.St.MarshalTo(b[0:])
+}
 
-	buf.WriteString(string(val))
-	buf.WriteByte(0)
+// XattrVal : value of xattr, or the names of listxattr each terminated by '\0'
+type XattrVal []byte
 
-	return buf.Bytes(), nil
+// ToBinary : Parse to binary
+func (val XattrVal) ToBinary() ([]byte, error) {
+	return []byte(val), nil
 }
 
-// FuseGetxattrOut : getxattr, listxattr response
+// FuseGetxattrOut : getxattr, listxattr response of the size probe
 type FuseGetxattrOut struct {
 	Size    uint32
 	Padding uint32
-
-	Value XattrVal
 }
 
 // ToBinary : Parse to binary
 func (getxattr FuseGetxattrOut) ToBinary() ([]byte, error) {
 
-	buf := bytes.NewBuffer(nil)
+	b := make([]byte, getxattr.BinarySize())
+	getxattr.MarshalTo(b)
 
-	binary.Write(buf, binary.LittleEndian, getxattr.Size)
-	binary.Write(buf, binary.LittleEndian, getxattr.Padding)
+	return b, nil
+}
 
-	if len(getxattr.Value) > 0 {
-		valb, _ := getxattr.Value.ToBinary()
-		buf.Write(valb)
-		buf.WriteByte(0)
-	}
+// BinarySize : the length of FuseGetxattrOut in binary
+func (getxattr FuseGetxattrOut) BinarySize() int {
+	return 8
+}
 
-	return buf.Bytes(), nil
+// MarshalTo : encode to b without allocation, b is at least BinarySize() bytes
+func (getxattr FuseGetxattrOut) MarshalTo(b []byte) {
+	binary.LittleEndian.PutUint32(b[0:], getxattr.Size)
+	binary.LittleEndian.PutUint32(b[4:], getxattr.Padding)
 }
 
 // FuseLkOut : getlk, setlk, setlkw response
@@ -246,21 +495,55 @@ type FuseLkOut struct {
 // ToBinary : Parse to binary
 func (lk FuseLkOut) ToBinary() ([]byte, error) {
 
-	return common.ToBinary(lk)
+	b := make([]byte, lk.BinarySize())
+	lk.MarshalTo(b)
+
+	return b, nil
+}
+
+// BinarySize : the length of FuseLkOut in binary
+func (lk FuseLkOut) BinarySize() int {
+	return 24
+}
+
+// MarshalTo : encode to b without allocation, b is at least BinarySize() bytes
+func (lk FuseLkOut) MarshalTo(b []byte) {
+	lk.Lk.MarshalTo(b[0:])
 }
 
 // FuseIoctlOut : ioctl response
+// followed by InIovecs and OutIovecs if Flags has FuseIoctlRetry,
+// else followed by the output data Buf
 type FuseIoctlOut struct {
 	Result  int32
 	Flags   uint32
 	InIovs  uint32
 	OutIovs uint32
+
+	InIovecs  []FuseIoctlIovec
+	OutIovecs []FuseIoctlIovec
+
+	Buf []byte
 }
 
 // ToBinary : Parse to binary
 func (ioctl FuseIoctlOut) ToBinary() ([]byte, error) {
 
-	return common.ToBinary(ioctl)
+	buf := bytes.NewBuffer(nil)
+
+	binary.Write(buf, binary.LittleEndian, ioctl.Result)
+	binary.Write(buf, binary.LittleEndian, ioctl.Flags)
+	binary.Write(buf, binary.LittleEndian, ioctl.InIovs)
+	binary.Write(buf, binary.LittleEndian, ioctl.OutIovs)
+
+	if ioctl.Flags&FuseIoctlRetry > 0 {
+		binary.Write(buf, binary.LittleEndian, ioctl.InIovecs)
+		binary.Write(buf, binary.LittleEndian, ioctl.OutIovecs)
+	} else {
+		buf.Write(ioctl.Buf)
+	}
+
+	return buf.Bytes(), nil
 }
 
 // FusePollOut : poll response
@@ -272,7 +555,21 @@ type FusePollOut struct {
 // ToBinary : Parse to binary
 func (poll FusePollOut) ToBinary() ([]byte, error) {
 
-	return common.ToBinary(poll)
+	b := make([]byte, poll.BinarySize())
+	poll.MarshalTo(b)
+
+	return b, nil
+}
+
+// BinarySize : the length of FusePollOut in binary
+func (poll FusePollOut) BinarySize() int {
+	return 8
+}
+
+// MarshalTo : encode to b without allocation, b is at least BinarySize() bytes
+func (poll FusePollOut) MarshalTo(b []byte) {
+	binary.LittleEndian.PutUint32(b[0:], poll.Revents)
+	binary.LittleEndian.PutUint32(b[4:], poll.Padding)
 }
 
 // FuseLseekOut : lseek response
@@ -283,7 +580,20 @@ type FuseLseekOut struct {
 // ToBinary : Parse to binary
 func (lseek FuseLseekOut) ToBinary() ([]byte, error) {
 
-	return common.ToBinary(lseek)
+	b := make([]byte, lseek.BinarySize())
+	lseek.MarshalTo(b)
+
+	return b, nil
+}
+
+// BinarySize : the length of FuseLseekOut in binary
+func (lseek FuseLseekOut) BinarySize() int {
+	return 8
+}
+
+// MarshalTo : encode to b without allocation, b is at least BinarySize() bytes
+func (lseek FuseLseekOut) MarshalTo(b []byte) {
+	binary.LittleEndian.PutUint64(b[0:], lseek.Offset)
 }
 
 // FuseBmapOut : bmap response
@@ -294,7 +604,20 @@ type FuseBmapOut struct {
 // ToBinary : Parse to binary
 func (bmap FuseBmapOut) ToBinary() ([]byte, error) {
 
-	return common.ToBinary(bmap)
+	b := make([]byte, bmap.BinarySize())
+	bmap.MarshalTo(b)
+
+	return b, nil
+}
+
+// BinarySize : the length of FuseBmapOut in binary
+func (bmap FuseBmapOut) BinarySize() int {
+	return 8
+}
+
+// MarshalTo : encode to b without allocation, b is at least BinarySize() bytes
+func (bmap FuseBmapOut) MarshalTo(b []byte) {
+	binary.LittleEndian.PutUint64(b[0:], bmap.Block)
 }
 
 // CuseInitOut : cuse_init response
@@ -315,3 +638,27 @@ func (cuseinit CuseInitOut) ToBinary() ([]byte, error) {
 
 	return common.ToBinary(cuseinit)
 }
+
+// CuseInitResp : cuse_init response with the device info,
+// the device info is a list of "KEY=VALUE" strings, each ends with '\0'
+type CuseInitResp struct {
+	Out CuseInitOut
+
+	DevInfo string
+}
+
+// ToBinary : Parse to binary
+func (cuseinit CuseInitResp) ToBinary() ([]byte, error) {
+
+	bout, err := cuseinit.Out.ToBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(bout)
+
+	buf.WriteString(cuseinit.DevInfo)
+	buf.WriteByte(0)
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,7 @@
+package kernel
+
+// FuseReleaseFlush : release flags, flush the file on release
+const FuseReleaseFlush = (1 << 0)
+
+// FuseReleaseFlockUnlock : release flags, unlock the BSD locks of LockOwner
+const FuseReleaseFlockUnlock = (1 << 1)
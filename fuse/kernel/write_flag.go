@@ -0,0 +1,13 @@
+package kernel
+
+// FuseWriteCache : write flags, delayed write from page cache, file handle is guessed
+const FuseWriteCache = (1 << 0)
+
+// FuseWriteLockowner : write flags, lock_owner field is valid
+const FuseWriteLockowner = (1 << 1)
+
+// FuseWriteKillPriv : write flags, kill suid and sgid bits
+const FuseWriteKillPriv = (1 << 2)
+
+// FuseReadLockowner : read flags, lock_owner field is valid
+const FuseReadLockowner = (1 << 1)
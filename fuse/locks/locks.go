@@ -0,0 +1,322 @@
+package locks
+
+import (
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+)
+
+// offsetMax : the end of a lock to EOF
+const offsetMax = 0x7fffffffffffffff
+
+// lock : a byte-range lock, end is inclusive
+type lock struct {
+	owner uint64
+	pid   int32
+	typ   int16
+	start uint64
+	end   uint64
+}
+
+func (lk *lock) overlap(start uint64, end uint64) bool {
+	return lk.start <= end && start <= lk.end
+}
+
+// adjacent : lk and [start, end] can be merged into one range
+func (lk *lock) adjacent(start uint64, end uint64) bool {
+	return lk.overlap(start, end) ||
+		(lk.end != offsetMax && lk.end+1 == start) ||
+		(end != offsetMax && end+1 == lk.start)
+}
+
+// conflict : whether lk blocks the lock typ of owner on [start, end]
+func (lk *lock) conflict(owner uint64, typ int16, start uint64, end uint64) bool {
+	if lk.owner == owner || !lk.overlap(start, end) {
+		return false
+	}
+	return lk.typ == syscall.F_WRLCK || typ == syscall.F_WRLCK
+}
+
+// waiter : a blocked Setlkw and the owner of the conflicting lock, an owner
+// may have several, e.g. the threads of a process
+type waiter struct {
+	owner   uint64
+	blocker uint64
+}
+
+// Manager : local POSIX byte-range locks, keyed by nodeid and lock owner.
+// It implements Getlk and Setlk for filesystems which do not need locks
+// shared with other hosts, use Install to set them to fuse.Opt.
+type Manager struct {
+	lk sync.Mutex
+
+	nodes map[uint64][]*lock
+
+	// waiting : the blocked Setlkw calls
+	waiting map[*waiter]bool
+
+	// changed : closed and replaced when any lock is released
+	changed chan interface{}
+}
+
+// NewManager : the function to new a lock manager
+func NewManager() *Manager {
+	m := &Manager{}
+	m.nodes = make(map[uint64][]*lock)
+	m.waiting = make(map[*waiter]bool)
+	m.changed = make(chan interface{})
+
+	return m
+}
+
+// lockRange : convert flock to [start, end]
+func lockRange(flock fuse.Flock) (uint64, uint64) {
+	start := uint64(flock.Start)
+	if flock.Len <= 0 {
+		return start, offsetMax
+	}
+	return start, start + uint64(flock.Len) - 1
+}
+
+// Getlk : test for a lock, flock is set to the conflicting lock or F_UNLCK
+func (m *Manager) Getlk(nodeid uint64, owner uint64, flock *fuse.Flock) int32 {
+
+	start, end := lockRange(*flock)
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	conflict := m.findConflict(nodeid, owner, flock.Type, start, end)
+	if conflict == nil {
+		flock.Type = syscall.F_UNLCK
+		return errno.SUCCESS
+	}
+
+	flock.Type = conflict.typ
+	flock.Whence = int16(os.SEEK_SET)
+	flock.Start = int64(conflict.start)
+	if conflict.end == offsetMax {
+		flock.Len = 0
+	} else {
+		flock.Len = int64(conflict.end - conflict.start + 1)
+	}
+	flock.Pid = conflict.pid
+
+	return errno.SUCCESS
+}
+
+// Setlk : acquire, modify or release a lock without waiting,
+// return errno.EAGAIN if it conflicts with the lock of other owner
+func (m *Manager) Setlk(nodeid uint64, owner uint64, flock fuse.Flock) int32 {
+
+	start, end := lockRange(flock)
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	if flock.Type != syscall.F_UNLCK && m.findConflict(nodeid, owner, flock.Type, start, end) != nil {
+		return errno.EAGAIN
+	}
+
+	m.apply(nodeid, owner, flock.Pid, flock.Type, start, end)
+
+	return errno.SUCCESS
+}
+
+// Setlkw : acquire or modify a lock, wait until the conflicting locks are
+// released. Return errno.EDEADLK if waiting would deadlock, and errno.EINTR
+// once interrupted is closed.
+func (m *Manager) Setlkw(nodeid uint64, owner uint64, flock fuse.Flock, interrupted <-chan interface{}) int32 {
+
+	start, end := lockRange(flock)
+	w := &waiter{owner: owner}
+
+	for {
+		m.lk.Lock()
+
+		var conflict *lock
+		if flock.Type != syscall.F_UNLCK {
+			conflict = m.findConflict(nodeid, owner, flock.Type, start, end)
+		}
+
+		if conflict == nil {
+			delete(m.waiting, w)
+			m.apply(nodeid, owner, flock.Pid, flock.Type, start, end)
+			m.lk.Unlock()
+			return errno.SUCCESS
+		}
+
+		if m.deadlock(owner, conflict.owner) {
+			delete(m.waiting, w)
+			m.lk.Unlock()
+			return errno.EDEADLK
+		}
+
+		w.blocker = conflict.owner
+		m.waiting[w] = true
+		changed := m.changed
+
+		m.lk.Unlock()
+
+		select {
+		case <-changed:
+		case <-interrupted:
+			m.lk.Lock()
+			delete(m.waiting, w)
+			m.lk.Unlock()
+			return errno.EINTR
+		}
+	}
+}
+
+// Unlock : release all locks of owner on nodeid, on close of the file
+func (m *Manager) Unlock(nodeid uint64, owner uint64) {
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	m.apply(nodeid, owner, 0, syscall.F_UNLCK, 0, offsetMax)
+}
+
+// findConflict : the first lock of other owner which blocks the lock
+func (m *Manager) findConflict(nodeid uint64, owner uint64, typ int16, start uint64, end uint64) *lock {
+
+	for _, lk := range m.nodes[nodeid] {
+		if lk.conflict(owner, typ, start, end) {
+			return lk
+		}
+	}
+
+	return nil
+}
+
+// deadlock : whether owner waiting for blocker makes a cycle,
+// blocker may wait for several owners
+func (m *Manager) deadlock(owner uint64, blocker uint64) bool {
+
+	visited := make(map[uint64]bool)
+	next := []uint64{blocker}
+
+	for len(next) > 0 {
+		blocker = next[len(next)-1]
+		next = next[:len(next)-1]
+
+		if blocker == owner {
+			return true
+		}
+		if visited[blocker] {
+			continue
+		}
+		visited[blocker] = true
+
+		for w := range m.waiting {
+			if w.owner == blocker {
+				next = append(next, w.blocker)
+			}
+		}
+	}
+
+	return false
+}
+
+// apply : set [start, end] of owner to typ, splitting and merging its locks
+func (m *Manager) apply(nodeid uint64, owner uint64, pid int32, typ int16, start uint64, end uint64) {
+
+	old := m.nodes[nodeid]
+	list := make([]*lock, 0, len(old)+2)
+	released := false
+
+	for _, lk := range old {
+
+		if lk.owner != owner || !lk.adjacent(start, end) {
+			list = append(list, lk)
+			continue
+		}
+
+		if lk.typ == typ {
+			// same type, merge into the new range
+			if lk.start < start {
+				start = lk.start
+			}
+			if lk.end > end {
+				end = lk.end
+			}
+			continue
+		}
+
+		if !lk.overlap(start, end) {
+			list = append(list, lk)
+			continue
+		}
+
+		// different type, keep the parts out of the new range
+		released = true
+		if lk.start < start {
+			list = append(list, &lock{owner: lk.owner, pid: lk.pid, typ: lk.typ, start: lk.start, end: start - 1})
+		}
+		if lk.end > end {
+			list = append(list, &lock{owner: lk.owner, pid: lk.pid, typ: lk.typ, start: end + 1, end: lk.end})
+		}
+	}
+
+	if typ != syscall.F_UNLCK {
+		list = append(list, &lock{owner: owner, pid: pid, typ: typ, start: start, end: end})
+	}
+
+	if len(list) == 0 {
+		delete(m.nodes, nodeid)
+	} else {
+		m.nodes[nodeid] = list
+	}
+
+	if released {
+		// wake up the waiters to check again
+		close(m.changed)
+		m.changed = make(chan interface{})
+	}
+}
+
+// Install : use m as Getlk and Setlk of opts, and release the locks of
+// the owner on Flush and Release. The Flush and Release already in opts
+// are still called after that.
+func Install(opts *fuse.Opt, m *Manager) {
+
+	getlk := func(req fuse.Req, nodeid uint64, fi fuse.FileInfo, lock *fuse.Flock) int32 {
+		return m.Getlk(nodeid, fi.LockOwner, lock)
+	}
+
+	setlk := func(req fuse.Req, nodeid uint64, fi fuse.FileInfo, lock fuse.Flock, lksleep int) int32 {
+		if lksleep != 0 {
+			return m.Setlkw(nodeid, fi.LockOwner, lock, req.Interrupted())
+		}
+		return m.Setlk(nodeid, fi.LockOwner, lock)
+	}
+
+	prevFlush := opts.Flush
+	flush := func(req fuse.Req, nodeid uint64, fi fuse.FileInfo) int32 {
+		m.Unlock(nodeid, fi.LockOwner)
+		if prevFlush != nil {
+			return (*prevFlush)(req, nodeid, fi)
+		}
+		return errno.SUCCESS
+	}
+
+	prevRelease := opts.Release
+	release := func(req fuse.Req, nodeid uint64, fi fuse.FileInfo) int32 {
+		if fi.LockOwner != 0 {
+			m.Unlock(nodeid, fi.LockOwner)
+		}
+		if prevRelease != nil {
+			return (*prevRelease)(req, nodeid, fi)
+		}
+		return errno.SUCCESS
+	}
+
+	opts.Getlk = &getlk
+	opts.Setlk = &setlk
+	opts.Flush = &flush
+	opts.Release = &release
+}
@@ -0,0 +1,76 @@
+package locks
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+)
+
+// waiters : the count of blocked Setlkw
+func (m *Manager) waiters() int {
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	return len(m.waiting)
+}
+
+// waitWaiters : wait until n Setlkw are blocked
+func waitWaiters(t *testing.T, m *Manager, n int) {
+
+	for i := 0; m.waiters() != n; i++ {
+		if i > 1000 {
+			t.Fatalf("waiters: %d, should be %d \n", m.waiters(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+//TestSetlkwWaitersOfOwner : two waiters of one owner, the one woken up does not drop the edge of the other
+func TestSetlkwWaitersOfOwner(t *testing.T) {
+
+	m := NewManager()
+
+	wrlck := func(start int64) fuse.Flock {
+		return fuse.Flock{Type: syscall.F_WRLCK, Start: start, Len: 10}
+	}
+
+	m.Setlk(1, 2, wrlck(0))
+	m.Setlk(1, 3, wrlck(10))
+	m.Setlk(1, 1, wrlck(20))
+
+	// owner 1 waits for owner 2 and owner 3 at the same time
+	first := make(chan int32, 1)
+	go func() {
+		first <- m.Setlkw(1, 1, wrlck(0), nil)
+	}()
+	interrupted := make(chan interface{})
+	second := make(chan int32, 1)
+	go func() {
+		second <- m.Setlkw(1, 1, wrlck(10), interrupted)
+	}()
+	waitWaiters(t, m, 2)
+
+	// the first waiter gets the lock of owner 2
+	m.Setlk(1, 2, fuse.Flock{Type: syscall.F_UNLCK, Start: 0, Len: 10})
+	if res := <-first; res != errno.SUCCESS {
+		t.Fatalf("first waiter res: %d \n", res)
+	}
+
+	// owner 1 still waits for owner 3
+	timeout := make(chan interface{})
+	timer := time.AfterFunc(time.Second, func() { close(timeout) })
+	defer timer.Stop()
+	if res := m.Setlkw(1, 3, wrlck(20), timeout); res != errno.EDEADLK {
+		t.Errorf("owner 3 waiting for owner 1 should be EDEADLK, not %d \n", res)
+	}
+
+	close(interrupted)
+	if res := <-second; res != errno.EINTR {
+		t.Errorf("second waiter res should be EINTR, not %d \n", res)
+	}
+	waitWaiters(t, m, 0)
+}
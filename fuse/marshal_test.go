@@ -0,0 +1,126 @@
+package fuse
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mingforpc/fuse-go/fuse/common"
+	"github.com/mingforpc/fuse-go/fuse/kernel"
+)
+
+// reflectResp : the reply frame encoded by reflection with a buffer,
+// as generateResp before the structs have MarshalTo
+func reflectResp(outHeader kernel.FuseOutHeader, resp interface{}) ([]byte, error) {
+
+	buf := bytes.NewBuffer(nil)
+
+	var bresp []byte
+	var err error
+
+	if resp != nil {
+		bresp, err = common.ToBinary(resp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	outHeader.Len = uint32(kernel.OutHeaderLen + len(bresp))
+
+	bheader, err := common.ToBinary(outHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	buf.Write(bheader)
+	buf.Write(bresp)
+
+	return buf.Bytes(), nil
+}
+
+// attrOut : the reply of getattr to encode
+var attrOut = kernel.FuseAttrOut{AttrValid: 1, Attr: kernel.FuseAttr{Ino: 2, Size: 13, Mode: 0100644, Nlink: 1}}
+
+//TestGenerateRespReflect : the frame of generateResp is the one encoded by reflection
+func TestGenerateRespReflect(t *testing.T) {
+
+	outHeader := kernel.FuseOutHeader{Unique: 42}
+
+	frame, err := generateResp(outHeader, attrOut)
+	if err != nil || len(frame) != 1 {
+		t.Fatalf("generateResp frame: %d slices, err: %+v \n", len(frame), err)
+	}
+
+	want, _ := reflectResp(outHeader, attrOut)
+	if !bytes.Equal(frame[0], want) {
+		t.Errorf("generateResp: %x, should be %x \n", frame[0], want)
+	}
+}
+
+//BenchmarkGenerateResp : encode the reply of getattr with the header
+func BenchmarkGenerateResp(b *testing.B) {
+
+	outHeader := kernel.FuseOutHeader{Unique: 42}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := generateResp(outHeader, attrOut); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+//BenchmarkGenerateRespReflect : encode the reply of getattr with the header by reflection
+func BenchmarkGenerateRespReflect(b *testing.B) {
+
+	outHeader := kernel.FuseOutHeader{Unique: 42}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := reflectResp(outHeader, attrOut); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+//TestParseCopy : only the Buf of Write points into the request buffer, the other arguments are copied
+func TestParseCopy(t *testing.T) {
+
+	setxattrBuf := append([]byte{5, 0, 0, 0, 0, 0, 0, 0}, "user.a\x00value"...)
+	setxattr := kernel.FuseSetxattrIn{}
+	if err := setxattr.ParseBinary(setxattrBuf); err != nil {
+		t.Fatalf("parse setxattr err: %+v \n", err)
+	}
+
+	ioctlBuf := make([]byte, 32)
+	ioctlBuf[24] = 5
+	ioctlBuf = append(ioctlBuf, "inbuf"...)
+	ioctl := kernel.FuseIoctlIn{}
+	if err := ioctl.ParseBinary(ioctlBuf); err != nil {
+		t.Fatalf("parse ioctl err: %+v \n", err)
+	}
+
+	writeBuf := make([]byte, 40)
+	writeBuf[16] = 5
+	writeBuf = append(writeBuf, "write"...)
+	write := kernel.FuseWriteIn{}
+	if err := write.ParseBinary(writeBuf); err != nil {
+		t.Fatalf("parse write err: %+v \n", err)
+	}
+
+	// the request buffer is reused by the next request
+	for _, buf := range [][]byte{setxattrBuf, ioctlBuf, writeBuf} {
+		for i := range buf {
+			buf[i] = 0xff
+		}
+	}
+
+	if setxattr.Name != "user.a" || string(setxattr.Value) != "value" {
+		t.Errorf("setxattr changed with the buffer: %+v \n", setxattr)
+	}
+	if string(ioctl.InBuf) != "inbuf" {
+		t.Errorf("ioctl changed with the buffer: %+v \n", ioctl)
+	}
+	if !bytes.Equal(write.Buf, writeBuf[40:]) {
+		t.Errorf("buf of write should be in the request buffer: %q \n", write.Buf)
+	}
+}
@@ -43,9 +43,52 @@ type Opt struct {
 	 */
 	Destory *func(userdata interface{})
 
+	/**
+	 * Takeover
+	 * Save filesystem state before handing the connection over
+	 *
+	 * Called by Session.Takeover after all outstanding requests are
+	 * replied and before "/dev/fuse" is passed to the successor
+	 * process. The filesystem should serialize whatever the
+	 * successor needs to keep serving the mount, such as its inode
+	 * and file handle tables.
+	 *
+	 * userdata: The userdata saved in Init
+	 * state: the serialized state, passed to Resume of the successor
+	 *
+	 * 热重启时保存文件系统的状态(例如inode和文件句柄表)，交给新进程的Resume
+	 */
+	Takeover *func(userdata interface{}) (state []byte)
+
+	/**
+	 * Resume
+	 * Restore filesystem state after taking the connection over
+	 *
+	 * Called by Session.Resume instead of Init, because the kernel
+	 * will not send another INIT request on an existing connection.
+	 *
+	 * conn: The fuse connection info negotiated by the predecessor
+	 * state: the state returned by Takeover of the predecessor
+	 * userdata: userdata saved to session
+	 *
+	 * 热重启后恢复文件系统的状态，代替Init被调用
+	 */
+	Resume *func(conn *ConnInfo, state []byte) (userdata interface{})
+
 	/**
 	 * Look up a directory entry by name and get its attributes.
 	 *
+	 * fsStat.EntryTimeout and fsStat.AttrTimeout set the cache timeouts
+	 * of this reply. Return fsStat with Nodeid 0 and errno.SUCCESS for a
+	 * negative entry, the kernel caches that the name does not exist for
+	 * fsStat.EntryTimeout (or Config.NegativeTimeout). If
+	 * Config.NegativeTimeout is set, errno.ENOENT is cached the same way.
+	 *
+	 * With Config.ExportSupport, name may be "." for the directory
+	 * parentId itself, or ".." if GetParent is not implemented. The
+	 * entry of them must have the Nodeid, and the lookup count is
+	 * increased like any other name.
+	 *
 	 * req: request handle
 	 * parentId: parent inode number of the parent directory
 	 * name: the name to look up
@@ -56,6 +99,21 @@ type Opt struct {
 	 */
 	Lookup *func(req Req, parentId uint64, name string) (fsStat *FileStat, res int32)
 
+	/**
+	 * Look up the parent directory of a directory, for ".." with
+	 * Config.ExportSupport, "." is answered by Lookup. The lookup
+	 * count of the parent is increased like Lookup, the kernel
+	 * sends Forget for it later.
+	 *
+	 * req: request handle
+	 * nodeid: the inode number of the directory
+	 * fsStat: the file stat of the parent directory
+	 * res: the errno to fs
+	 *
+	 * 获取目录的父目录的属性
+	 */
+	GetParent *func(req Req, nodeid uint64) (fsStat *FileStat, res int32)
+
 	/**
 	 * Forget about an inode
 	 *
@@ -111,6 +169,27 @@ type Opt struct {
 	 */
 	Getattr *func(req Req, nodeid uint64) (fsStat *FileStat, res int32)
 
+	/**
+	 * Get extended file attributes, for statx(2)
+	 *
+	 * The kernel sends it when the caller asks for more than
+	 * StatxBasicStats, such as StatxBtime. The fields in mask
+	 * should be filled and set in attr.Mask, other fields may
+	 * be filled too. If Statx is nil, the library answers by
+	 * Getattr without the birth time and file attributes.
+	 *
+	 * req: request handle
+	 * nodeid: the inode number
+	 * flags: the AT_STATX_* sync flags of statx(2)
+	 * mask: the Statx* fields requested
+	 * fi: file information, Fh is valid if the file is opened, otherwise 0
+	 * attr: the file attributes
+	 * res: the errno to fs
+	 *
+	 * 获取文件的扩展属性，包括创建时间
+	 */
+	Statx *func(req Req, nodeid uint64, flags uint32, mask uint32, fi FileInfo) (attr *Attr, res int32)
+
 	/**
 	 * Set file attributes
 	 *
@@ -122,6 +201,16 @@ type Opt struct {
 	 * expected to reset the setuid and setgid bits if the file
 	 * size or owner is being changed.
 	 *
+	 * If writeback caching is enabled, the kernel owns the size and
+	 * mtime of the file and sends them with FuseSetAttrSize,
+	 * FuseSetAttrMtime and FuseSetAttrCtime, they should be applied
+	 * as they are.
+	 *
+	 * With the writeback cache, the library replies the attributes
+	 * returned by Getattr after setattr if Getattr is implemented,
+	 * with the size and times in 'attr' set by the kernel. Otherwise
+	 * only the members in 'attr' are replied.
+	 *
 	 * If the setattr was invoked from the ftruncate() system call
 	 * under Linux kernel versions 2.6.15 or later, the fi->fh will
 	 * contain the value set by the open method or will be undefined
@@ -304,9 +393,13 @@ type Opt struct {
 	* Filesystem may also implement stateless file I/O and not store
 	* anything in fi->fh.
 	*
-	* There are also some flags (direct_io, keep_cache) which the
-	* filesystem may set in fi, to change the way the file is opened.
-	* See fuse_file_info structure in <fuse_common.h> for more details.
+	* There are also some flags (DirectIo, KeepCache, Nonseekable,
+	* NoFlush) which the filesystem may set in fi, to change the way
+	* the file is opened. See FileInfo for more details.
+	*
+	* With Config.Passthrough, set fi.Passthrough and fi.BackingFd to the
+	* fd of the backing file, the kernel reads and writes it directly without calling Read
+	* and Write. The same applies to Create.
 	*
 	* If this request is answered with an error code of ENOSYS
 	* and FUSE_CAP_NO_OPEN_SUPPORT is set in
@@ -346,6 +439,24 @@ type Opt struct {
 	 */
 	Read *func(req Req, nodeid uint64, size uint32, offset uint64, fi FileInfo) (content []byte, res int32)
 
+	/**
+	 * Read data in pieces
+	 *
+	 * Like Read, but the content is returned in pieces (e.g. pages of
+	 * a cache), which are written to the kernel by writev after the
+	 * reply header without being joined. The total size follows the
+	 * rules of Read. If set, it is used instead of Read.
+	 *
+	 * req: request handle
+	 * nodeid: the inode number
+	 * size: number of bytes to read
+	 * offset: offset to read from
+	 * fi: file information
+	 * content: the content to read, in order
+	 * res: the errno to fs. About read, please check[http://man7.org/linux/man-pages/man2/read.2.html]
+	 */
+	ReadVec *func(req Req, nodeid uint64, size uint32, offset uint64, fi FileInfo) (content [][]byte, res int32)
+
 	/**
 	 * Write data
 	 *
@@ -361,10 +472,14 @@ type Opt struct {
 	 * fi->fh will contain the value set by the open method, or will
 	 * be undefined if the open method didn't set any value.
 	 *
+	 * fi.Writepage is set if the write is a delayed write from the
+	 * page cache (writeback caching), then fi.Fh maybe any open handle
+	 * of the file. fi.LockOwner is 0 if the kernel does not send it.
+	 *
 	 *
 	 * req: request handle
 	 * nodeid: the inode number
-	 * buf: data to write
+	 * buf: data to write, it is reused after the request is replied, copy it to keep it
 	 * offset: offset to write to
 	 * fi: file information
 	 * size: the size write to file
@@ -440,6 +555,9 @@ type Opt struct {
 	 * case the contents of the directory can change between opendir
 	 * and releasedir.
 	 *
+	 * Set fi.CacheDir to let the kernel cache the entries of readdir,
+	 * and fi.KeepCache to keep the cached entries of the last open.
+	 *
 	 *
 	 * req: request handle
 	 * nodeid: the inode number
@@ -465,17 +583,41 @@ type Opt struct {
 	 * entries, but is allowed to do so.
 	 *
 	 *
+	 * Dirent.Off is the offset to continue reading after the entry,
+	 * it must be stable for seekdir/telldir. If it is 0, the library
+	 * uses the position of the entry in the directory, that is offset
+	 * plus its index in direntList plus 1. The entries which do not fit
+	 * in size are dropped, and the kernel reads them again from the
+	 * offset of the last sent entry.
+	 *
 	 * req: request handle
 	 * nodeid: the inode number
 	 * size: maximum number of bytes to send
 	 * offset: offset to continue reading the directory stream
 	 * fi: file information
-	 * direntList: list of file in this directory, the binary size of direntList should not larget than size.
+	 * direntList: list of file in this directory, starting from offset.
 	 * res: the errno to fs. About readdir, please check[http://man7.org/linux/man-pages/man3/readdir.3.html]
 	 *
 	 */
 	Readdir *func(req Req, nodeid uint64, size uint32, offset uint64, fi FileInfo) (direntList []Dirent, res int32)
 
+	/**
+	 * Read directory as a stream
+	 *
+	 * Like Readdir, but the library reads the entries from stream
+	 * until the reply buffer is full, then calls stream.Close().
+	 * The cookies are the same as Readdir. If set, it is used
+	 * instead of Readdir.
+	 *
+	 * req: request handle
+	 * nodeid: the inode number
+	 * offset: offset to continue reading the directory stream
+	 * fi: file information
+	 * stream: the directory stream starting from offset
+	 * res: the errno to fs. About readdir, please check[http://man7.org/linux/man-pages/man3/readdir.3.html]
+	 */
+	ReaddirStream *func(req Req, nodeid uint64, offset uint64, fi FileInfo) (stream DirStream, res int32)
+
 	/**
 	 * Release an open directory
 	 *
@@ -544,6 +686,8 @@ type Opt struct {
 	/**
 	 * Get file system statistics
 	 *
+	 * If Statfs is nil, the library answers by Config.StatfsPath,
+	 * or Config.Capacity and Config.MaxInodes.
 	 *
 	 * req: request handle
 	 * nodeid: the inode number, zero means "undefined"
@@ -555,6 +699,9 @@ type Opt struct {
 	/**
 	 * Set an extended attribute
 	 *
+	 * The value is passed as string, SetxattrBytes is called instead
+	 * if it is set.
+	 *
 	 * If this request is answered with an error code of ENOSYS, this is
 	 * treated as a permanent failure with error code EOPNOTSUPP, i.e. all
 	 * future setxattr() requests will fail with EOPNOTSUPP without being
@@ -570,16 +717,24 @@ type Opt struct {
 	Setxattr *func(req Req, nodeid uint64, name string, value string, flags uint32) (res int32)
 
 	/**
-	 * Get an extended attribute
-	 *
-	 * If size is zero, the size of the value should be sent with
-	 * fuse_reply_xattr.
+	 * Set an extended attribute with the binary value, as Setxattr
 	 *
-	 * If the size is non-zero, and the value fits in the buffer, the
-	 * value should be sent with fuse_reply_buf.
+	 * req: request handle
+	 * nodeid: the inode number, zero means "undefined"
+	 * name: name of attribute
+	 * value: value of attribute, binary
+	 * flags: setxattr flags, XattrCreate or XattrReplace
+	 * res: the errno to fs
+	 */
+	SetxattrBytes *func(req Req, nodeid uint64, name string, value []byte, flags uint32) (res int32)
+
+	/**
+	 * Get an extended attribute
 	 *
-	 * If the size is too small for the value, the ERANGE error should
-	 * be sent.
+	 * The value is returned as string, GetxattrBytes is called instead
+	 * if it is set. The library answers the probe of size zero with the
+	 * size of the value, and sends the ERANGE error if the size is too
+	 * small for the value.
 	 *
 	 * If this request is answered with an error code of ENOSYS, this is
 	 * treated as a permanent failure with error code EOPNOTSUPP, i.e. all
@@ -590,24 +745,32 @@ type Opt struct {
 	 * req: request handle
 	 * nodeid: the inode number
 	 * name: name of the extended attribute
-	 * size: maximum size of the value to send
+	 * size: maximum size of the value to send, zero to get the size
 	 * value: value of the extended attribute
 	 * res: the errno to fs. About getxattr, pease check[http://man7.org/linux/man-pages/man2/fgetxattr.2.html]
 	 */
 	Getxattr *func(req Req, nodeid uint64, name string, size uint32) (value string, res int32)
 
 	/**
-	 * List extended attribute names
-	 *
-	 * If size is zero, the total size of the attribute list should be
-	 * sent with fuse_reply_xattr.
+	 * Get an extended attribute with the binary value, as Getxattr.
+	 * The value is sent as is, an empty value is not an error.
 	 *
-	 * If the size is non-zero, and the null character separated
-	 * attribute list fits in the buffer, the list should be sent with
-	 * fuse_reply_buf.
+	 * req: request handle
+	 * nodeid: the inode number
+	 * name: name of the extended attribute
+	 * size: maximum size of the value to send, zero to get the size
+	 * value: value of the extended attribute, binary
+	 * res: the errno to fs
+	 */
+	GetxattrBytes *func(req Req, nodeid uint64, name string, size uint32) (value []byte, res int32)
+
+	/**
+	 * List extended attribute names
 	 *
-	 * If the size is too small for the list, the ERANGE error should
-	 * be sent.
+	 * The list is returned as string, ListxattrNames is called instead
+	 * if it is set. The library answers the probe of size zero with the
+	 * total size of the list, and sends the ERANGE error if the size is
+	 * too small for the list.
 	 *
 	 * If this request is answered with an error code of ENOSYS, this is
 	 * treated as a permanent failure with error code EOPNOTSUPP, i.e. all
@@ -617,12 +780,24 @@ type Opt struct {
 	 *
 	 * req: request handle
 	 * nodeid: the inode number
-	 * size: maximum size of the list to send
+	 * size: maximum size of the list to send, zero to get the size
 	 * list: the extended attributes names string, eatch name use '\0' to split it
 	 * res: the errno to fs. About getxattr, pease check[http://man7.org/linux/man-pages/man2/flistxattr.2.html]
 	 */
 	Listxattr *func(req Req, nodeid uint64, size uint32) (list string, res int32)
 
+	/**
+	 * List extended attribute names, as Listxattr. The library joins
+	 * the names, each terminated by the null character.
+	 *
+	 * req: request handle
+	 * nodeid: the inode number
+	 * size: maximum size of the list to send, zero to get the size
+	 * names: the extended attributes names
+	 * res: the errno to fs
+	 */
+	ListxattrNames *func(req Req, nodeid uint64, size uint32) (names []string, res int32)
+
 	/**
 	 * Remove an extended attribute
 	 *
@@ -723,6 +898,25 @@ type Opt struct {
 	 */
 	Setlk *func(req Req, nodeid uint64, fi FileInfo, lock Flock, lksleep int) (res int32)
 
+	/**
+	 * Acquire, modify or release a BSD file lock
+	 *
+	 * If set and the kernel supports it, flock(2) calls are sent to
+	 * the filesystem instead of being handled by the kernel locally.
+	 * fi.LockOwner identifies the open file description.
+	 *
+	 * On the last close of the file, Flock is called with LOCK_UN
+	 * before Release, and fi.FlockRelease is set in Release.
+	 *
+	 *
+	 * req: request handle
+	 * nodeid: the inode number
+	 * fi: file information
+	 * op: the locking operation, LOCK_SH, LOCK_EX or LOCK_UN, maybe with LOCK_NB
+	 * res: the errno to fs. About flock, please check [http://man7.org/linux/man-pages/man2/flock.2.html]
+	 */
+	Flock *func(req Req, nodeid uint64, fi FileInfo, op int) (res int32)
+
 	/**
 	 * Map block index within file to block index within device
 	 *
@@ -747,23 +941,33 @@ type Opt struct {
 	 * Ioctl
 	 *
 	 * Note: For unrestricted ioctls (not allowed for FUSE
-	 * servers), data in and out areas can be discovered by giving
-	 * iovs and setting FUSE_IOCTL_RETRY in *flags*.  For
-	 * restricted ioctls, kernel prepares in/out data area
-	 * according to the information encoded in cmd.
-	 *
+	 * servers, only CUSE), data in and out areas can be discovered
+	 * by giving iovs in ioctl.InIovs and ioctl.OutIovs and setting
+	 * ioctl.Retry, the kernel will call Ioctl again with inbuf
+	 * fetched from InIovs. For restricted ioctls, kernel prepares
+	 * in/out data area according to the information encoded in cmd.
+	 *
+	 * If flags has kernel.FuseIoctlCompat, the caller is a 32bit
+	 * process on 64bit kernel (kernel.FuseIoctlCompatX32 for x32),
+	 * the pointers in arg and inbuf are 32bit, and cmd is the 32bit
+	 * one which may differ from the 64bit cmd of the same request.
+	 * Decoding them is up to the filesystem. The iovecs of Retry are
+	 * always sent as 64bit fuse_ioctl_iovec, which the kernel converts
+	 * for the compat caller, so they only need to be addresses of the
+	 * caller: the ones beyond 32bit are replied EIO.
 	 *
 	 * req: request handle
 	 * nodeid: the inode number
 	 * cmd: ioctl command
 	 * arg: ioctl argument
 	 * fi: file information
+	 * flags: kernel.FuseIoctlCompat, kernel.FuseIoctlUnrestricted, kernel.FuseIoctl32bit, kernel.FuseIoctlDir, kernel.FuseIoctlCompatX32
 	 * inbuf: data fetched from the caller
 	 * outbufsz: maximum size of output data
-	 * ioctl: result to kernel
+	 * ioctl: result to kernel, nil means result 0 without output data
 	 * res: the errno to fs. About ioctl, please check[http://man7.org/linux/man-pages/man2/ioctl.2.html]
 	 */
-	Ioctl *func(req Req, nodeid uint64, cmd uint32, arg uint64, fi FileInfo, inbuf []byte, outbufsz uint32) (ioctl *Ioctl, res int32)
+	Ioctl *func(req Req, nodeid uint64, cmd uint32, arg uint64, fi FileInfo, flags uint32, inbuf []byte, outbufsz uint32) (ioctl *Ioctl, res int32)
 
 	/**
 	 * Poll for IO readiness
@@ -832,27 +1036,44 @@ type Opt struct {
 	/**
 	 * Read directory with attributes
 	 *
-	 * Send a buffer filled using fuse_add_direntry_plus(), with size not
-	 * exceeding the requested size.  Send an empty buffer on end of
-	 * stream.
+	 * Return the entries with their attributes, the library encodes
+	 * them and stops at the requested size. Return an empty list on
+	 * end of stream.
 	 *
 	 * fi->fh will contain the value set by the opendir method, or
 	 * will be undefined if the opendir method didn't set any value.
 	 *
 	 * In contrast to readdir() (which does not affect the lookup counts),
 	 * the lookup count of every entry returned by readdirplus(), except "."
-	 * and "..", is incremented by one.
+	 * and "..", is incremented by one. The library calls Forget for the
+	 * entries which do not fit in size, so return every entry as sent.
+	 *
+	 * The cookies in Dirent.Off are the same as Readdir.
 	 *
+	 * If Readdirplus is nil, the library builds the entries from Readdir
+	 * (or ReaddirStream) and Lookup. If both Readdir and ReaddirStream
+	 * are nil, Readdirplus is used for readdir and the lookup counts are
+	 * dropped by Forget.
 	 *
 	 * req: request handle
 	 * nodeid: the inode number
 	 * size: maximum number of bytes to send
 	 * offset: offset to continue reading the directory stream
 	 * fi: file information
-	 * buf: result to kernel
+	 * direntList: result to kernel
 	 * res: the errno to fs. About readdirplus , please check[http://man7.org/linux/man-pages/man3/readdir.3.html]
 	 */
-	Readdirplus *func(req Req, nodeid uint64, size uint32, offset uint64, fi FileInfo) (buf []byte, res int32)
+	Readdirplus *func(req Req, nodeid uint64, size uint32, offset uint64, fi FileInfo) (direntList []DirentPlus, res int32)
 
+	/**
+	 * Interrupt
+	 *
+	 * Called when the kernel interrupts a request, req.Arg is
+	 * kernel.FuseInterruptIn with the unique of the interrupted
+	 * request. The library already closes Interrupted() of that
+	 * request, so most filesystems do not need this.
+	 *
+	 * req: the interrupt request handle
+	 */
 	Interrupt *func(req Req)
 }
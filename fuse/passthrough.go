@@ -0,0 +1,199 @@
+package fuse
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/mingforpc/fuse-go/fuse/kernel"
+	"github.com/mingforpc/fuse-go/fuse/log"
+)
+
+// backing : the backing file registered to the kernel for a nodeid.
+// All passthrough opens of a file must use the same backing id,
+// so it is shared and closed after the last of them is released.
+type backing struct {
+	id int32
+
+	// dev, ino : the file of the backing fd, the later opens must
+	// choose the same file
+	dev uint64
+	ino uint64
+
+	// handles : count of the opens using it, by file handle
+	handles map[uint64]int
+	refs    int
+}
+
+// BackingState : the backing file of a nodeid handed over by takeover,
+// the backing id stays registered on the same "/dev/fuse"
+type BackingState struct {
+	Nodeid uint64
+	ID     int32
+	Dev    uint64
+	Ino    uint64
+
+	// Handles : count of the opens using it, by file handle
+	Handles map[uint64]int
+}
+
+// backingIoctl : the ioctl of "/dev/fuse" to open and close backing files
+var backingIoctl = func(fd int, req uintptr, arg unsafe.Pointer) (uintptr, error) {
+
+	r, _, errnum := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(arg))
+	if errnum != 0 {
+		return 0, errnum
+	}
+
+	return r, nil
+}
+
+// backingOpen : register fd as a backing file, return the backing id
+func (se *Session) backingOpen(fd int) (int32, error) {
+
+	if !se.isDev() {
+		return 0, ErrNotDev
+	}
+
+	m := kernel.FuseBackingMap{Fd: int32(fd)}
+
+	id, err := backingIoctl(se.devFd, kernel.FuseDevIocBackingOpen, unsafe.Pointer(&m))
+	if err != nil {
+		return 0, err
+	}
+
+	return int32(id), nil
+}
+
+// backingClose : unregister the backing id
+func (se *Session) backingClose(id int32) error {
+
+	if !se.isDev() {
+		return ErrNotDev
+	}
+
+	_, err := backingIoctl(se.devFd, kernel.FuseDevIocBackingClose, unsafe.Pointer(&id))
+
+	return err
+}
+
+// setPassthrough : reply the backing file chosen by open, if the file
+// can not be registered, or it is not the backing file of the nodeid
+// already opened, it is opened without passthrough
+func setPassthrough(se *Session, nodeid uint64, openOut *kernel.FuseOpenOut, fi FileInfo) {
+
+	if !fi.Passthrough || fi.BackingFd < 0 {
+		return
+	}
+
+	if (se.connInfo.Want & FuseCapPassthrough) == 0 {
+		if se.Debug {
+			log.Trace.Printf("Passthrough: not enabled, ignore backing fd[%d] of nodeid[%d] \n", fi.BackingFd, nodeid)
+		}
+		return
+	}
+
+	se.backingsLock.Lock()
+	defer se.backingsLock.Unlock()
+
+	if se.backings == nil {
+		se.backings = make(map[uint64]*backing)
+	}
+
+	var stat syscall.Stat_t
+	err := syscall.Fstat(fi.BackingFd, &stat)
+	if err != nil {
+		log.Error.Printf("Passthrough: stat backing fd[%d] of nodeid[%d] err: %s \n", fi.BackingFd, nodeid, err)
+		return
+	}
+
+	b, ok := se.backings[nodeid]
+	if ok && (b.dev != stat.Dev || b.ino != stat.Ino) {
+		// the kernel takes one backing file for all the opens of a nodeid
+		log.Error.Printf("Passthrough: backing fd[%d] of nodeid[%d] is not the file of backing id[%d], open without passthrough \n", fi.BackingFd, nodeid, b.id)
+		return
+	}
+
+	if !ok {
+		id, err := se.backingOpen(fi.BackingFd)
+		if err != nil {
+			log.Error.Printf("Passthrough: register backing fd[%d] of nodeid[%d] err: %s \n", fi.BackingFd, nodeid, err)
+			return
+		}
+
+		b = &backing{id: id, dev: stat.Dev, ino: stat.Ino, handles: make(map[uint64]int)}
+		se.backings[nodeid] = b
+	}
+
+	b.handles[fi.Fh]++
+	b.refs++
+
+	openOut.OpenFlags |= FOpenPassthrough
+	openOut.BackingID = b.id
+}
+
+// releaseBacking : drop the backing file used by the handle fh,
+// unregister it on the last release of nodeid
+func releaseBacking(se *Session, nodeid uint64, fh uint64) {
+
+	se.backingsLock.Lock()
+	defer se.backingsLock.Unlock()
+
+	b, ok := se.backings[nodeid]
+	if !ok || b.handles[fh] == 0 {
+		return
+	}
+
+	b.handles[fh]--
+	if b.handles[fh] == 0 {
+		delete(b.handles, fh)
+	}
+
+	b.refs--
+	if b.refs > 0 {
+		return
+	}
+
+	delete(se.backings, nodeid)
+
+	err := se.backingClose(b.id)
+	if err != nil {
+		log.Error.Printf("Passthrough: close backing id[%d] of nodeid[%d] err: %s \n", b.id, nodeid, err)
+	}
+}
+
+// exportBackings : the registered backing files, for takeover
+func (se *Session) exportBackings() []BackingState {
+
+	se.backingsLock.Lock()
+	defer se.backingsLock.Unlock()
+
+	var list []BackingState
+	for nodeid, b := range se.backings {
+		state := BackingState{Nodeid: nodeid, ID: b.id, Dev: b.dev, Ino: b.ino}
+		state.Handles = make(map[uint64]int, len(b.handles))
+		for fh, count := range b.handles {
+			state.Handles[fh] = count
+		}
+		list = append(list, state)
+	}
+
+	return list
+}
+
+// importBackings : restore the backing files handed over by takeover,
+// they are closed on the last release as the ones registered here
+func (se *Session) importBackings(list []BackingState) {
+
+	se.backingsLock.Lock()
+	defer se.backingsLock.Unlock()
+
+	se.backings = make(map[uint64]*backing, len(list))
+	for _, state := range list {
+		b := &backing{id: state.ID, dev: state.Dev, ino: state.Ino, handles: make(map[uint64]int)}
+		for fh, count := range state.Handles {
+			b.handles[fh] = count
+			b.refs += count
+		}
+		se.backings[state.Nodeid] = b
+	}
+}
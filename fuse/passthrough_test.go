@@ -0,0 +1,186 @@
+package fuse
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+
+	"github.com/mingforpc/fuse-go/fuse/kernel"
+)
+
+// fakeBackings : the backing ids of a fake "/dev/fuse"
+type fakeBackings struct {
+	next   int32
+	opened map[int32]bool
+}
+
+// install : replace the ioctl of "/dev/fuse" until the test ends
+func (fake *fakeBackings) install(t *testing.T) {
+
+	fake.opened = make(map[int32]bool)
+
+	old := backingIoctl
+	backingIoctl = func(fd int, req uintptr, arg unsafe.Pointer) (uintptr, error) {
+		switch req {
+		case kernel.FuseDevIocBackingOpen:
+			fake.next++
+			fake.opened[fake.next] = true
+			return uintptr(fake.next), nil
+		case kernel.FuseDevIocBackingClose:
+			delete(fake.opened, *(*int32)(arg))
+		}
+		return 0, nil
+	}
+	t.Cleanup(func() {
+		backingIoctl = old
+	})
+}
+
+// passthroughSession : a session on a fake "/dev/fuse" with passthrough
+func passthroughSession(t *testing.T) *Session {
+
+	dev, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("open err: %+v \n", err)
+	}
+	t.Cleanup(func() {
+		dev.Close()
+	})
+
+	se := NewFuseSession("", &Opt{}, 16)
+	se.SetDev(int(dev.Fd()))
+	se.connInfo.Want |= FuseCapPassthrough
+
+	return se
+}
+
+// openBacking : open the file as the backing fd of the handle fh
+func openBacking(t *testing.T, se *Session, nodeid uint64, fh uint64, path string) kernel.FuseOpenOut {
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open err: %+v \n", err)
+	}
+	defer file.Close()
+
+	fi := NewFuseFileInfo()
+	fi.Fh = fh
+	fi.Passthrough = true
+	fi.BackingFd = int(file.Fd())
+
+	openOut := kernel.FuseOpenOut{}
+	setPassthrough(se, nodeid, &openOut, fi)
+
+	return openOut
+}
+
+//TestPassthroughSameFile : the opens of a nodeid share the backing file, another file is not passed through
+func TestPassthroughSameFile(t *testing.T) {
+
+	fake := fakeBackings{}
+	fake.install(t)
+
+	dir := t.TempDir()
+	fileA := filepath.Join(dir, "a")
+	fileB := filepath.Join(dir, "b")
+	os.WriteFile(fileA, []byte("a"), 0644)
+	os.WriteFile(fileB, []byte("b"), 0644)
+
+	se := passthroughSession(t)
+
+	first := openBacking(t, se, 2, 10, fileA)
+	second := openBacking(t, se, 2, 11, fileA)
+	if first.OpenFlags&FOpenPassthrough == 0 || second.OpenFlags&FOpenPassthrough == 0 || first.BackingID != second.BackingID {
+		t.Fatalf("opens of the same file should share the backing: %+v %+v \n", first, second)
+	}
+
+	other := openBacking(t, se, 2, 12, fileB)
+	if other.OpenFlags&FOpenPassthrough != 0 || other.BackingID != 0 {
+		t.Errorf("open of another file should not be passed through: %+v \n", other)
+	}
+	if len(fake.opened) != 1 {
+		t.Errorf("backing files registered: %d, should be 1 \n", len(fake.opened))
+	}
+
+	// the handle without passthrough does not drop the backing
+	releaseBacking(se, 2, 12)
+	releaseBacking(se, 2, 10)
+	if !fake.opened[first.BackingID] {
+		t.Errorf("backing id[%d] closed before the last release \n", first.BackingID)
+	}
+
+	releaseBacking(se, 2, 11)
+	if len(fake.opened) != 0 {
+		t.Errorf("backing files not closed after the last release: %+v \n", fake.opened)
+	}
+}
+
+//TestPassthroughZeroFileInfo : the zero FileInfo, or BackingFd without Passthrough, has no backing file
+func TestPassthroughZeroFileInfo(t *testing.T) {
+
+	fake := fakeBackings{}
+	fake.install(t)
+
+	se := passthroughSession(t)
+
+	// fd 0 set without Passthrough
+	withFd := NewFuseFileInfo()
+	withFd.BackingFd = 0
+
+	for _, fi := range []FileInfo{{}, withFd} {
+		openOut := kernel.FuseOpenOut{}
+		setPassthrough(se, 2, &openOut, fi)
+		if openOut.OpenFlags&FOpenPassthrough != 0 || openOut.BackingID != 0 {
+			t.Errorf("open with %+v should not be passed through: %+v \n", fi, openOut)
+		}
+	}
+
+	if fake.next != 0 || len(se.backings) != 0 {
+		t.Errorf("fd 0 registered as the backing file: %+v \n", fake.opened)
+	}
+}
+
+//TestPassthroughTakeover : the backing files are handed over with the state
+func TestPassthroughTakeover(t *testing.T) {
+
+	fake := fakeBackings{}
+	fake.install(t)
+
+	dir := t.TempDir()
+	fileA := filepath.Join(dir, "a")
+	os.WriteFile(fileA, []byte("a"), 0644)
+
+	old := passthroughSession(t)
+	openOut := openBacking(t, old, 2, 10, fileA)
+	openBacking(t, old, 2, 11, fileA)
+
+	data, err := json.Marshal(old.State())
+	if err != nil {
+		t.Fatalf("marshal err: %+v \n", err)
+	}
+	state := &SessionState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		t.Fatalf("unmarshal err: %+v \n", err)
+	}
+	if len(state.Backings) != 1 || state.Backings[0].ID != openOut.BackingID || state.Backings[0].Handles[10] != 1 {
+		t.Fatalf("backings of state: %+v \n", state.Backings)
+	}
+
+	successor := passthroughSession(t)
+	successor.importBackings(state.Backings)
+
+	// a new open of the same file uses the handed over backing id
+	again := openBacking(t, successor, 2, 12, fileA)
+	if again.BackingID != openOut.BackingID || len(fake.opened) != 1 {
+		t.Errorf("open after takeover backing id[%d], should be %d \n", again.BackingID, openOut.BackingID)
+	}
+
+	for _, fh := range []uint64{10, 11, 12} {
+		releaseBacking(successor, 2, fh)
+	}
+	if len(fake.opened) != 0 {
+		t.Errorf("backing files not closed by the successor: %+v \n", fake.opened)
+	}
+}
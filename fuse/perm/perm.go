@@ -0,0 +1,66 @@
+package perm
+
+import (
+	"syscall"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+)
+
+/**
+ * The mask of access(2)
+ *
+ * FOK: test for existence of file
+ * XOK: test for execute or search permission
+ * WOK: test for write permission
+ * ROK: test for read permission
+ */
+const (
+	FOK = 0
+	XOK = 1
+	WOK = 2
+	ROK = 4
+)
+
+// Check : check the access of the caller of req to the file by the POSIX
+// permission bits, like the kernel does with "default_permissions".
+// mask is the mask of Opt.Access, XOK|WOK|ROK or FOK. The owner class is
+// used if the caller owns the file, the group class if it is in the group
+// of the file (or the supplementary groups), the other class otherwise.
+// root is allowed everything but executing a file without any execute bit.
+// Return errno.SUCCESS or errno.EACCES, errno.ENOENT if fsStat is nil.
+func Check(fsStat *fuse.FileStat, req fuse.Req, mask uint32) int32 {
+
+	if fsStat == nil {
+		return errno.ENOENT
+	}
+
+	mask &= XOK | WOK | ROK
+	if mask == FOK {
+		return errno.SUCCESS
+	}
+
+	stat := fsStat.Stat
+
+	if req.UID == 0 {
+		if (mask&XOK) != 0 && (stat.Mode&syscall.S_IFMT) != syscall.S_IFDIR && (stat.Mode&0111) == 0 {
+			return errno.EACCES
+		}
+		return errno.SUCCESS
+	}
+
+	var bits uint32
+	if req.UID == stat.Uid {
+		bits = (stat.Mode >> 6) & 07
+	} else if req.InGroup(stat.Gid) {
+		bits = (stat.Mode >> 3) & 07
+	} else {
+		bits = stat.Mode & 07
+	}
+
+	if (mask & ^bits) != 0 {
+		return errno.EACCES
+	}
+
+	return errno.SUCCESS
+}
@@ -0,0 +1,187 @@
+package record
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Magic : the first bytes of a recording, with the version of format
+const Magic = "FUSEREC\x01"
+
+/**
+ * The kinds of record
+ *
+ * KindRequest: a request read from "/dev/fuse", kernel.FuseInHeader and the argument
+ * KindReply: a reply written to "/dev/fuse", kernel.FuseOutHeader and the data
+ */
+const (
+	KindRequest = 1
+	KindReply   = 2
+)
+
+// MaxFrameSize : the largest frame in a recording, larger means the file is corrupt
+const MaxFrameSize = 1 << 24
+
+var (
+	// ErrMagic : the file is not a recording, or of other version
+	ErrMagic = errors.New("record: not a fuse recording")
+	// ErrCorrupt : the record is invalid
+	ErrCorrupt = errors.New("record: corrupt record")
+)
+
+// Record : a frame of "/dev/fuse" and when it was read or written
+type Record struct {
+	Kind  uint8
+	Time  time.Time
+	Frame []byte
+}
+
+// Unique : the unique of the request or reply, 0 if the frame is too short
+func (rec Record) Unique() uint64 {
+	if len(rec.Frame) < 16 {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(rec.Frame[8:16])
+}
+
+// Opcode : the opcode of the request, 0 for a reply
+func (rec Record) Opcode() uint32 {
+	if rec.Kind != KindRequest || len(rec.Frame) < 8 {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(rec.Frame[4:8])
+}
+
+// Writer : write the records, it is safe for the read and write goroutines
+// of a session. Each record is the kind, the time since the last record
+// in nanoseconds and the length of frame as varints, then the frame.
+type Writer struct {
+	w io.Writer
+
+	last int64 // UnixNano of the last record
+	err  error // the first error, no more records after it
+
+	lock sync.Mutex
+}
+
+// NewWriter : start a recording on w
+func NewWriter(w io.Writer) (*Writer, error) {
+
+	_, err := io.WriteString(w, Magic)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{w: w}, nil
+}
+
+// Write : record the frame of kind now
+func (w *Writer) Write(kind uint8, frame []byte) error {
+	return w.WriteRecord(Record{Kind: kind, Time: time.Now(), Frame: frame})
+}
+
+// WriteRecord : record rec, in one write of the underlying writer
+func (w *Writer) WriteRecord(rec Record) error {
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+
+	now := rec.Time.UnixNano()
+
+	buf := make([]byte, 1+2*binary.MaxVarintLen64+len(rec.Frame))
+	buf[0] = rec.Kind
+	n := 1
+	n += binary.PutVarint(buf[n:], now-w.last)
+	n += binary.PutUvarint(buf[n:], uint64(len(rec.Frame)))
+	n += copy(buf[n:], rec.Frame)
+
+	_, w.err = w.w.Write(buf[:n])
+	w.last = now
+
+	return w.err
+}
+
+// Reader : read the records written by Writer
+type Reader struct {
+	r *bufio.Reader
+
+	last int64
+}
+
+// NewReader : read the recording from r, ErrMagic if it is not a recording
+func NewReader(r io.Reader) (*Reader, error) {
+
+	reader := &Reader{r: bufio.NewReader(r)}
+
+	magic := make([]byte, len(Magic))
+	_, err := io.ReadFull(reader.r, magic)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, ErrMagic
+	}
+	if err != nil {
+		return nil, err
+	}
+	if string(magic) != Magic {
+		return nil, ErrMagic
+	}
+
+	return reader, nil
+}
+
+// Next : the next record, io.EOF at the end of the recording,
+// io.ErrUnexpectedEOF if the last record is truncated
+func (reader *Reader) Next() (Record, error) {
+
+	rec := Record{}
+
+	kind, err := reader.r.ReadByte()
+	if err != nil {
+		return rec, err
+	}
+	if kind != KindRequest && kind != KindReply {
+		return rec, ErrCorrupt
+	}
+
+	delta, err := binary.ReadVarint(reader.r)
+	if err != nil {
+		return rec, unexpected(err)
+	}
+
+	size, err := binary.ReadUvarint(reader.r)
+	if err != nil {
+		return rec, unexpected(err)
+	}
+	if size > MaxFrameSize {
+		return rec, ErrCorrupt
+	}
+
+	frame := make([]byte, size)
+	_, err = io.ReadFull(reader.r, frame)
+	if err != nil {
+		return rec, unexpected(err)
+	}
+
+	reader.last += delta
+
+	rec.Kind = kind
+	rec.Time = time.Unix(0, reader.last)
+	rec.Frame = frame
+
+	return rec, nil
+}
+
+// unexpected : EOF in a record means it is truncated
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,88 @@
+package fuse
+
+import (
+	"bytes"
+	"os"
+	"sync"
+
+	"github.com/mingforpc/fuse-go/fuse/log"
+	"github.com/mingforpc/fuse-go/fuse/record"
+)
+
+// recorder : the transport which records the frames of the
+// transport under it to Config.RecordPath
+type recorder struct {
+	Transport
+
+	file *os.File
+	w    *record.Writer
+
+	failOnce sync.Once
+}
+
+// startRecord : record the session if Config.RecordPath is set,
+// the session is served without recording if the file fails
+func (se *Session) startRecord() {
+
+	path := se.FuseConfig.RecordPath
+	if path == "" {
+		return
+	}
+	if _, ok := se.transport.(*recorder); ok {
+		return
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		log.Error.Printf("Record: %s \n", err)
+		return
+	}
+
+	w, err := record.NewWriter(file)
+	if err != nil {
+		log.Error.Printf("Record: %s \n", err)
+		file.Close()
+		return
+	}
+
+	se.transport = &recorder{Transport: se.transport, file: file, w: w}
+}
+
+// ReadFrame : read and record a request
+func (r *recorder) ReadFrame(buf []byte) (int, error) {
+
+	n, err := r.Transport.ReadFrame(buf)
+	if err == nil {
+		r.record(record.KindRequest, buf[:n])
+	}
+
+	return n, err
+}
+
+// WriteFrame : record and write a reply
+func (r *recorder) WriteFrame(frame [][]byte) error {
+
+	r.record(record.KindReply, bytes.Join(frame, nil))
+
+	return r.Transport.WriteFrame(frame)
+}
+
+// Close : close the transport and the recording
+func (r *recorder) Close() error {
+
+	err := r.Transport.Close()
+	r.file.Close()
+
+	return err
+}
+
+func (r *recorder) record(kind uint8, frame []byte) {
+
+	err := r.w.Write(kind, frame)
+	if err != nil {
+		// the recording stops at the first error
+		r.failOnce.Do(func() {
+			log.Error.Printf("Record: %s \n", err)
+		})
+	}
+}
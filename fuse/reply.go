@@ -0,0 +1,366 @@
+package fuse
+
+import (
+	"errors"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/mingforpc/fuse-go/fuse/errno"
+	"github.com/mingforpc/fuse-go/fuse/kernel"
+	"github.com/mingforpc/fuse-go/fuse/log"
+)
+
+// ReplyLater : the res of a handler which called Req.Async, the request
+// is answered by the Reply and the other returned values are ignored.
+// It is not an errno.
+const ReplyLater int32 = 1
+
+var (
+	// ErrReplied : the request is already replied
+	ErrReplied = errors.New("fuse: request already replied")
+	// ErrReplyType : the reply does not match the opcode of request,
+	// the request is replied errno.EIO instead
+	ErrReplyType = errors.New("fuse: reply does not match the request")
+)
+
+// asyncOps : the opcodes which can be replied later
+var asyncOps = map[uint32]bool{
+	kernel.FuseOpLookup:      true,
+	kernel.FuseOpGetattr:     true,
+	kernel.FuseOpSetattr:     true,
+	kernel.FuseOpStatx:       true,
+	kernel.FuseOpReadlink:    true,
+	kernel.FuseOpSymlink:     true,
+	kernel.FuseOpMknod:       true,
+	kernel.FuseOpMkdir:       true,
+	kernel.FuseOpUnlink:      true,
+	kernel.FuseOpRmdir:       true,
+	kernel.FuseOpRename:      true,
+	kernel.FuseOpRename2:     true,
+	kernel.FuseOpLink:        true,
+	kernel.FuseOpOpen:        true,
+	kernel.FuseOpRead:        true,
+	kernel.FuseOpWrite:       true,
+	kernel.FuseOpStatfs:      true,
+	kernel.FuseOpRelease:     true,
+	kernel.FuseOpFsync:       true,
+	kernel.FuseOpSetxattr:    true,
+	kernel.FuseOpGetxattr:    true,
+	kernel.FuseOpRemovexattr: true,
+	kernel.FuseOpFlush:       true,
+	kernel.FuseOpOpendir:     true,
+	kernel.FuseOpReleasedir:  true,
+	kernel.FuseOpFsyncdir:    true,
+	kernel.FuseOpSetlk:       true,
+	kernel.FuseOpSetlkw:      true,
+	kernel.FuseOpAccess:      true,
+	kernel.FuseOpCreate:      true,
+	kernel.FuseOpFallocate:   true,
+}
+
+// Reply : the reply of a request answered later, as fuse_reply_* of
+// libfuse. The methods can be called from any goroutine, only the first
+// call replies the request, the others return ErrReplied.
+//
+// 异步回复: Handler调用Req.Async后返回ReplyLater，之后在任意goroutine中回复
+type Reply struct {
+	req Req
+
+	replied int32
+}
+
+// Async : answer the request later by the returned Reply, the handler
+// should return ReplyLater at once. Until it is replied, the request
+// can be interrupted and the session is not taken over.
+//
+// The arguments of the request, e.g. the buf of Write, stay valid
+// until the request is replied, copy them to keep them after it.
+//
+// It returns nil if the request can not be replied later, such as
+// Init, Readdir, or Lookup called by the library for Readdirplus,
+// then the handler should return the result as usual.
+func (req Req) Async() *Reply {
+
+	if req.ctx == nil || !asyncOps[req.opcode] {
+		return nil
+	}
+
+	if req.ctx.reply == nil {
+		req.ctx.reply = &Reply{req: req}
+		// the arguments stay valid until the reply is sent
+		atomic.AddInt32(&req.ctx.bufRefs, 1)
+	}
+
+	return req.ctx.reply
+}
+
+// isAsync : whether the handler of request called Async
+func (req Req) isAsync() bool {
+	return req.ctx != nil && req.ctx.reply != nil
+}
+
+// ReplyErr : reply the errno res, errno.SUCCESS only for the requests
+// without data, such as Unlink, Release, Setxattr and Setlk
+func (reply *Reply) ReplyErr(res int32) error {
+
+	if res != errno.SUCCESS {
+
+		se := reply.req.session
+		if reply.req.opcode == kernel.FuseOpLookup && res == errno.ENOENT && se.FuseConfig.NegativeTimeout > 0 {
+			// let the kernel cache that the name does not exist
+			entryOut := kernel.FuseEntryOut{}
+			setEntryOut(se, &entryOut, &FileStat{})
+			return reply.send(errno.SUCCESS, entryOut)
+		}
+
+		return reply.send(res, nil)
+	}
+
+	switch reply.req.opcode {
+	case kernel.FuseOpUnlink, kernel.FuseOpRmdir, kernel.FuseOpRename, kernel.FuseOpRename2,
+		kernel.FuseOpRelease, kernel.FuseOpReleasedir, kernel.FuseOpFlush, kernel.FuseOpFsync,
+		kernel.FuseOpFsyncdir, kernel.FuseOpSetxattr, kernel.FuseOpRemovexattr, kernel.FuseOpAccess,
+		kernel.FuseOpSetlk, kernel.FuseOpSetlkw, kernel.FuseOpFallocate:
+		return reply.send(errno.SUCCESS, nil)
+	}
+
+	return reply.mismatch()
+}
+
+// ReplyEntry : reply the entry of Lookup, Mknod, Mkdir, Symlink or Link.
+// For Lookup, nil is errno.ENOENT, Nodeid 0 is a negative entry.
+func (reply *Reply) ReplyEntry(fsStat *FileStat) error {
+
+	switch reply.req.opcode {
+	case kernel.FuseOpLookup, kernel.FuseOpMknod, kernel.FuseOpMkdir, kernel.FuseOpSymlink, kernel.FuseOpLink:
+	default:
+		return reply.mismatch()
+	}
+
+	if fsStat == nil {
+		if reply.req.opcode == kernel.FuseOpLookup {
+			return reply.send(errno.ENOENT, nil)
+		}
+		return reply.send(errno.EIO, nil)
+	}
+
+	entryOut := kernel.FuseEntryOut{}
+	setEntryOut(reply.req.session, &entryOut, fsStat)
+
+	return reply.send(errno.SUCCESS, entryOut)
+}
+
+// ReplyCreate : reply the entry and the opened file of Create
+func (reply *Reply) ReplyCreate(fsStat *FileStat, fi FileInfo) error {
+
+	if reply.req.opcode != kernel.FuseOpCreate {
+		return reply.mismatch()
+	}
+	if fsStat == nil {
+		return reply.send(errno.EIO, nil)
+	}
+
+	se := reply.req.session
+	createOut := kernel.FuseCreateOut{}
+
+	setEntryOut(se, &createOut.Entry, fsStat)
+	setOpenOut(&createOut.Open, fi)
+	setPassthrough(se, fsStat.Nodeid, &createOut.Open, fi)
+
+	return reply.send(errno.SUCCESS, createOut)
+}
+
+// ReplyAttr : reply the attributes of Getattr or Setattr. As the Getattr
+// called by the library, it also replies Statx
+func (reply *Reply) ReplyAttr(fsStat *FileStat) error {
+
+	if fsStat == nil {
+		return reply.send(errno.EIO, nil)
+	}
+
+	se := reply.req.session
+
+	switch reply.req.opcode {
+	case kernel.FuseOpGetattr, kernel.FuseOpSetattr:
+		attrOut := kernel.FuseAttrOut{}
+		if getattrIn, ok := (*reply.req.Arg).(kernel.FuseGetattrIn); ok {
+			attrOut.Dummp = getattrIn.Dummy
+		}
+		setAttrOut(se, &attrOut, fsStat)
+		return reply.send(errno.SUCCESS, attrOut)
+
+	case kernel.FuseOpStatx:
+		statxOut := kernel.FuseStatxOut{}
+		setStatxOut(se, &statxOut, AttrFromStat(*fsStat))
+		return reply.send(errno.SUCCESS, statxOut)
+	}
+
+	return reply.mismatch()
+}
+
+// ReplyReadlink : reply the target of Readlink
+func (reply *Reply) ReplyReadlink(path string) error {
+
+	if reply.req.opcode != kernel.FuseOpReadlink {
+		return reply.mismatch()
+	}
+
+	return reply.send(errno.SUCCESS, kernel.FuseReadlinkOut{Path: path})
+}
+
+// ReplyOpen : reply the opened file of Open or Opendir
+func (reply *Reply) ReplyOpen(fi FileInfo) error {
+
+	openOut := kernel.FuseOpenOut{}
+
+	switch reply.req.opcode {
+	case kernel.FuseOpOpen:
+		setOpenOut(&openOut, fi)
+		setPassthrough(reply.req.session, reply.req.nodeid, &openOut, fi)
+	case kernel.FuseOpOpendir:
+		setOpenOut(&openOut, fi)
+	default:
+		return reply.mismatch()
+	}
+
+	return reply.send(errno.SUCCESS, openOut)
+}
+
+// ReplyData : reply the content of Read
+func (reply *Reply) ReplyData(content []byte) error {
+
+	if reply.req.opcode != kernel.FuseOpRead {
+		return reply.mismatch()
+	}
+
+	return reply.send(errno.SUCCESS, kernel.FuseReadOut{Content: content})
+}
+
+// ReplyDataVec : reply the content of Read in pieces, as Opt.ReadVec
+func (reply *Reply) ReplyDataVec(content [][]byte) error {
+
+	if reply.req.opcode != kernel.FuseOpRead {
+		return reply.mismatch()
+	}
+
+	return reply.send(errno.SUCCESS, kernel.FuseReadOut{Vec: content})
+}
+
+// ReplyWrite : reply the number of bytes written by Write
+func (reply *Reply) ReplyWrite(size uint32) error {
+
+	if reply.req.opcode != kernel.FuseOpWrite {
+		return reply.mismatch()
+	}
+
+	return reply.send(errno.SUCCESS, kernel.FuseWriteOut{Size: size})
+}
+
+// ReplyStatfs : reply the statistics of Statfs
+func (reply *Reply) ReplyStatfs(statfs *Statfs) error {
+
+	if reply.req.opcode != kernel.FuseOpStatfs {
+		return reply.mismatch()
+	}
+	if statfs == nil {
+		return reply.send(errno.EIO, nil)
+	}
+
+	return reply.send(errno.SUCCESS, kernel.FuseStatfsOut{St: kernel.FuseStatfs(*statfs)})
+}
+
+// ReplyXattr : reply the value of Getxattr, the size of it to
+// the size probe, or errno.ERANGE if it is larger than the size
+func (reply *Reply) ReplyXattr(value []byte) error {
+
+	if reply.req.opcode != kernel.FuseOpGetxattr {
+		return reply.mismatch()
+	}
+
+	getxattrIn := (*reply.req.Arg).(kernel.FuseGetxattrIn)
+
+	var sizeOut = kernel.FuseGetxattrOut{}
+	var valueOut = kernel.XattrVal{}
+
+	res := setXattrReply(getxattrIn.Size, value, &sizeOut, &valueOut)
+	if res != errno.SUCCESS {
+		return reply.send(res, nil)
+	}
+
+	if getxattrIn.Size == 0 {
+		return reply.send(errno.SUCCESS, sizeOut)
+	}
+
+	return reply.send(errno.SUCCESS, valueOut)
+}
+
+// mismatch : reply errno.EIO for the reply of other opcode
+func (reply *Reply) mismatch() error {
+
+	log.Error.Printf("Reply of opcode[%d] unique[%d] does not match the request \n", reply.req.opcode, reply.req.Unique)
+
+	err := reply.send(errno.EIO, nil)
+	if err != nil {
+		return err
+	}
+
+	return ErrReplyType
+}
+
+// send : write the reply once, then the request is done
+func (reply *Reply) send(errnum int32, resp kernel.FuseResponsor) (err error) {
+
+	if !atomic.CompareAndSwapInt32(&reply.replied, 0, 1) {
+		return ErrReplied
+	}
+
+	req := reply.req
+	se := req.session
+
+	se.unregisterReq(&req)
+	defer se.releaseBuf(req.ctx)
+
+	outHeader := kernel.FuseOutHeader{}
+	outHeader.Error = errnum
+	outHeader.Unique = req.Unique
+
+	if se.Debug {
+		log.Trace.Printf("Reply: errnum[%d %s], outHeader[%+v], resp[%+v]", errnum, errno.Name(errnum), outHeader, resp)
+	}
+
+	if errnum != errno.SUCCESS {
+		resp = nil
+	}
+
+	frame, err := generateResp(outHeader, resp)
+	if err != nil {
+		log.Error.Println(err)
+
+		outHeader.Error = errno.EIO
+		frame, _ = generateResp(outHeader, nil)
+	}
+
+	defer func() {
+		// the write channel is closed with the session
+		if recover() != nil {
+			se.inflight.Done()
+			err = syscall.ENODEV
+		}
+	}()
+
+	select {
+	case <-se.closeCh:
+		se.inflight.Done()
+		return syscall.ENODEV
+	default:
+	}
+
+	select {
+	case <-se.closeCh:
+		se.inflight.Done()
+		return syscall.ENODEV
+	case se.writeChan <- frame:
+	}
+
+	return nil
+}
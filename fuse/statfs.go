@@ -0,0 +1,104 @@
+package fuse
+
+import (
+	"sync/atomic"
+	"syscall"
+
+	"github.com/mingforpc/fuse-go/fuse/errno"
+	"github.com/mingforpc/fuse-go/fuse/kernel"
+)
+
+// StatfsBlockSize : the block size of the statfs computed from Config.Capacity
+const StatfsBlockSize = 4096
+
+// usage : the space and inodes used by the filesystem, updated atomically
+type usage struct {
+	bytes  int64
+	inodes int64
+}
+
+// AddUsedBytes : add delta (may be negative) to the used space, call it
+// on write, truncate and unlink. It is used for Config.Capacity.
+func (se *Session) AddUsedBytes(delta int64) uint64 {
+	return clampUsage(atomic.AddInt64(&se.usage.bytes, delta))
+}
+
+// AddUsedInodes : add delta (may be negative) to the used inodes, call it
+// on create and forget of the last link. It is used for Config.MaxInodes.
+func (se *Session) AddUsedInodes(delta int64) uint64 {
+	return clampUsage(atomic.AddInt64(&se.usage.inodes, delta))
+}
+
+// SetUsage : set the used space and inodes, e.g. after scanning the filesystem on start
+func (se *Session) SetUsage(bytes uint64, inodes uint64) {
+	atomic.StoreInt64(&se.usage.bytes, int64(bytes))
+	atomic.StoreInt64(&se.usage.inodes, int64(inodes))
+}
+
+// Usage : the used space and inodes
+func (se *Session) Usage() (bytes uint64, inodes uint64) {
+	return clampUsage(atomic.LoadInt64(&se.usage.bytes)), clampUsage(atomic.LoadInt64(&se.usage.inodes))
+}
+
+func clampUsage(n int64) uint64 {
+	if n < 0 {
+		return 0
+	}
+	return uint64(n)
+}
+
+// defaultStatfs : the statfs when Opt.Statfs is nil, by Config.StatfsPath,
+// or Config.Capacity and Config.MaxInodes with the usage of session
+func defaultStatfs(se *Session, st *kernel.FuseStatfs) int32 {
+
+	config := se.FuseConfig
+
+	if config.StatfsPath != "" {
+
+		var hostStat syscall.Statfs_t
+		err := syscall.Statfs(config.StatfsPath, &hostStat)
+		if err != nil {
+			if e, ok := err.(syscall.Errno); ok {
+				return -int32(e)
+			}
+			return errno.EIO
+		}
+
+		st.Blocks = hostStat.Blocks
+		st.Bfree = hostStat.Bfree
+		st.Bavail = hostStat.Bavail
+		st.Files = hostStat.Files
+		st.Ffree = hostStat.Ffree
+		st.Bsize = uint32(hostStat.Bsize)
+		st.NameLen = uint32(hostStat.Namelen)
+		st.Frsize = uint32(hostStat.Frsize)
+
+		return errno.SUCCESS
+	}
+
+	st.NameLen = 255
+	st.Bsize = 512
+
+	if config.Capacity == 0 && config.MaxInodes == 0 {
+		return errno.SUCCESS
+	}
+
+	usedBytes, usedInodes := se.Usage()
+
+	st.Bsize = StatfsBlockSize
+	st.Frsize = StatfsBlockSize
+
+	st.Blocks = config.Capacity / StatfsBlockSize
+	usedBlocks := (usedBytes + StatfsBlockSize - 1) / StatfsBlockSize
+	if usedBlocks < st.Blocks {
+		st.Bfree = st.Blocks - usedBlocks
+	}
+	st.Bavail = st.Bfree
+
+	st.Files = config.MaxInodes
+	if usedInodes < st.Files {
+		st.Ffree = st.Files - usedInodes
+	}
+
+	return errno.SUCCESS
+}
@@ -0,0 +1,142 @@
+package fuse
+
+import (
+	"syscall"
+
+	"github.com/mingforpc/fuse-go/fuse/kernel"
+	"golang.org/x/sys/unix"
+)
+
+/**
+ * The fields of Attr, in the mask of Statx
+ *
+ * StatxBasicStats: the fields in FileStat
+ * StatxBtime: the creation time
+ */
+const (
+	StatxType       = 0x00000001
+	StatxMode       = 0x00000002
+	StatxNlink      = 0x00000004
+	StatxUID        = 0x00000008
+	StatxGID        = 0x00000010
+	StatxAtime      = 0x00000020
+	StatxMtime      = 0x00000040
+	StatxCtime      = 0x00000080
+	StatxIno        = 0x00000100
+	StatxSize       = 0x00000200
+	StatxBlocks     = 0x00000400
+	StatxBasicStats = 0x000007ff
+	StatxBtime      = 0x00000800
+	StatxAll        = 0x00000fff
+)
+
+/**
+ * The file attributes in Attr.Attributes, the ones supported by the
+ * filesystem should be set in Attr.AttributesMask
+ */
+const (
+	StatxAttrCompressed = 0x00000004
+	StatxAttrImmutable  = 0x00000010
+	StatxAttrAppend     = 0x00000020
+	StatxAttrNodump     = 0x00000040
+	StatxAttrEncrypted  = 0x00000800
+	StatxAttrAutomount  = 0x00001000
+	StatxAttrMountRoot  = 0x00002000
+	StatxAttrVerity     = 0x00100000
+	StatxAttrDax        = 0x00200000
+)
+
+// Attr : the file attributes of statx, FileStat with the creation time
+// and the file attributes
+type Attr struct {
+	// Mask : the valid fields, Statx*, 0 is StatxBasicStats
+	Mask uint32
+
+	Blksize uint32
+
+	// Attributes : the file attributes, StatxAttr*
+	Attributes uint64
+	// AttributesMask : the file attributes supported by the filesystem
+	AttributesMask uint64
+
+	Nlink  uint32
+	UID    uint32
+	GID    uint32
+	Mode   uint32
+	Ino    uint64
+	Size   uint64
+	Blocks uint64
+
+	Atime syscall.Timespec
+	Btime syscall.Timespec
+	Ctime syscall.Timespec
+	Mtime syscall.Timespec
+
+	RdevMajor uint32
+	RdevMinor uint32
+	DevMajor  uint32
+	DevMinor  uint32
+
+	// AttrTimeout : validity timeout (in seconds) for the attributes,
+	// 0 is Config.AttrTimeout, negative means no caching
+	AttrTimeout float64
+}
+
+// AttrFromStat : the function to new an Attr from the file stat,
+// only StatxBasicStats is valid
+func AttrFromStat(fsStat FileStat) Attr {
+
+	stat := fsStat.Stat
+
+	return Attr{
+		Mask:      StatxBasicStats,
+		Blksize:   uint32(stat.Blksize),
+		Nlink:     uint32(stat.Nlink),
+		UID:       stat.Uid,
+		GID:       stat.Gid,
+		Mode:      stat.Mode,
+		Ino:       stat.Ino,
+		Size:      uint64(stat.Size),
+		Blocks:    uint64(stat.Blocks),
+		Atime:     stat.Atim,
+		Ctime:     stat.Ctim,
+		Mtime:     stat.Mtim,
+		RdevMajor: unix.Major(uint64(stat.Rdev)),
+		RdevMinor: unix.Minor(uint64(stat.Rdev)),
+		DevMajor:  unix.Major(uint64(stat.Dev)),
+		DevMinor:  unix.Minor(uint64(stat.Dev)),
+
+		AttrTimeout: fsStat.AttrTimeout,
+	}
+}
+
+func setFuseStatx(sx *kernel.FuseStatx, attr Attr) {
+
+	sx.Mask = attr.Mask
+	if sx.Mask == 0 {
+		sx.Mask = StatxBasicStats
+	}
+
+	sx.Blksize = attr.Blksize
+	sx.Attributes = attr.Attributes
+	sx.AttributesMask = attr.AttributesMask
+	sx.Nlink = attr.Nlink
+	sx.UID = attr.UID
+	sx.GID = attr.GID
+	sx.Mode = uint16(attr.Mode)
+	sx.Ino = attr.Ino
+	sx.Size = attr.Size
+	sx.Blocks = attr.Blocks
+	sx.Atime = fuseSxTime(attr.Atime)
+	sx.Btime = fuseSxTime(attr.Btime)
+	sx.Ctime = fuseSxTime(attr.Ctime)
+	sx.Mtime = fuseSxTime(attr.Mtime)
+	sx.RdevMajor = attr.RdevMajor
+	sx.RdevMinor = attr.RdevMinor
+	sx.DevMajor = attr.DevMajor
+	sx.DevMinor = attr.DevMinor
+}
+
+func fuseSxTime(ts syscall.Timespec) kernel.FuseSxTime {
+	return kernel.FuseSxTime{TvSec: int64(ts.Sec), TvNsec: uint32(ts.Nsec)}
+}
@@ -0,0 +1,193 @@
+package fuse
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/mingforpc/fuse-go/fuse/log"
+)
+
+// ErrNoFd : the takeover message does not carry a file descriptor
+var ErrNoFd = errors.New("takeover message has no file descriptor")
+
+// ErrNotRunning : the session is not serving "/dev/fuse"
+var ErrNotRunning = errors.New("fuse session is not running")
+
+// SessionState : the state handed over to the successor process,
+// everything except "/dev/fuse" itself which is passed as fd
+type SessionState struct {
+	// ConnInfo : the connection info negotiated in INIT
+	ConnInfo ConnInfo
+
+	// Bufsize : read buffer size of "/dev/fuse"
+	Bufsize int
+
+	// Generations : the generation table of the reused nodeid
+	Generations map[uint64]uint64
+
+	// Backings : the backing files of passthrough still opened
+	Backings []BackingState
+
+	// UsedBytes, UsedInodes : the usage for Config.Capacity and Config.MaxInodes
+	UsedBytes  uint64
+	UsedInodes uint64
+
+	// Data : the filesystem state returned by Opt.Takeover
+	Data []byte
+}
+
+// State : return the current state of session
+func (se *Session) State() *SessionState {
+	state := &SessionState{}
+	state.ConnInfo = *se.connInfo
+	state.Bufsize = se.bufsize
+	state.Generations = se.exportGenerations()
+	state.Backings = se.exportBackings()
+	state.UsedBytes, state.UsedInodes = se.Usage()
+
+	if se.Opts != nil && se.Opts.Takeover != nil {
+		state.Data = (*se.Opts.Takeover)(se.userdata)
+	}
+
+	return state
+}
+
+// Takeover : hand "/dev/fuse" and the session state over to a successor
+// process through the unix socket conn, the mount stays alive.
+//
+// It stops reading new requests, waits until every outstanding request is
+// replied, then sends the fd and the state. FuseLoop returns once the
+// takeover starts, and the session is closed when this function returns
+// without error. The successor should call ReceiveTakeover and Resume.
+//
+// 热重启: 停止读取请求，等待所有请求回复后，将"/dev/fuse"和状态通过unix socket交给新进程
+func (se *Session) Takeover(conn *net.UnixConn) error {
+
+	if !se.running.Load() || se.loopDone == nil {
+		return ErrNotRunning
+	}
+	if !se.isDev() {
+		return ErrNotDev
+	}
+
+	// stop reading, FuseLoop returns after the read goroutine exits
+	se.detaching.Store(true)
+	<-se.loopDone
+
+	se.inflight.Wait()
+
+	state := se.State()
+
+	if se.Debug {
+		log.Trace.Printf("Takeover: %+v \n", state.ConnInfo)
+	}
+
+	err := sendTakeover(conn, se.devFd, state)
+	if err != nil {
+		// The session stays detached, the caller can retry or Close it
+		return err
+	}
+
+	// the successor holds its own reference of "/dev/fuse",
+	// so closing ours does not abort the connection
+	se.running.Store(false)
+	close(se.writeChan)
+	se.closeTransport()
+
+	return nil
+}
+
+// ReceiveTakeover : receive "/dev/fuse" fd and session state sent by Takeover
+func ReceiveTakeover(conn *net.UnixConn) (fd int, state *SessionState, err error) {
+
+	var head [4]byte
+	oob := make([]byte, syscall.CmsgSpace(4))
+
+	_, oobn, _, _, err := conn.ReadMsgUnix(head[:], oob)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return -1, nil, err
+	}
+	if len(msgs) == 0 {
+		return -1, nil, ErrNoFd
+	}
+
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil {
+		return -1, nil, err
+	}
+	if len(fds) == 0 {
+		return -1, nil, ErrNoFd
+	}
+	fd = fds[0]
+
+	data := make([]byte, binary.LittleEndian.Uint32(head[:]))
+	_, err = io.ReadFull(conn, data)
+	if err != nil {
+		syscall.Close(fd)
+		return -1, nil, err
+	}
+
+	state = &SessionState{}
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		syscall.Close(fd)
+		return -1, nil, err
+	}
+
+	return fd, state, nil
+}
+
+// Resume : resume serving the connection handed over by Takeover,
+// call FuseLoop after it. The kernel does not send INIT again, so
+// Opt.Resume is called instead of Opt.Init.
+func (se *Session) Resume(fd int, state *SessionState) {
+
+	se.SetDev(fd)
+	syscall.CloseOnExec(fd)
+
+	*se.connInfo = state.ConnInfo
+	if state.Bufsize > 0 {
+		se.bufsize = state.Bufsize
+	}
+	se.importGenerations(state.Generations)
+	se.importBackings(state.Backings)
+	se.SetUsage(state.UsedBytes, state.UsedInodes)
+
+	if se.Debug {
+		log.Trace.Printf("Resume: %+v \n", state.ConnInfo)
+	}
+
+	if se.Opts != nil && se.Opts.Resume != nil {
+		se.userdata = (*se.Opts.Resume)(se.connInfo, state.Data)
+	}
+}
+
+// sendTakeover : send the length of state with fd, then the state itself
+func sendTakeover(conn *net.UnixConn, fd int, state *SessionState) error {
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	var head [4]byte
+	binary.LittleEndian.PutUint32(head[:], uint32(len(data)))
+
+	_, _, err = conn.WriteMsgUnix(head[:], syscall.UnixRights(fd), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Write(data)
+
+	return err
+}
@@ -0,0 +1,82 @@
+package fuse
+
+import (
+	"errors"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrNotDev : the transport of the session is not "/dev/fuse"
+var ErrNotDev = errors.New("fuse session is not on /dev/fuse")
+
+// Transport : the I/O of the requests and replies of a session, "/dev/fuse"
+// by SetDev. Each read returns a whole request and each write is a whole
+// reply, as "/dev/fuse" does. Set by SetTransport before FuseLoop, e.g. to
+// serve the requests of fusetest.Kernel without mounting.
+type Transport interface {
+	/**
+	 * Read a request into buf, return the length of it.
+	 * It blocks until a request comes, syscall.ENODEV or
+	 * syscall.EBADF means the connection is gone and FuseLoop exits.
+	 */
+	ReadFrame(buf []byte) (n int, err error)
+
+	/**
+	 * Write a reply, the concatenation of the slices of frame,
+	 * starting with kernel.FuseOutHeader
+	 */
+	WriteFrame(frame [][]byte) (err error)
+
+	/**
+	 * Close the connection, the blocking ReadFrame should return
+	 */
+	Close() (err error)
+}
+
+// devTransport : "/dev/fuse" or "/dev/cuse", read with epoll by FuseLoop
+type devTransport struct {
+	fd int
+}
+
+// ReadFrame : read a request from the fd
+func (dev devTransport) ReadFrame(buf []byte) (int, error) {
+	return syscall.Read(dev.fd, buf)
+}
+
+// WriteFrame : write a reply to the fd by writev, the kernel
+// takes a reply in one write
+func (dev devTransport) WriteFrame(frame [][]byte) error {
+
+	if len(frame) == 1 {
+		_, err := syscall.Write(dev.fd, frame[0])
+		return err
+	}
+
+	_, err := unix.Writev(dev.fd, frame)
+	return err
+}
+
+// Close : close the fd
+func (dev devTransport) Close() error {
+	return syscall.Close(dev.fd)
+}
+
+// SetTransport : serve the requests of t instead of "/dev/fuse".
+// Passthrough and Takeover need "/dev/fuse", they are not supported.
+func (se *Session) SetTransport(t Transport) {
+	se.devFd = -1
+	se.transport = t
+}
+
+// isDev : whether the transport is "/dev/fuse" (or "/dev/cuse")
+func (se *Session) isDev() bool {
+
+	t := se.transport
+	if r, ok := t.(*recorder); ok {
+		t = r.Transport
+	}
+
+	_, ok := t.(devTransport)
+	return ok
+}
@@ -27,6 +27,9 @@ func (file *notExistFile) isNegative() bool {
 // Because file system maybe will scan the mountpoint,
 // and will look up a lot of not exist path
 // TODO: should add a goroutine to delete timeout node?
+//
+// Deprecated: set fuse.Config.NegativeTimeout or return a negative entry
+// (FileStat with Nodeid 0) from Lookup, so the kernel caches it instead.
 type NotExistManager struct {
 	NegativeTimeout int
 
@@ -39,3 +39,23 @@ func (fp *FusePathManager) Del(nodeid uint64) {
 	delete(fp.pathDict, nodeid)
 	fp.lk.Unlock()
 }
+
+// Export copy all nodeid and path pairs, e.g. to hand them over in Opt.Takeover
+func (fp *FusePathManager) Export() map[uint64]string {
+	fp.lk.RLock()
+	dict := make(map[uint64]string, len(fp.pathDict))
+	for nodeid, path := range fp.pathDict {
+		dict[nodeid] = path
+	}
+	fp.lk.RUnlock()
+	return dict
+}
+
+// Import set all nodeid and path pairs, e.g. from the state received in Opt.Resume
+func (fp *FusePathManager) Import(dict map[uint64]string) {
+	fp.lk.Lock()
+	for nodeid, path := range dict {
+		fp.pathDict[nodeid] = path
+	}
+	fp.lk.Unlock()
+}
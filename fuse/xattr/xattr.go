@@ -0,0 +1,273 @@
+package xattr
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+)
+
+/* The namespaces of xattr */
+const (
+	NamespaceUser     = "user"
+	NamespaceTrusted  = "trusted"
+	NamespaceSecurity = "security"
+	NamespaceSystem   = "system"
+)
+
+// Handler : the operations of the xattrs routed to it,
+// a nil operation uses the default result
+type Handler struct {
+	/**
+	 * Get the value of name, errno.ENODATA if it does not exist.
+	 * nil means every name does not exist.
+	 */
+	Get func(req fuse.Req, nodeid uint64, name string) (value []byte, res int32)
+
+	/**
+	 * Set the value of name. flags is already checked by the router
+	 * with Get, check it again if it should be atomic.
+	 * nil means errno.ENOTSUP, e.g. the attributes are read-only.
+	 */
+	Set func(req fuse.Req, nodeid uint64, name string, value []byte, flags uint32) (res int32)
+
+	/**
+	 * List the names of nodeid, the names routed to other handlers
+	 * are dropped. nil means no names for a namespace, and the name
+	 * itself if Get succeeds for an exact name.
+	 */
+	List func(req fuse.Req, nodeid uint64) (names []string, res int32)
+
+	/**
+	 * Remove name, errno.ENODATA if it does not exist.
+	 * nil means errno.ENOTSUP.
+	 */
+	Remove func(req fuse.Req, nodeid uint64, name string) (res int32)
+}
+
+type prefixHandler struct {
+	prefix  string
+	handler *Handler
+}
+
+// Router : route the xattr operations to the handlers by name.
+// An exact name is routed before its namespace, the names of no
+// handler get errno.ENOTSUP. Register the handlers before the
+// session runs, use Install to set the router to fuse.Opt.
+type Router struct {
+	names    map[string]*Handler
+	nameList []string
+
+	prefixes []prefixHandler
+}
+
+// NewRouter : the function to new a xattr router
+func NewRouter() *Router {
+	r := &Router{}
+	r.names = make(map[string]*Handler)
+
+	return r
+}
+
+// HandleNamespace : route the names "namespace.*" to h, namespace
+// may be a sub namespace such as "trusted.overlay"
+func (r *Router) HandleNamespace(namespace string, h *Handler) {
+
+	prefix := strings.TrimSuffix(namespace, ".") + "."
+
+	for i := range r.prefixes {
+		if r.prefixes[i].prefix == prefix {
+			r.prefixes[i].handler = h
+			return
+		}
+	}
+
+	r.prefixes = append(r.prefixes, prefixHandler{prefix: prefix, handler: h})
+
+	// the longest prefix first
+	sort.SliceStable(r.prefixes, func(i, j int) bool {
+		return len(r.prefixes[i].prefix) > len(r.prefixes[j].prefix)
+	})
+}
+
+// HandleName : route the name to h
+func (r *Router) HandleName(name string, h *Handler) {
+
+	if _, ok := r.names[name]; !ok {
+		r.nameList = append(r.nameList, name)
+	}
+
+	r.names[name] = h
+}
+
+// route : the handler of name, nil if none
+func (r *Router) route(name string) *Handler {
+
+	if h, ok := r.names[name]; ok {
+		return h
+	}
+
+	for _, ph := range r.prefixes {
+		if strings.HasPrefix(name, ph.prefix) {
+			return ph.handler
+		}
+	}
+
+	return nil
+}
+
+// Getxattr : get the value of name from its handler
+func (r *Router) Getxattr(req fuse.Req, nodeid uint64, name string) ([]byte, int32) {
+
+	h := r.route(name)
+	if h == nil {
+		return nil, errno.ENOTSUP
+	}
+
+	if h.Get == nil {
+		return nil, errno.ENODATA
+	}
+
+	return h.Get(req, nodeid, name)
+}
+
+// Setxattr : set the value of name by its handler, fuse.XattrCreate
+// fails with errno.EEXIST if name exists, fuse.XattrReplace fails with
+// errno.ENODATA if it does not exist
+func (r *Router) Setxattr(req fuse.Req, nodeid uint64, name string, value []byte, flags uint32) int32 {
+
+	h := r.route(name)
+	if h == nil || h.Set == nil {
+		return errno.ENOTSUP
+	}
+
+	create := (flags & fuse.XattrCreate) != 0
+	replace := (flags & fuse.XattrReplace) != 0
+
+	if create && replace {
+		return errno.EINVAL
+	}
+
+	if create || replace {
+
+		var res int32 = errno.ENODATA
+		if h.Get != nil {
+			_, res = h.Get(req, nodeid, name)
+		}
+
+		if create && res == errno.SUCCESS {
+			return errno.EEXIST
+		}
+		if replace && res == errno.ENODATA {
+			return errno.ENODATA
+		}
+		if res != errno.SUCCESS && res != errno.ENODATA {
+			return res
+		}
+	}
+
+	return h.Set(req, nodeid, name, value, flags)
+}
+
+// Removexattr : remove name by its handler
+func (r *Router) Removexattr(req fuse.Req, nodeid uint64, name string) int32 {
+
+	h := r.route(name)
+	if h == nil || h.Remove == nil {
+		return errno.ENOTSUP
+	}
+
+	return h.Remove(req, nodeid, name)
+}
+
+// Listxattr : the names of all handlers, each name is listed once by the
+// handler it is routed to. errno.ENODATA and errno.ENOTSUP of a handler
+// are taken as no names, other errors fail the whole list.
+func (r *Router) Listxattr(req fuse.Req, nodeid uint64) ([]string, int32) {
+
+	var names []string
+	seen := make(map[string]bool)
+
+	add := func(h *Handler, list []string) {
+		for _, name := range list {
+			if seen[name] || r.route(name) != h {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range r.nameList {
+
+		h := r.names[name]
+
+		if h.List == nil {
+			if h.Get == nil {
+				continue
+			}
+			_, res := h.Get(req, nodeid, name)
+			if res == errno.SUCCESS {
+				add(h, []string{name})
+			} else if res != errno.ENODATA && res != errno.ENOTSUP {
+				return nil, res
+			}
+			continue
+		}
+
+		list, res := h.List(req, nodeid)
+		if res != errno.SUCCESS && res != errno.ENODATA && res != errno.ENOTSUP {
+			return nil, res
+		}
+		if res == errno.SUCCESS {
+			add(h, list)
+		}
+	}
+
+	for _, ph := range r.prefixes {
+
+		if ph.handler.List == nil {
+			continue
+		}
+
+		list, res := ph.handler.List(req, nodeid)
+		if res != errno.SUCCESS && res != errno.ENODATA && res != errno.ENOTSUP {
+			return nil, res
+		}
+		if res == errno.SUCCESS {
+			add(ph.handler, list)
+		}
+	}
+
+	return names, errno.SUCCESS
+}
+
+// Install : use r as SetxattrBytes, GetxattrBytes, ListxattrNames and
+// Removexattr of opts. The xattr handlers already in opts are replaced.
+func Install(opts *fuse.Opt, r *Router) {
+
+	setxattr := func(req fuse.Req, nodeid uint64, name string, value []byte, flags uint32) int32 {
+		return r.Setxattr(req, nodeid, name, value, flags)
+	}
+
+	getxattr := func(req fuse.Req, nodeid uint64, name string, size uint32) ([]byte, int32) {
+		return r.Getxattr(req, nodeid, name)
+	}
+
+	listxattr := func(req fuse.Req, nodeid uint64, size uint32) ([]string, int32) {
+		return r.Listxattr(req, nodeid)
+	}
+
+	removexattr := func(req fuse.Req, nodeid uint64, name string) int32 {
+		return r.Removexattr(req, nodeid, name)
+	}
+
+	opts.Setxattr = nil
+	opts.Getxattr = nil
+	opts.Listxattr = nil
+	opts.SetxattrBytes = &setxattr
+	opts.GetxattrBytes = &getxattr
+	opts.ListxattrNames = &listxattr
+	opts.Removexattr = &removexattr
+}
@@ -0,0 +1,131 @@
+package test
+
+import (
+	"bytes"
+	"syscall"
+	"testing"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/acl"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+	"github.com/mingforpc/fuse-go/fuse/perm"
+)
+
+// aclValue : "u::rw-,u:1001:rwx,g::r--,m::r-x,o::---" in the xattr format
+var aclValue = []byte{
+	0x02, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x06, 0x00, 0xff, 0xff, 0xff, 0xff,
+	0x02, 0x00, 0x07, 0x00, 0xe9, 0x03, 0x00, 0x00,
+	0x04, 0x00, 0x04, 0x00, 0xff, 0xff, 0xff, 0xff,
+	0x10, 0x00, 0x05, 0x00, 0xff, 0xff, 0xff, 0xff,
+	0x20, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff,
+}
+
+//TestACLCodec : test decode and encode of the posix acl xattr
+func TestACLCodec(t *testing.T) {
+
+	a, err := acl.Decode(aclValue)
+	if err != nil {
+		t.Fatalf("TestACLCodec err: %+v \n", err)
+	}
+
+	if len(a) != 5 {
+		t.Fatalf("TestACLCodec should have %d entries, not %d \n", 5, len(a))
+	}
+	if a[1].Tag != acl.TagUser || a[1].ID != 1001 || a[1].Perm != acl.PermRead|acl.PermWrite|acl.PermExecute {
+		t.Errorf("TestACLCodec user entry not correct: %+v \n", a[1])
+	}
+	if err := a.Valid(); err != nil {
+		t.Errorf("TestACLCodec acl should be valid: %+v \n", err)
+	}
+
+	// encode sorts the entries
+	reversed := acl.ACL{a[4], a[3], a[2], a[1], a[0]}
+	if !bytes.Equal(reversed.Encode(), aclValue) {
+		t.Errorf("TestACLCodec encode not correct: %x \n", reversed.Encode())
+	}
+
+	if _, err := acl.Decode(aclValue[:10]); err != acl.ErrSize {
+		t.Errorf("TestACLCodec short value should be ErrSize, not %+v \n", err)
+	}
+
+	if err := (acl.ACL{a[0], a[1], a[2], a[4]}).Valid(); err != acl.ErrInvalid {
+		t.Errorf("TestACLCodec acl without mask should be invalid, not %+v \n", err)
+	}
+}
+
+//TestACLMode : test the mode of acl and chmod
+func TestACLMode(t *testing.T) {
+
+	a, _ := acl.Decode(aclValue)
+
+	if mode := a.Mode(); mode != 0650 {
+		t.Errorf("TestACLMode mode should be %o, not %o \n", 0650, mode)
+	}
+
+	b := a.Chmod(0704)
+	if mode := b.Mode(); mode != 0704 {
+		t.Errorf("TestACLMode mode after chmod should be %o, not %o \n", 0704, mode)
+	}
+	// the group entry is kept, the mask is changed
+	if b[2].Perm != acl.PermRead {
+		t.Errorf("TestACLMode group entry should not be changed: %+v \n", b[2])
+	}
+
+	if !acl.FromMode(0640).IsMinimal() || a.IsMinimal() {
+		t.Errorf("TestACLMode IsMinimal not correct \n")
+	}
+}
+
+//TestACLInherit : test inheritance of the default acl
+func TestACLInherit(t *testing.T) {
+
+	def, _ := acl.Decode(aclValue)
+
+	access, dirDefault, mode := acl.Inherit(def, syscall.S_IFDIR|0755, 077, true)
+	if mode != syscall.S_IFDIR|0650 {
+		t.Errorf("TestACLInherit mode should be %o, not %o \n", syscall.S_IFDIR|0650, mode)
+	}
+	if access == nil || dirDefault == nil {
+		t.Errorf("TestACLInherit directory should have access and default acl \n")
+	}
+
+	access, dirDefault, mode = acl.Inherit(nil, syscall.S_IFREG|0666, 022, false)
+	if mode != syscall.S_IFREG|0644 || access != nil || dirDefault != nil {
+		t.Errorf("TestACLInherit without default acl should apply umask, mode %o \n", mode)
+	}
+}
+
+//TestACLCheck : test access check by acl
+func TestACLCheck(t *testing.T) {
+
+	a, _ := acl.Decode(aclValue)
+
+	fsStat := &fuse.FileStat{}
+	fsStat.Stat.Mode = syscall.S_IFREG | a.Mode()
+	fsStat.Stat.Uid = 1000
+	fsStat.Stat.Gid = 100
+
+	cases := []struct {
+		req    fuse.Req
+		mask   uint32
+		result int32
+	}{
+		{fuse.Req{UID: 1000, Gid: 1000}, perm.ROK | perm.WOK, errno.SUCCESS},
+		{fuse.Req{UID: 1000, Gid: 1000}, perm.XOK, errno.EACCES},
+		// named user is limited by the mask
+		{fuse.Req{UID: 1001, Gid: 1001}, perm.ROK | perm.XOK, errno.SUCCESS},
+		{fuse.Req{UID: 1001, Gid: 1001}, perm.WOK, errno.EACCES},
+		{fuse.Req{UID: 1002, Gid: 100}, perm.ROK, errno.SUCCESS},
+		{fuse.Req{UID: 1002, Gid: 100}, perm.XOK, errno.EACCES},
+		{fuse.Req{UID: 1003, Gid: 1003}, perm.ROK, errno.EACCES},
+		{fuse.Req{UID: 1003, Gid: 1003}, perm.FOK, errno.SUCCESS},
+	}
+
+	for i, c := range cases {
+		result := acl.Check(a, fsStat, c.req, c.mask)
+		if result != c.result {
+			t.Errorf("TestACLCheck case[%d] should be %d, not %d \n", i, c.result, result)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package test
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/cuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+	"github.com/mingforpc/fuse-go/fuse/fusetest"
+	"github.com/mingforpc/fuse-go/fuse/kernel"
+)
+
+//TestCuseInit : the reply of CUSE_INIT, cuse_init_out and the device info
+func TestCuseInit(t *testing.T) {
+
+	opts := fuse.Opt{}
+	se := cuse.NewCuseSession("fusego-test", 240, 7, &opts, 16)
+	se.SetCuseInfo(&fuse.CuseInfo{
+		DevName:  "fusego-test",
+		DevMajor: 240,
+		DevMinor: 7,
+		MaxRead:  65536,
+		Flags:    kernel.CuseUnrestrictedIoctl,
+	})
+
+	k := fusetest.NewKernel(se)
+	defer k.Close()
+
+	reply, err := k.Call(kernel.CuseInit, 0, kernel.CuseInitIn{Major: 7, Minor: 31, Flags: kernel.CuseUnrestrictedIoctl})
+	if err != nil || reply.Errno != errno.SUCCESS {
+		t.Fatalf("cuse init res: %d, err: %+v \n", reply.Errno, err)
+	}
+
+	// struct cuse_init_out is 72 bytes, then "DEVNAME=...\0"
+	data := reply.Data
+	devinfo := "DEVNAME=fusego-test\x00"
+	if len(data) != 72+len(devinfo) {
+		t.Fatalf("cuse init reply length %d, should be %d \n", len(data), 72+len(devinfo))
+	}
+
+	fields := []struct {
+		name   string
+		offset int
+		value  uint32
+	}{
+		{"major", 0, kernel.FuseKernelVersion},
+		{"minor", 4, kernel.FuseKernelMinorVersion},
+		{"flags", 12, kernel.CuseUnrestrictedIoctl},
+		{"max_read", 16, 65536},
+		{"dev_major", 24, 240},
+		{"dev_minor", 28, 7},
+	}
+	for _, field := range fields {
+		if v := binary.LittleEndian.Uint32(data[field.offset:]); v != field.value {
+			t.Errorf("cuse_init_out.%s: %d, should be %d \n", field.name, v, field.value)
+		}
+	}
+	if maxWrite := binary.LittleEndian.Uint32(data[20:]); maxWrite == 0 {
+		t.Errorf("cuse_init_out.max_write should not be 0 \n")
+	}
+	for i := 32; i < 72; i++ {
+		if data[i] != 0 {
+			t.Fatalf("cuse_init_out.spare[%d] is not 0 \n", i-32)
+		}
+	}
+
+	if got := string(data[72:]); got != devinfo {
+		t.Errorf("device info: %q, should be %q \n", got, devinfo)
+	}
+}
+
+//TestCuseInitError : CUSE_INIT without the device info or with a too long name
+func TestCuseInitError(t *testing.T) {
+
+	tests := []struct {
+		info *fuse.CuseInfo
+		res  int32
+	}{
+		{nil, errno.ENODEV},
+		{&fuse.CuseInfo{DevName: strings.Repeat("d", kernel.CuseInitInfoMax)}, errno.EINVAL},
+	}
+
+	for _, test := range tests {
+		opts := fuse.Opt{}
+		se := fuse.NewFuseSession("", &opts, 16)
+		if test.info != nil {
+			se.SetCuseInfo(test.info)
+		}
+
+		k := fusetest.NewKernel(se)
+
+		reply, err := k.Call(kernel.CuseInit, 0, kernel.CuseInitIn{Major: 7, Minor: 31})
+		if err != nil || reply.Errno != test.res || len(reply.Data) != 0 {
+			t.Errorf("cuse init of %+v res: %d, data: %d bytes, err: %+v, should be %d \n", test.info, reply.Errno, len(reply.Data), err, test.res)
+		}
+
+		k.Close()
+	}
+}
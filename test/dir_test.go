@@ -55,6 +55,105 @@ func TestReaddir(t *testing.T) {
 
 }
 
+func TestReaddirplus(t *testing.T) {
+	tempPoint, err := createTempPoint()
+
+	if err != nil {
+		t.Fatalf("TestReaddirplus err: %+v \n", err)
+	}
+
+	opts := fuse.Opt{}
+	opts.Getattr = &getattr
+	opts.Lookup = &lookup
+	opts.Readdirplus = &readdirplus
+
+	se := NewTestFuse(tempPoint, opts)
+
+	err = preTest(se)
+
+	if err != nil {
+		t.Fatalf("TestReaddirplus err: %+v \n", err)
+	}
+
+	go se.FuseLoop()
+	defer exitTest(se)
+
+	wait.Wait()
+
+	// read root folder, sizes come from readdirplus
+	fis, err := ioutil.ReadDir(tempPoint)
+
+	if err != nil {
+		t.Errorf("Failed to read dir: %+v \n", err)
+	}
+
+	if len(fis) != 2 {
+		t.Errorf("count of files under root[%s] should be %d \n", tempPoint, 2)
+	}
+	for _, fi := range fis {
+		name := fi.Name()
+		if name == rootFile.name {
+			if fi.Size() != rootFile.stat.Stat.Size {
+				t.Errorf("size of [%s] should be %d, not %d \n", name, rootFile.stat.Stat.Size, fi.Size())
+			}
+		} else if name != rootDir.name {
+			t.Errorf("not exist file [%s] \n", fi.Name())
+		}
+	}
+
+}
+
+func TestReaddirStream(t *testing.T) {
+	tempPoint, err := createTempPoint()
+
+	if err != nil {
+		t.Fatalf("TestReaddirStream err: %+v \n", err)
+	}
+
+	opts := fuse.Opt{}
+	opts.Getattr = &getattr
+	opts.Lookup = &lookup
+	opts.ReaddirStream = &readdirStream
+
+	se := NewTestFuse(tempPoint, opts)
+
+	err = preTest(se)
+
+	if err != nil {
+		t.Fatalf("TestReaddirStream err: %+v \n", err)
+	}
+
+	go se.FuseLoop()
+	defer exitTest(se)
+
+	wait.Wait()
+
+	// the entries do not fit in one reply, every one should be listed once
+	dir, err := os.Open(tempPoint)
+	if err != nil {
+		t.Fatalf("Failed to open dir: %+v \n", err)
+	}
+	defer dir.Close()
+
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		t.Errorf("Failed to read dir: %+v \n", err)
+	}
+
+	if len(names) != bigDirCount {
+		t.Errorf("count of files under root[%s] should be %d, not %d \n", tempPoint, bigDirCount, len(names))
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			t.Errorf("file [%s] listed twice \n", name)
+		}
+		seen[name] = true
+	}
+
+}
+
 func TestFsyncdir(t *testing.T) {
 	tempPoint, err := createTempPoint()
 
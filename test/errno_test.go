@@ -0,0 +1,112 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+	"github.com/mingforpc/fuse-go/fuse/fusetest"
+)
+
+//TestErrnoName : the names of errno for tracing
+func TestErrnoName(t *testing.T) {
+
+	tests := []struct {
+		res  int32
+		name string
+	}{
+		{errno.SUCCESS, "SUCCESS"},
+		{errno.ENOENT, "ENOENT"},
+		{errno.EWOULDBLOCK, "EAGAIN"},
+		{errno.ENOATTR, "ENODATA"},
+		{errno.EHWPOISON, "EHWPOISON"},
+		{-1000, "Errno(-1000)"},
+	}
+
+	for _, test := range tests {
+		if name := errno.Name(test.res); name != test.name {
+			t.Errorf("name of %d: %s, should be %s \n", test.res, name, test.name)
+		}
+	}
+
+	if s := errno.Errno(errno.ENOENT).Error(); s != "ENOENT: no such file or directory" {
+		t.Errorf("error of ENOENT: %s \n", s)
+	}
+}
+
+//TestErrnoFromError : the errno of Go errors
+func TestErrnoFromError(t *testing.T) {
+
+	_, statErr := os.Stat("/notexist/file")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		err error
+		res int32
+		ok  bool
+	}{
+		{nil, errno.SUCCESS, true},
+		{statErr, errno.ENOENT, true},
+		{syscall.ENOTEMPTY, errno.ENOTEMPTY, true},
+		{&os.PathError{Op: "rmdir", Path: "a", Err: syscall.EBUSY}, errno.EBUSY, true},
+		{fmt.Errorf("wrapped: %w", os.ErrExist), errno.EEXIST, true},
+		{os.ErrPermission, errno.EACCES, true},
+		{errno.Errno(errno.ESTALE), errno.ESTALE, true},
+		{fmt.Errorf("rpc: %w", errno.Errno(errno.EROFS)), errno.EROFS, true},
+		{ctx.Err(), errno.EINTR, true},
+		{context.DeadlineExceeded, errno.ETIMEDOUT, true},
+		{errors.New("backend broken"), errno.EIO, false},
+	}
+
+	for _, test := range tests {
+		res, ok := errno.FromError(test.err)
+		if res != test.res || ok != test.ok {
+			t.Errorf("errno of %v: %s %v, should be %s %v \n", test.err, errno.Name(res), ok, errno.Name(test.res), test.ok)
+		}
+	}
+}
+
+//TestErrnoHandler : the handlers return the errno of Go errors
+func TestErrnoHandler(t *testing.T) {
+
+	errs := make(chan error, 1)
+
+	osLookup := func(req fuse.Req, parentId uint64, name string) (*fuse.FileStat, int32) {
+		_, err := os.Lstat("/notexist/" + name)
+		return nil, fuse.ToErrno(err)
+	}
+
+	asyncUnlink := func(req fuse.Req, parentId uint64, name string) int32 {
+		reply := req.Async()
+		go func() {
+			errs <- reply.ReplyError(fmt.Errorf("unlink %s: %w", name, syscall.EROFS))
+		}()
+		return fuse.ReplyLater
+	}
+
+	opts := fuse.Opt{}
+	opts.Lookup = &osLookup
+	opts.Unlink = &asyncUnlink
+
+	k := newTestKernel(t, opts)
+	defer k.Close()
+
+	_, res, err := k.Lookup(fusetest.RootID, "file")
+	if err != nil || res != errno.ENOENT {
+		t.Errorf("lookup res should be ENOENT, not %s, err: %+v \n", errno.Name(res), err)
+	}
+
+	res, err = k.Unlink(fusetest.RootID, "file")
+	if err != nil || res != errno.EROFS {
+		t.Errorf("unlink res should be EROFS, not %s, err: %+v \n", errno.Name(res), err)
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("reply error err: %+v \n", err)
+	}
+}
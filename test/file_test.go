@@ -5,10 +5,12 @@ import (
 	"path/filepath"
 	"syscall"
 	"testing"
+	"time"
 
 	"golang.org/x/sys/unix"
 
 	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/locks"
 )
 
 func TestMknod(t *testing.T) {
@@ -279,6 +281,58 @@ func TestOpenReadWrite(t *testing.T) {
 	}
 }
 
+func TestWritebackCacheOpenFlags(t *testing.T) {
+	tempPoint, err := createTempPoint()
+
+	if err != nil {
+		t.Fatalf("TestWritebackCacheOpenFlags err: %+v \n", err)
+	}
+
+	openFlags := make(chan uint32, 1)
+	flagsOpen := func(req fuse.Req, nodeid uint64, fi *fuse.FileInfo) (result int32) {
+		select {
+		case openFlags <- fi.Flags:
+		default:
+		}
+		return open(req, nodeid, fi)
+	}
+
+	opts := fuse.Opt{}
+	opts.Getattr = &getattr
+	opts.Lookup = &lookup
+	opts.Open = &flagsOpen
+	opts.Release = &release
+
+	se := NewTestFuse(tempPoint, opts)
+	se.FuseConfig.WritebackCache = true
+
+	err = preTest(se)
+
+	if err != nil {
+		t.Fatalf("TestWritebackCacheOpenFlags err: %+v \n", err)
+	}
+
+	go se.FuseLoop()
+	defer exitTest(se)
+
+	wait.Wait()
+
+	path := tempPoint + "/" + rootFile.path
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("Failed to open file: %+v \n", err)
+	}
+	defer file.Close()
+
+	flags := <-openFlags
+	if flags&syscall.O_ACCMODE != syscall.O_RDWR {
+		t.Errorf("O_WRONLY should be opened as O_RDWR, flags: %o \n", flags)
+	}
+	if flags&syscall.O_APPEND != 0 {
+		t.Errorf("O_APPEND should be cleared, flags: %o \n", flags)
+	}
+}
+
 func TestStatfs(t *testing.T) {
 	tempPoint, err := createTempPoint()
 
@@ -316,6 +370,102 @@ func TestStatfs(t *testing.T) {
 	}
 }
 
+//TestStatfsCapacity : test statfs computed from the capacity and usage
+func TestStatfsCapacity(t *testing.T) {
+	tempPoint, err := createTempPoint()
+
+	if err != nil {
+		t.Fatalf("TestStatfsCapacity err: %+v \n", err)
+	}
+
+	opts := fuse.Opt{}
+	opts.Getattr = &getattr
+	opts.Lookup = &lookup
+
+	se := NewTestFuse(tempPoint, opts)
+	se.FuseConfig.Capacity = 1 << 30
+	se.FuseConfig.MaxInodes = 1000
+
+	err = preTest(se)
+
+	if err != nil {
+		t.Fatalf("TestStatfsCapacity err: %+v \n", err)
+	}
+
+	go se.FuseLoop()
+	defer exitTest(se)
+
+	wait.Wait()
+
+	se.AddUsedBytes(1 << 20)
+	se.AddUsedBytes(1)
+	se.AddUsedInodes(10)
+	se.AddUsedInodes(-2)
+
+	buf := syscall.Statfs_t{}
+	err = syscall.Statfs(tempPoint, &buf)
+	if err != nil {
+		t.Fatalf("Failed to call statfs: %+v \n", err)
+	}
+
+	if buf.Bsize != fuse.StatfsBlockSize {
+		t.Errorf("statfs Bsize should be %d, not %d \n", fuse.StatfsBlockSize, buf.Bsize)
+	}
+	if buf.Blocks != (1<<30)/fuse.StatfsBlockSize {
+		t.Errorf("statfs Blocks should be %d, not %d \n", (1<<30)/fuse.StatfsBlockSize, buf.Blocks)
+	}
+	// the partly used block is not free
+	if buf.Bfree != buf.Blocks-(1<<20)/fuse.StatfsBlockSize-1 || buf.Bavail != buf.Bfree {
+		t.Errorf("statfs Bfree not correct: %d, Bavail: %d \n", buf.Bfree, buf.Bavail)
+	}
+	if buf.Files != 1000 || buf.Ffree != 992 {
+		t.Errorf("statfs Files should be 1000 and Ffree 992, not %d and %d \n", buf.Files, buf.Ffree)
+	}
+}
+
+//TestStatfsPath : test statfs proxied from a host path
+func TestStatfsPath(t *testing.T) {
+	tempPoint, err := createTempPoint()
+
+	if err != nil {
+		t.Fatalf("TestStatfsPath err: %+v \n", err)
+	}
+
+	opts := fuse.Opt{}
+	opts.Getattr = &getattr
+	opts.Lookup = &lookup
+
+	se := NewTestFuse(tempPoint, opts)
+	se.FuseConfig.StatfsPath = os.TempDir()
+
+	err = preTest(se)
+
+	if err != nil {
+		t.Fatalf("TestStatfsPath err: %+v \n", err)
+	}
+
+	go se.FuseLoop()
+	defer exitTest(se)
+
+	wait.Wait()
+
+	host := syscall.Statfs_t{}
+	err = syscall.Statfs(os.TempDir(), &host)
+	if err != nil {
+		t.Fatalf("Failed to call statfs: %+v \n", err)
+	}
+
+	buf := syscall.Statfs_t{}
+	err = syscall.Statfs(tempPoint, &buf)
+	if err != nil {
+		t.Fatalf("Failed to call statfs: %+v \n", err)
+	}
+
+	if buf.Blocks != host.Blocks || buf.Bsize != host.Bsize || buf.Files != host.Files || buf.Namelen != host.Namelen {
+		t.Errorf("statfs should be the same as host %+v, not %+v \n", host, buf)
+	}
+}
+
 func compareStatfs(statfs syscall.Statfs_t) bool {
 	if statfs.Bsize != int64(rootStatfs.Bsize) {
 		return false
@@ -469,6 +619,140 @@ func TestLk(t *testing.T) {
 	}
 }
 
+func TestFlock(t *testing.T) {
+	tempPoint, err := createTempPoint()
+
+	if err != nil {
+		t.Fatalf("TestFlock err: %+v \n", err)
+	}
+
+	opts := fuse.Opt{}
+	opts.Getattr = &getattr
+	opts.Lookup = &lookup
+	opts.Open = &open
+	opts.Release = &release
+	opts.Flock = &flock
+
+	se := NewTestFuse(tempPoint, opts)
+
+	err = preTest(se)
+
+	if err != nil {
+		t.Fatalf("TestFlock err: %+v \n", err)
+	}
+
+	go se.FuseLoop()
+	defer exitTest(se)
+
+	wait.Wait()
+
+	//open
+	path := tempPoint + "/" + rootFile.path
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("Failed to open file: %+v \n", err)
+	}
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		t.Fatalf("Failed to flock file: %+v \n", err)
+	}
+
+	select {
+	case op := <-flockOps:
+		if op != syscall.LOCK_EX|syscall.LOCK_NB {
+			t.Errorf("flock op should be %d, not %d \n", syscall.LOCK_EX|syscall.LOCK_NB, op)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("flock is not sent to filesystem \n")
+	}
+
+	// the last close should unlock
+	file.Close()
+
+	select {
+	case op := <-flockOps:
+		if op != syscall.LOCK_UN {
+			t.Errorf("flock op should be %d, not %d \n", syscall.LOCK_UN, op)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("flock is not released on close \n")
+	}
+}
+
+func TestLockManager(t *testing.T) {
+	tempPoint, err := createTempPoint()
+
+	if err != nil {
+		t.Fatalf("TestLockManager err: %+v \n", err)
+	}
+
+	opts := fuse.Opt{}
+	opts.Getattr = &getattr
+	opts.Lookup = &lookup
+	opts.Open = &open
+	locks.Install(&opts, locks.NewManager())
+
+	se := NewTestFuse(tempPoint, opts)
+
+	err = preTest(se)
+
+	if err != nil {
+		t.Fatalf("TestLockManager err: %+v \n", err)
+	}
+
+	go se.FuseLoop()
+	defer exitTest(se)
+
+	wait.Wait()
+
+	// open file descriptions have their own lock owner
+	path := tempPoint + "/" + rootFile.path
+	file1, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("Failed to open file: %+v \n", err)
+	}
+	defer file1.Close()
+	file2, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("Failed to open file: %+v \n", err)
+	}
+	defer file2.Close()
+
+	lock := unix.Flock_t{Type: unix.F_WRLCK, Whence: 0, Start: 0, Len: 10}
+	err = unix.FcntlFlock(file1.Fd(), unix.F_OFD_SETLK, &lock)
+	if err != nil {
+		t.Fatalf("Failed to set file lock: %+v \n", err)
+	}
+
+	// conflict
+	lock = unix.Flock_t{Type: unix.F_RDLCK, Whence: 0, Start: 5, Len: 10}
+	err = unix.FcntlFlock(file2.Fd(), unix.F_OFD_SETLK, &lock)
+	if err != unix.EAGAIN {
+		t.Errorf("set conflicting lock err should be EAGAIN, not %+v \n", err)
+	}
+
+	err = unix.FcntlFlock(file2.Fd(), unix.F_OFD_GETLK, &lock)
+	if err != nil {
+		t.Fatalf("Failed to get file lock: %+v \n", err)
+	}
+	if lock.Type != unix.F_WRLCK || lock.Start != 0 || lock.Len != 10 {
+		t.Errorf("conflicting lock should be write lock [0, 10), not %+v \n", lock)
+	}
+
+	// no conflict after the range is unlocked
+	lock = unix.Flock_t{Type: unix.F_UNLCK, Whence: 0, Start: 5, Len: 5}
+	err = unix.FcntlFlock(file1.Fd(), unix.F_OFD_SETLK, &lock)
+	if err != nil {
+		t.Fatalf("Failed to unlock file lock: %+v \n", err)
+	}
+	lock = unix.Flock_t{Type: unix.F_RDLCK, Whence: 0, Start: 5, Len: 10}
+	err = unix.FcntlFlock(file2.Fd(), unix.F_OFD_SETLK, &lock)
+	if err != nil {
+		t.Errorf("Failed to set file lock: %+v \n", err)
+	}
+}
+
 // TODO: how to test？
 func TestBmap(t *testing.T) {
 }
@@ -0,0 +1,704 @@
+package test
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+	"github.com/mingforpc/fuse-go/fuse/fusetest"
+	"github.com/mingforpc/fuse-go/fuse/kernel"
+)
+
+// newTestKernel : serve opts by a fake kernel, after INIT
+func newTestKernel(t *testing.T, opts fuse.Opt) *fusetest.Kernel {
+
+	se := fuse.NewFuseSession("", &opts, 16)
+	se.FuseConfig.AttrTimeout = 1
+
+	k := fusetest.NewKernel(se)
+
+	_, res, err := k.Init(kernel.FuseInitIn{})
+	if err != nil || res != errno.SUCCESS {
+		k.Close()
+		t.Fatalf("init res: %d, err: %+v \n", res, err)
+	}
+
+	return k
+}
+
+//TestKernelEncode : every request argument encoded by fusetest is parsed back
+func TestKernelEncode(t *testing.T) {
+
+	tests := []struct {
+		in   interface{}
+		size int
+	}{
+		{kernel.FuseGetattrIn{}, 16},
+		{kernel.FuseSetattrIn{}, 88},
+		{kernel.FuseReadIn{}, 40},
+		{kernel.FuseReleaseIn{}, 24},
+		{kernel.FuseFlushIn{}, 24},
+		{kernel.FuseLkIn{}, 48},
+		{kernel.FuseInitIn{}, 64},
+		{kernel.FuseMknodIn{Name: "a"}, 18},
+		{kernel.FuseRename2In{OldName: "a", NewName: "b"}, 20},
+		{kernel.FuseWriteIn{Size: 3, Buf: []byte("abc")}, 43},
+	}
+
+	for _, test := range tests {
+		b, err := fusetest.Encode(test.in)
+		if err != nil || len(b) != test.size {
+			t.Errorf("encode %T: size %d (err %+v), should be %d \n", test.in, len(b), err, test.size)
+		}
+	}
+
+	b, _ := fusetest.Encode(kernel.FuseRename2In{NewDir: 9, OldName: "old", NewName: "new"})
+	in := kernel.FuseRename2In{}
+	if err := in.ParseBinary(b); err != nil || in.NewDir != 9 || in.OldName != "old" || in.NewName != "new" {
+		t.Errorf("rename2 parsed: %+v, err: %+v \n", in, err)
+	}
+}
+
+//TestKernelLookup : lookup and getattr without mounting
+func TestKernelLookup(t *testing.T) {
+
+	opts := fuse.Opt{}
+	opts.Getattr = &getattr
+	opts.Lookup = &lookup
+
+	k := newTestKernel(t, opts)
+	defer k.Close()
+
+	entry, res, err := k.Lookup(fusetest.RootID, rootFile.name)
+	if err != nil || res != errno.SUCCESS {
+		t.Fatalf("lookup res: %d, err: %+v \n", res, err)
+	}
+	if entry.NodeID != rootFile.stat.Nodeid || entry.Attr.Size != uint64(rootFile.stat.Stat.Size) || entry.AttrValid != 1 {
+		t.Errorf("lookup entry: %+v \n", entry)
+	}
+
+	_, res, err = k.Lookup(fusetest.RootID, "notexist")
+	if err != nil || res != errno.ENOENT {
+		t.Errorf("lookup notexist res should be ENOENT, not %d, err: %+v \n", res, err)
+	}
+
+	attr, res, err := k.Getattr(rootDir.stat.Nodeid)
+	if err != nil || res != errno.SUCCESS || attr.Attr.Mode != rootDir.stat.Stat.Mode || attr.Attr.UID != 2 {
+		t.Errorf("getattr res: %d, attr: %+v, err: %+v \n", res, attr, err)
+	}
+
+	// no Opt.Statfs, the library answers
+	_, res, err = k.Statfs(fusetest.RootID)
+	if err != nil || res != errno.SUCCESS {
+		t.Errorf("statfs res: %d, err: %+v \n", res, err)
+	}
+
+	// no Opt.Mkdir
+	_, res, err = k.Mkdir(fusetest.RootID, "dir", 0755, 022)
+	if err != nil || res != errno.ENOSYS {
+		t.Errorf("mkdir res should be ENOSYS, not %d, err: %+v \n", res, err)
+	}
+}
+
+// exportFs : a tree of root(1)/dir(5)/file(6) counting the lookups, for the
+// lookups of "." and ".." with export support
+type exportFs struct {
+	lock    sync.Mutex
+	nlookup map[uint64]uint64
+	forgets chan uint64
+}
+
+func newExportFs() *exportFs {
+	return &exportFs{nlookup: make(map[uint64]uint64), forgets: make(chan uint64, 8)}
+}
+
+func (fs *exportFs) entry(nodeid uint64) *fuse.FileStat {
+
+	fs.lock.Lock()
+	fs.nlookup[nodeid]++
+	fs.lock.Unlock()
+
+	stat := fuse.FileStat{Nodeid: nodeid}
+	stat.Stat.Ino = nodeid
+	stat.Stat.Mode = syscall.S_IFDIR | 0755
+	if nodeid == 6 {
+		stat.Stat.Mode = syscall.S_IFREG | 0644
+	}
+	return &stat
+}
+
+func (fs *exportFs) count(nodeid uint64) uint64 {
+
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	return fs.nlookup[nodeid]
+}
+
+func (fs *exportFs) opts(getParent bool) fuse.Opt {
+
+	parents := map[uint64]uint64{5: fusetest.RootID, 6: 5}
+
+	lookup := func(req fuse.Req, parentId uint64, name string) (*fuse.FileStat, int32) {
+		switch {
+		case name == ".":
+			return fs.entry(parentId), errno.SUCCESS
+		case name == ".." && parents[parentId] != 0:
+			return fs.entry(parents[parentId]), errno.SUCCESS
+		case parentId == fusetest.RootID && name == "dir":
+			return fs.entry(5), errno.SUCCESS
+		case parentId == 5 && name == "file":
+			return fs.entry(6), errno.SUCCESS
+		}
+		return nil, errno.ENOENT
+	}
+	parent := func(req fuse.Req, nodeid uint64) (*fuse.FileStat, int32) {
+		if parents[nodeid] == 0 {
+			return nil, errno.ENOENT
+		}
+		return fs.entry(parents[nodeid]), errno.SUCCESS
+	}
+	forget := func(req fuse.Req, nodeid uint64, nlookup uint64) {
+		fs.lock.Lock()
+		fs.nlookup[nodeid] -= nlookup
+		fs.lock.Unlock()
+		fs.forgets <- nodeid
+	}
+
+	opts := fuse.Opt{}
+	opts.Lookup = &lookup
+	opts.Forget = &forget
+	if getParent {
+		opts.GetParent = &parent
+	}
+	return opts
+}
+
+//TestKernelLookupDot : the lookups of "." and ".." of export support are counted and forgotten like the others
+func TestKernelLookupDot(t *testing.T) {
+
+	for _, getParent := range []bool{true, false} {
+		fs := newExportFs()
+
+		k := newTestKernel(t, fs.opts(getParent))
+
+		entry, res, err := k.Lookup(5, ".")
+		if err != nil || res != errno.SUCCESS || entry.NodeID != 5 || entry.Attr.Ino != 5 {
+			t.Errorf("lookup . res: %d, entry: %+v, err: %+v \n", res, entry, err)
+		}
+
+		entry, res, err = k.Lookup(5, "..")
+		if err != nil || res != errno.SUCCESS || entry.NodeID != fusetest.RootID {
+			t.Errorf("lookup .. with GetParent[%v] res: %d, entry: %+v, err: %+v \n", getParent, res, entry, err)
+		}
+
+		_, res, err = k.Lookup(fusetest.RootID, "..")
+		if err != nil || res != errno.ENOENT {
+			t.Errorf("lookup .. of root res should be ENOENT, not %d, err: %+v \n", res, err)
+		}
+
+		if fs.count(5) != 1 || fs.count(fusetest.RootID) != 1 {
+			t.Errorf("nlookup after . and ..: %+v \n", fs.nlookup)
+		}
+
+		k.Forget(5, 1)
+		k.Forget(fusetest.RootID, 1)
+		<-fs.forgets
+		<-fs.forgets
+		if fs.count(5) != 0 || fs.count(fusetest.RootID) != 0 {
+			t.Errorf("nlookup after forget: %+v \n", fs.nlookup)
+		}
+
+		k.Close()
+	}
+}
+
+//TestKernelGeneration : the generation of a nodeid across forget and re-lookup
+func TestKernelGeneration(t *testing.T) {
+
+	fs := newExportFs()
+
+	k := newTestKernel(t, fs.opts(true))
+	defer k.Close()
+
+	se := k.Session()
+
+	entry, res, err := k.Lookup(5, "file")
+	if err != nil || res != errno.SUCCESS || entry.NodeID != 6 || entry.Generation != 0 {
+		t.Fatalf("lookup res: %d, entry: %+v, err: %+v \n", res, entry, err)
+	}
+
+	// forgotten but not reused, the generation stays
+	k.Forget(6, 1)
+	<-fs.forgets
+	entry, _, _ = k.Lookup(5, "file")
+	if entry.Generation != 0 {
+		t.Errorf("generation after re-lookup: %d, should be 0 \n", entry.Generation)
+	}
+
+	// nodeid 6 is reused for another file after forget
+	k.Forget(6, 1)
+	<-fs.forgets
+	gen := se.BumpGeneration(6)
+
+	entry, _, _ = k.Lookup(5, "file")
+	if entry.NodeID != 6 || entry.Generation != gen || gen != 1 {
+		t.Errorf("generation after reuse: %d, should be %d \n", entry.Generation, gen)
+	}
+
+	// "." and ".." reply the generation of the nodeid too
+	se.BumpGeneration(5)
+	entry, _, _ = k.Lookup(5, ".")
+	if entry.Generation != se.Generation(5) {
+		t.Errorf("generation of .: %d, should be %d \n", entry.Generation, se.Generation(5))
+	}
+	entry, _, _ = k.Lookup(6, "..")
+	if entry.NodeID != 5 || entry.Generation != se.Generation(5) {
+		t.Errorf("generation of ..: %+v, should be %d \n", entry, se.Generation(5))
+	}
+}
+
+//TestKernelReaddirplusFallback : readdirplus built from Readdir and Lookup without Readdirplus
+func TestKernelReaddirplusFallback(t *testing.T) {
+
+	fs := newExportFs()
+
+	readdir := func(req fuse.Req, nodeid uint64, size uint32, offset uint64, fi fuse.FileInfo) ([]fuse.Dirent, int32) {
+		names := []string{".", "..", "file"}
+		dirents := make([]fuse.Dirent, 0, len(names))
+		for i, name := range names[offset:] {
+			dirents = append(dirents, fuse.Dirent{Ino: uint64(i) + offset + 5, NameLen: uint32(len(name)), Name: name})
+		}
+		return dirents, errno.SUCCESS
+	}
+
+	opts := fs.opts(true)
+	opts.Readdir = &readdir
+
+	se := fuse.NewFuseSession("", &opts, 16)
+	se.FuseConfig.AttrTimeout = 1
+
+	k := fusetest.NewKernel(se)
+	defer k.Close()
+
+	initOut, res, err := k.Init(kernel.FuseInitIn{Flags: kernel.FuseDoReaddirplus | kernel.FuseReaddirplusAuto})
+	if err != nil || res != errno.SUCCESS || initOut.Flags&kernel.FuseDoReaddirplus == 0 || initOut.Flags&kernel.FuseReaddirplusAuto == 0 {
+		t.Fatalf("readdirplus should be negotiated with Readdir and Lookup, init res: %d, out: %+v, err: %+v \n", res, initOut, err)
+	}
+
+	entries, res, err := k.Readdirplus(5, 0, 0, 4096)
+	if err != nil || res != errno.SUCCESS || len(entries) != 3 {
+		t.Fatalf("readdirplus res: %d, entries: %+v, err: %+v \n", res, entries, err)
+	}
+
+	// "." and ".." are not looked up, the kernel does not forget them
+	for _, entry := range entries[:2] {
+		if entry.EntryOut.NodeID != 0 {
+			t.Errorf("entry of %s should be empty: %+v \n", entry.Dirent.Name, entry.EntryOut)
+		}
+	}
+	file := entries[2]
+	if file.Dirent.Name != "file" || file.EntryOut.NodeID != 6 || file.EntryOut.AttrValid != 1 || file.EntryOut.Attr.Mode != syscall.S_IFREG|0644 {
+		t.Errorf("entry of file: %+v \n", file)
+	}
+	if fs.count(6) != 1 || fs.count(5) != 0 {
+		t.Errorf("nlookup after readdirplus: %+v \n", fs.nlookup)
+	}
+
+	// the entry not fitting the buffer is not looked up
+	size := kernel.DirentplusSize(1) + kernel.DirentplusSize(2)
+	entries, res, err = k.Readdirplus(5, 0, 0, uint32(size))
+	if err != nil || res != errno.SUCCESS || len(entries) != 2 {
+		t.Errorf("readdirplus small buffer res: %d, entries: %+v, err: %+v \n", res, entries, err)
+	}
+	if fs.count(6) != 1 {
+		t.Errorf("nlookup after readdirplus with small buffer: %+v \n", fs.nlookup)
+	}
+
+	k.Forget(6, 1)
+	<-fs.forgets
+	if fs.count(6) != 0 {
+		t.Errorf("nlookup after forget: %+v \n", fs.nlookup)
+	}
+}
+
+//TestKernelReadWrite : open, read, write and readdir without mounting
+func TestKernelReadWrite(t *testing.T) {
+
+	content := rootFile.content
+	defer func() { rootFile.content = content }()
+
+	opts := fuse.Opt{}
+	opts.Getattr = &getattr
+	opts.Lookup = &lookup
+	opts.Open = &open
+	opts.Read = &read
+	opts.Write = &write
+	opts.Release = &release
+	opts.Readdir = &readdir
+
+	k := newTestKernel(t, opts)
+	defer k.Close()
+
+	openOut, res, err := k.Open(rootFile.stat.Nodeid, syscall.O_RDWR)
+	if err != nil || res != errno.SUCCESS {
+		t.Fatalf("open res: %d, err: %+v \n", res, err)
+	}
+
+	out, res, err := k.Write(rootFile.stat.Nodeid, openOut.Fh, 6, []byte("fuse!"))
+	if err != nil || res != errno.SUCCESS || out.Size != 5 {
+		t.Errorf("write res: %d, out: %+v, err: %+v \n", res, out, err)
+	}
+
+	data, res, err := k.Read(rootFile.stat.Nodeid, openOut.Fh, 0, 4096)
+	if err != nil || res != errno.SUCCESS || string(data) != "hello fuse!!\n" {
+		t.Errorf("read res: %d, data: %q, err: %+v \n", res, data, err)
+	}
+
+	res, err = k.Release(rootFile.stat.Nodeid, openOut.Fh, syscall.O_RDWR)
+	if err != nil || res != errno.SUCCESS {
+		t.Errorf("release res: %d, err: %+v \n", res, err)
+	}
+
+	_, res, err = k.Open(rootDir.stat.Nodeid, syscall.O_RDONLY)
+	if err != nil || res != errno.EACCES {
+		t.Errorf("open dir res should be EACCES, not %d, err: %+v \n", res, err)
+	}
+
+	dirents, res, err := k.Readdir(fusetest.RootID, 0, 0, 4096)
+	if err != nil || res != errno.SUCCESS {
+		t.Fatalf("readdir res: %d, err: %+v \n", res, err)
+	}
+
+	var names []string
+	for _, dirent := range dirents {
+		names = append(names, dirent.Name)
+	}
+	if len(names) != 4 || names[2] != rootFile.name || names[3] != rootDir.name {
+		t.Errorf("readdir names: %v \n", names)
+	}
+}
+
+//TestKernelReadVec : the content of ReadVec in pieces is read as a whole
+func TestKernelReadVec(t *testing.T) {
+
+	readVec := func(req fuse.Req, nodeid uint64, size uint32, offset uint64, fi fuse.FileInfo) (content [][]byte, result int32) {
+		// more pieces than an iovec takes, and some empty
+		for i := uint64(0); i < uint64(size); i++ {
+			content = append(content, []byte{byte(offset + i)}, nil)
+		}
+		return content, errno.SUCCESS
+	}
+
+	opts := fuse.Opt{}
+	opts.Read = &read
+	opts.ReadVec = &readVec
+
+	k := newTestKernel(t, opts)
+	defer k.Close()
+
+	for _, size := range []uint32{0, 3, 4000} {
+		data, res, err := k.Read(rootFile.stat.Nodeid, 0, 7, size)
+		if err != nil || res != errno.SUCCESS || len(data) != int(size) {
+			t.Fatalf("read %d res: %d, len: %d, err: %+v \n", size, res, len(data), err)
+		}
+		for i, b := range data {
+			if b != byte(7+i) {
+				t.Fatalf("read %d: byte[%d] is %d, should be %d \n", size, i, b, byte(7+i))
+			}
+		}
+	}
+}
+
+//TestKernelSetattrWriteback : the reply of Setattr with and without writeback cache
+func TestKernelSetattrWriteback(t *testing.T) {
+
+	getattrCalls := 0
+	staleGetattr := func(req fuse.Req, nodeid uint64) (*fuse.FileStat, int32) {
+		getattrCalls++
+		// the cached writes are not in the filesystem yet
+		stat := fuse.FileStat{Nodeid: nodeid}
+		stat.Stat.Mode = syscall.S_IFREG | 0644
+		stat.Stat.Size = 10
+		stat.Stat.Mtim.Sec = 100
+		return &stat, errno.SUCCESS
+	}
+	setattr := func(req fuse.Req, nodeid uint64, attr fuse.FileStat, toSet uint32) int32 {
+		return errno.SUCCESS
+	}
+
+	in := kernel.FuseSetattrIn{
+		Valid: fuse.FuseSetAttrSize | fuse.FuseSetAttrMtime | fuse.FuseSetAttrCtime,
+		Size:  4096,
+		Mtime: 200,
+		Ctime: 300,
+	}
+
+	for _, writeback := range []bool{false, true} {
+		opts := fuse.Opt{}
+		opts.Getattr = &staleGetattr
+		opts.Setattr = &setattr
+
+		se := fuse.NewFuseSession("", &opts, 16)
+		se.FuseConfig.WritebackCache = writeback
+		k := fusetest.NewKernel(se)
+
+		if _, res, err := k.Init(kernel.FuseInitIn{Major: 7, Minor: 31, Flags: kernel.FuseWritebackCache}); err != nil || res != errno.SUCCESS {
+			k.Close()
+			t.Fatalf("init res: %d, err: %+v \n", res, err)
+		}
+
+		getattrCalls = 0
+		out, res, err := k.Setattr(rootFile.stat.Nodeid, in)
+		k.Close()
+
+		if err != nil || res != errno.SUCCESS {
+			t.Fatalf("setattr writeback[%v] res: %d, err: %+v \n", writeback, res, err)
+		}
+
+		// the size and times of the kernel win over Getattr
+		if out.Attr.Size != 4096 || out.Attr.Mtime != 200 || out.Attr.Ctime != 300 {
+			t.Errorf("setattr writeback[%v] attr: %+v \n", writeback, out.Attr)
+		}
+
+		if writeback && (getattrCalls != 1 || out.Attr.Mode != syscall.S_IFREG|0644) {
+			t.Errorf("setattr with writeback should reply the attributes of Getattr, calls: %d, attr: %+v \n", getattrCalls, out.Attr)
+		}
+		if !writeback && (getattrCalls != 0 || out.Attr.Mode != 0) {
+			t.Errorf("setattr without writeback should not call Getattr, calls: %d, attr: %+v \n", getattrCalls, out.Attr)
+		}
+	}
+}
+
+//TestKernelXattr : the xattr size probe and ERANGE without mounting
+func TestKernelXattr(t *testing.T) {
+
+	opts := fuse.Opt{}
+	opts.SetxattrBytes = &setxattr
+	opts.GetxattrBytes = &getxattr
+	opts.ListxattrNames = &listxattr
+	opts.Removexattr = &removexattr
+
+	k := newTestKernel(t, opts)
+	defer k.Close()
+	defer delete(xattrMap, dirFile.stat.Nodeid)
+
+	value := []byte{0, 1, 2, 0xff}
+
+	res, err := k.Setxattr(dirFile.stat.Nodeid, "user.bin", value, 0)
+	if err != nil || res != errno.SUCCESS {
+		t.Fatalf("setxattr res: %d, err: %+v \n", res, err)
+	}
+
+	_, length, res, err := k.Getxattr(dirFile.stat.Nodeid, "user.bin", 0)
+	if err != nil || res != errno.SUCCESS || length != uint32(len(value)) {
+		t.Errorf("getxattr size res: %d, length: %d, err: %+v \n", res, length, err)
+	}
+
+	_, _, res, err = k.Getxattr(dirFile.stat.Nodeid, "user.bin", 2)
+	if err != nil || res != errno.ERANGE {
+		t.Errorf("getxattr small buffer res should be ERANGE, not %d, err: %+v \n", res, err)
+	}
+
+	got, _, res, err := k.Getxattr(dirFile.stat.Nodeid, "user.bin", 64)
+	if err != nil || res != errno.SUCCESS || !bytes.Equal(got, value) {
+		t.Errorf("getxattr res: %d, value: %x, err: %+v \n", res, got, err)
+	}
+
+	names, _, res, err := k.Listxattr(dirFile.stat.Nodeid, 64)
+	if err != nil || res != errno.SUCCESS || len(names) != 1 || names[0] != "user.bin" {
+		t.Errorf("listxattr res: %d, names: %v, err: %+v \n", res, names, err)
+	}
+
+	res, err = k.Removexattr(dirFile.stat.Nodeid, "user.bin")
+	if err != nil || res != errno.SUCCESS {
+		t.Errorf("removexattr res: %d, err: %+v \n", res, err)
+	}
+
+	_, _, res, err = k.Getxattr(dirFile.stat.Nodeid, "user.bin", 64)
+	if err != nil || res != errno.ENOATTR {
+		t.Errorf("getxattr removed res should be ENOATTR, not %d, err: %+v \n", res, err)
+	}
+}
+
+//TestKernelXattrString : the xattr handlers of string still work, the ones of []byte take precedence
+func TestKernelXattrString(t *testing.T) {
+
+	values := make(map[string]string)
+
+	setxattrString := func(req fuse.Req, nodeid uint64, name string, value string, flags uint32) int32 {
+		values[name] = value
+		return errno.SUCCESS
+	}
+	getxattrString := func(req fuse.Req, nodeid uint64, name string, size uint32) (string, int32) {
+		value, ok := values[name]
+		if !ok {
+			return "", errno.ENOATTR
+		}
+		return value, errno.SUCCESS
+	}
+	listxattrString := func(req fuse.Req, nodeid uint64, size uint32) (string, int32) {
+		return "user.a\x00user.b\x00", errno.SUCCESS
+	}
+
+	opts := fuse.Opt{}
+	opts.Setxattr = &setxattrString
+	opts.Getxattr = &getxattrString
+	opts.Listxattr = &listxattrString
+
+	k := newTestKernel(t, opts)
+
+	res, err := k.Setxattr(dirFile.stat.Nodeid, "user.a", []byte("value"), 0)
+	if err != nil || res != errno.SUCCESS || values["user.a"] != "value" {
+		t.Errorf("setxattr res: %d, values: %v, err: %+v \n", res, values, err)
+	}
+
+	_, length, res, err := k.Getxattr(dirFile.stat.Nodeid, "user.a", 0)
+	if err != nil || res != errno.SUCCESS || length != 5 {
+		t.Errorf("getxattr size res: %d, length: %d, err: %+v \n", res, length, err)
+	}
+
+	got, _, res, err := k.Getxattr(dirFile.stat.Nodeid, "user.a", 64)
+	if err != nil || res != errno.SUCCESS || string(got) != "value" {
+		t.Errorf("getxattr res: %d, value: %q, err: %+v \n", res, got, err)
+	}
+
+	names, _, res, err := k.Listxattr(dirFile.stat.Nodeid, 64)
+	if err != nil || res != errno.SUCCESS || len(names) != 2 || names[1] != "user.b" {
+		t.Errorf("listxattr res: %d, names: %v, err: %+v \n", res, names, err)
+	}
+
+	k.Close()
+
+	// both set, the handlers of []byte are called
+	opts.GetxattrBytes = &getxattr
+	k = newTestKernel(t, opts)
+	defer k.Close()
+
+	_, _, res, err = k.Getxattr(dirFile.stat.Nodeid, "user.a", 64)
+	if err != nil || res != errno.ENOATTR {
+		t.Errorf("getxattr should be answered by GetxattrBytes, res: %d, err: %+v \n", res, err)
+	}
+}
+
+//TestKernelInterrupt : the interrupt of a pending request without mounting
+func TestKernelInterrupt(t *testing.T) {
+
+	started := make(chan uint64, 1)
+	slowGetattr := func(req fuse.Req, nodeid uint64) (fsStat *fuse.FileStat, result int32) {
+		started <- req.Unique
+		select {
+		case <-req.Interrupted():
+			return nil, errno.EINTR
+		case <-time.After(5 * time.Second):
+			return getattr(req, nodeid)
+		}
+	}
+
+	opts := fuse.Opt{}
+	opts.Getattr = &slowGetattr
+
+	k := newTestKernel(t, opts)
+	defer k.Close()
+
+	pending, err := k.Go(kernel.FuseOpGetattr, fusetest.RootID, kernel.FuseGetattrIn{})
+	if err != nil {
+		t.Fatalf("getattr err: %+v \n", err)
+	}
+
+	if unique := <-started; unique != pending.Unique {
+		t.Errorf("getattr unique should be %d, not %d \n", pending.Unique, unique)
+	}
+
+	if err := k.Interrupt(pending.Unique); err != nil {
+		t.Fatalf("interrupt err: %+v \n", err)
+	}
+
+	reply, err := pending.Wait()
+	if err != nil || reply.Errno != errno.EINTR {
+		t.Errorf("interrupted getattr should be EINTR, not %d, err: %+v \n", reply.Errno, err)
+	}
+}
+
+//TestKernelClose : the session exits when the kernel is closed
+func TestKernelClose(t *testing.T) {
+
+	k := newTestKernel(t, fuse.Opt{})
+	k.Close()
+
+	if k.Session().IsRunning() {
+		t.Errorf("session should not be running after close \n")
+	}
+
+	if _, _, err := k.Getattr(fusetest.RootID); err != fusetest.ErrClosed {
+		t.Errorf("getattr after close should be ErrClosed, not %+v \n", err)
+	}
+}
+
+//TestKernelSetRunning : SetRunning, in place of the former Running field, stops the session
+func TestKernelSetRunning(t *testing.T) {
+
+	k := newTestKernel(t, fuse.Opt{})
+	defer k.Close()
+
+	se := k.Session()
+	if !se.IsRunning() {
+		t.Fatalf("session should be running after init \n")
+	}
+
+	se.SetRunning(false)
+	if se.IsRunning() {
+		t.Errorf("session should not be running after SetRunning(false) \n")
+	}
+}
+
+//TestKernelCloseHandlers : Close returns after the handlers of the session return
+func TestKernelCloseHandlers(t *testing.T) {
+
+	var done int32
+	forget := func(req fuse.Req, nodeid uint64, nlookup uint64) {
+		time.Sleep(50 * time.Millisecond)
+		atomic.StoreInt32(&done, 1)
+	}
+
+	opts := fuse.Opt{}
+	opts.Forget = &forget
+
+	k := newTestKernel(t, opts)
+
+	if err := k.Forget(rootFile.stat.Nodeid, 1); err != nil {
+		t.Fatalf("forget err: %+v \n", err)
+	}
+	k.Close()
+
+	if atomic.LoadInt32(&done) != 1 {
+		t.Errorf("Close returned before the Forget handler \n")
+	}
+}
+
+//TestKernelLargeFrame : the request larger than the read buffer is answered EIO, the session goes on
+func TestKernelLargeFrame(t *testing.T) {
+
+	opts := fuse.Opt{}
+	opts.Getattr = &getattr
+	opts.Write = &write
+
+	k := newTestKernel(t, opts)
+	defer k.Close()
+
+	data := make([]byte, fuse.KernelBufPages*syscall.Getpagesize()+fuse.HeaderSize)
+	_, res, err := k.Write(rootFile.stat.Nodeid, 0, 0, data)
+	if err != nil || res != errno.EIO {
+		t.Errorf("write larger than the buffer should be EIO, not %d, err: %+v \n", res, err)
+	}
+
+	_, res, err = k.Getattr(rootFile.stat.Nodeid)
+	if err != nil || res != errno.SUCCESS {
+		t.Errorf("getattr after the large frame res: %d, err: %+v \n", res, err)
+	}
+}
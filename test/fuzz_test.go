@@ -0,0 +1,93 @@
+package test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/mingforpc/fuse-go/fuse/kernel"
+)
+
+// parsers : the parser of the argument of each opcode
+var parsers = map[uint32]func(bcontent []byte) error{
+	kernel.FuseOpInit:        func(b []byte) error { in := kernel.FuseInitIn{}; return in.ParseBinary(b) },
+	kernel.CuseInit:          func(b []byte) error { in := kernel.CuseInitIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpForget:      func(b []byte) error { in := kernel.FuseForgetIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpLookup:      func(b []byte) error { in := kernel.FuseLookupIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpGetattr:     func(b []byte) error { in := kernel.FuseGetattrIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpStatx:       func(b []byte) error { in := kernel.FuseStatxIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpSetattr:     func(b []byte) error { in := kernel.FuseSetattrIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpMknod:       func(b []byte) error { in := kernel.FuseMknodIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpMkdir:       func(b []byte) error { in := kernel.FuseMkdirIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpUnlink:      func(b []byte) error { in := kernel.FuseUnlinkIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpRmdir:       func(b []byte) error { in := kernel.FuseRmdirIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpSymlink:     func(b []byte) error { in := kernel.FuseSymlinkIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpRename:      func(b []byte) error { in := kernel.FuseRenameIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpRename2:     func(b []byte) error { in := kernel.FuseRename2In{}; return in.ParseBinary(b) },
+	kernel.FuseOpLink:        func(b []byte) error { in := kernel.FuseLinkIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpOpen:        func(b []byte) error { in := kernel.FuseOpenIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpRead:        func(b []byte) error { in := kernel.FuseReadIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpWrite:       func(b []byte) error { in := kernel.FuseWriteIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpRelease:     func(b []byte) error { in := kernel.FuseReleaseIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpFsync:       func(b []byte) error { in := kernel.FuseFsyncIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpSetxattr:    func(b []byte) error { in := kernel.FuseSetxattrIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpGetxattr:    func(b []byte) error { in := kernel.FuseGetxattrIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpRemovexattr: func(b []byte) error { in := kernel.FuseRemovexattrIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpFlush:       func(b []byte) error { in := kernel.FuseFlushIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpGetlk:       func(b []byte) error { in := kernel.FuseLkIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpAccess:      func(b []byte) error { in := kernel.FuseAccessIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpCreate:      func(b []byte) error { in := kernel.FuseCreateIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpInterrupt:   func(b []byte) error { in := kernel.FuseInterruptIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpBmap:        func(b []byte) error { in := kernel.FuseBmapIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpIoctl:       func(b []byte) error { in := kernel.FuseIoctlIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpPoll:        func(b []byte) error { in := kernel.FusePollIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpBatckForget: func(b []byte) error { in := kernel.FuseBatchForgetIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpFallocate:   func(b []byte) error { in := kernel.FuseFallocateIn{}; return in.ParseBinary(b) },
+	kernel.FuseOpLseek:       func(b []byte) error { in := kernel.FuseLseekIn{}; return in.ParseBinary(b) },
+}
+
+//FuzzParseIn : the argument parser of every opcode should never panic
+func FuzzParseIn(f *testing.F) {
+
+	for opcode := range parsers {
+		f.Add(opcode, []byte{})
+		f.Add(opcode, make([]byte, 7))
+		f.Add(opcode, append(make([]byte, 88), "name\x00target\x00"...))
+	}
+
+	// batch forget with count larger than the entries
+	batch := make([]byte, 24)
+	binary.LittleEndian.PutUint32(batch, 1000)
+	f.Add(uint32(kernel.FuseOpBatckForget), batch)
+
+	f.Fuzz(func(t *testing.T, opcode uint32, bcontent []byte) {
+		parse, ok := parsers[opcode]
+		if !ok {
+			return
+		}
+		parse(bcontent)
+	})
+}
+
+//TestParseInShort : the truncated arguments are rejected with ErrDataLen
+func TestParseInShort(t *testing.T) {
+
+	for opcode, parse := range parsers {
+		if err := parse(nil); err != kernel.ErrDataLen {
+			t.Errorf("opcode[%d] parse empty argument should be ErrDataLen, not %+v \n", opcode, err)
+		}
+	}
+
+	batch := make([]byte, 24)
+	binary.LittleEndian.PutUint32(batch, 2)
+	in := kernel.FuseBatchForgetIn{}
+	if err := in.ParseBinary(batch); err != kernel.ErrDataLen {
+		t.Errorf("batch forget with 2 of 1 entries should be ErrDataLen, not %+v \n", err)
+	}
+
+	binary.LittleEndian.PutUint32(batch, 1)
+	binary.LittleEndian.PutUint64(batch[8:], 7)
+	binary.LittleEndian.PutUint64(batch[16:], 3)
+	if err := in.ParseBinary(batch); err != nil || len(in.NodeList) != 1 || in.NodeList[0].Nodeid != 7 || in.NodeList[0].Nlookup != 3 {
+		t.Errorf("batch forget not correct: %+v, err: %+v \n", in, err)
+	}
+}
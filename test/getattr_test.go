@@ -1,12 +1,62 @@
 package test
 
 import (
+	"sync/atomic"
 	"syscall"
 	"testing"
 
 	"github.com/mingforpc/fuse-go/fuse"
+	"golang.org/x/sys/unix"
 )
 
+//TestNegativeLookup : test lookup of not exist file is cached by kernel
+func TestNegativeLookup(t *testing.T) {
+
+	tempPoint, err := createTempPoint()
+
+	if err != nil {
+		t.Fatalf("TestNegativeLookup err: %+v \n", err)
+	}
+
+	var count int32
+	countLookup := func(req fuse.Req, parentId uint64, name string) (fsStat *fuse.FileStat, result int32) {
+		if name == "notexist" {
+			atomic.AddInt32(&count, 1)
+		}
+		return lookup(req, parentId, name)
+	}
+
+	opts := fuse.Opt{}
+	opts.Getattr = &getattr
+	opts.Lookup = &countLookup
+
+	se := NewTestFuse(tempPoint, opts)
+	se.FuseConfig.NegativeTimeout = 60
+
+	err = preTest(se)
+
+	if err != nil {
+		panic(err)
+	}
+
+	go se.FuseLoop()
+	defer exitTest(se)
+
+	wait.Wait()
+
+	var stat syscall.Stat_t
+	for i := 0; i < 3; i++ {
+		err = syscall.Stat(tempPoint+"/notexist", &stat)
+		if err != syscall.ENOENT {
+			t.Errorf("TestNegativeLookup err should be ENOENT, not %+v \n", err)
+		}
+	}
+
+	if atomic.LoadInt32(&count) != 1 {
+		t.Errorf("TestNegativeLookup lookup should be called %d times, not %d \n", 1, count)
+	}
+}
+
 //TestLookup : test getattr() -> lookup file in fuse dir
 func TestLookup(t *testing.T) {
 
@@ -162,3 +212,55 @@ func TestGetattr(t *testing.T) {
 	}
 
 }
+
+//TestStatx : test statx() -> the birth time and file attributes
+func TestStatx(t *testing.T) {
+
+	tempPoint, err := createTempPoint()
+
+	if err != nil {
+		t.Fatalf("TestStatx err: %+v \n", err)
+	}
+
+	opts := fuse.Opt{}
+	opts.Getattr = &getattr
+	opts.Statx = &statx
+	opts.Lookup = &lookup
+
+	se := NewTestFuse(tempPoint, opts)
+
+	err = preTest(se)
+
+	if err != nil {
+		panic(err)
+	}
+
+	go se.FuseLoop()
+	defer exitTest(se)
+
+	wait.Wait()
+
+	var stx unix.Statx_t
+	err = unix.Statx(unix.AT_FDCWD, tempPoint+"/"+rootFile.name, 0, unix.STATX_BASIC_STATS|unix.STATX_BTIME, &stx)
+	if err != nil {
+		t.Fatalf("TestStatx err: %+v \n", err)
+	}
+
+	if stx.Ino != rootFile.stat.Stat.Ino {
+		t.Errorf("TestStatx inode should be %d, not %d \n", rootFile.stat.Stat.Ino, stx.Ino)
+	}
+
+	if (stx.Mask & unix.STATX_BTIME) == 0 {
+		t.Skip("TestStatx kernel does not send FUSE_STATX")
+	}
+
+	if stx.Btime.Sec != birthTime.Sec || stx.Btime.Nsec != uint32(birthTime.Nsec) {
+		t.Errorf("TestStatx btime should be %+v, not %+v \n", birthTime, stx.Btime)
+	}
+	if (stx.Attributes_mask&unix.STATX_ATTR_IMMUTABLE) == 0 || (stx.Attributes&unix.STATX_ATTR_IMMUTABLE) == 0 {
+		t.Errorf("TestStatx should be immutable, attributes[%x] mask[%x] \n", stx.Attributes, stx.Attributes_mask)
+	}
+	if (stx.Attributes & unix.STATX_ATTR_APPEND) != 0 {
+		t.Errorf("TestStatx should not be append only, attributes[%x] \n", stx.Attributes)
+	}
+}
@@ -46,7 +46,7 @@ var newFile testFileStat
 // the map to save xattr
 // key: inode id
 // value: map[{xattr name}]{xattr value}
-var xattrMap map[uint64]map[string]string
+var xattrMap map[uint64]map[string][]byte
 
 // the symlink File
 var symlinkFile testFileStat
@@ -120,7 +120,7 @@ func init() {
 	newDir.stat = stat
 
 	// init xattrmap
-	xattrMap = make(map[uint64]map[string]string)
+	xattrMap = make(map[uint64]map[string][]byte)
 
 	// rootStatfs
 	rootStatfs.Bsize = 1024
@@ -187,6 +187,27 @@ var getattr = func(req fuse.Req, nodeid uint64) (fsStat *fuse.FileStat, result i
 	return fsStat, result
 }
 
+// birthTime : the creation time returned by statx
+var birthTime = syscall.Timespec{Sec: 1546272000, Nsec: 1}
+
+var statx = func(req fuse.Req, nodeid uint64, flags uint32, mask uint32, fi fuse.FileInfo) (attr *fuse.Attr, result int32) {
+
+	fmt.Printf("Statx: nodeid:%d, flags:%d, mask:%x \n", nodeid, flags, mask)
+
+	fsStat := getStat(nodeid)
+	if fsStat == nil {
+		return nil, errno.ENOENT
+	}
+
+	stx := fuse.AttrFromStat(*fsStat)
+	stx.Mask |= fuse.StatxBtime
+	stx.Btime = birthTime
+	stx.AttributesMask = fuse.StatxAttrImmutable | fuse.StatxAttrAppend
+	stx.Attributes = fuse.StatxAttrImmutable
+
+	return &stx, errno.SUCCESS
+}
+
 var lookup = func(req fuse.Req, parentId uint64, name string) (fsStat *fuse.FileStat, result int32) {
 
 	fmt.Printf("Looup: parentid:%d, name:%s \n", parentId, name)
@@ -254,6 +275,62 @@ var readdir = func(req fuse.Req, nodeid uint64, size uint32, offset uint64, fi f
 	return fileList, result
 }
 
+var readdirplus = func(req fuse.Req, nodeid uint64, size uint32, offset uint64, fi fuse.FileInfo) (direntList []fuse.DirentPlus, result int32) {
+
+	fmt.Printf("Readdirplus: nodeid:%d, size:%d offset:%d, fi:[%+v] \n", nodeid, size, offset, fi)
+
+	var fileList []fuse.Dirent
+	fileList, result = readdir(req, nodeid, size, offset, fi)
+	if result != errno.SUCCESS {
+		return nil, result
+	}
+
+	direntList = make([]fuse.DirentPlus, len(fileList))
+	for i, dirent := range fileList {
+		direntList[i].Dirent = dirent
+		if dirent.Name == "." || dirent.Name == ".." {
+			continue
+		}
+		fsStat, res := lookup(req, nodeid, dirent.Name)
+		if res == errno.SUCCESS {
+			direntList[i].FileStat = *fsStat
+		}
+	}
+
+	return direntList, errno.SUCCESS
+}
+
+// bigDirCount : count of the entries in the root folder listed by readdirStream
+const bigDirCount = 1000
+
+type bigDirStream struct {
+	idx uint64
+}
+
+func (stream *bigDirStream) HasNext() bool {
+	return stream.idx < bigDirCount
+}
+
+func (stream *bigDirStream) Next() (fuse.Dirent, int32) {
+	name := fmt.Sprintf("entry-%04d", stream.idx)
+	stream.idx++
+	return fuse.Dirent{NameLen: uint32(len(name)), Ino: 1000 + stream.idx, Off: stream.idx, Name: name}, errno.SUCCESS
+}
+
+func (stream *bigDirStream) Close() {
+}
+
+var readdirStream = func(req fuse.Req, nodeid uint64, offset uint64, fi fuse.FileInfo) (stream fuse.DirStream, result int32) {
+
+	fmt.Printf("ReaddirStream: nodeid:%d, offset:%d, fi:[%+v] \n", nodeid, offset, fi)
+
+	if nodeid != root.stat.Nodeid {
+		return nil, errno.ENOTDIR
+	}
+
+	return &bigDirStream{idx: offset}, errno.SUCCESS
+}
+
 var open = func(req fuse.Req, nodeid uint64, fi *fuse.FileInfo) (result int32) {
 
 	fmt.Printf("Open: nodeid:%d,  fi:[%+v] \n", nodeid, fi)
@@ -326,13 +403,13 @@ var flush = func(req fuse.Req, nodeid uint64, fi fuse.FileInfo) (result int32) {
 	return errno.SUCCESS
 }
 var release = func(req fuse.Req, nodeid uint64, fi fuse.FileInfo) (result int32) {
-	fmt.Printf("Release: nodeid:[%d],  fi:[%+b] \n", nodeid, fi)
+	fmt.Printf("Release: nodeid:[%d],  fi:[%+v] \n", nodeid, fi)
 	return result
 }
 
 var fsyncdir = func(req fuse.Req, nodeid uint64, datasync uint32, fi fuse.FileInfo) (result int32) {
 
-	fmt.Printf("Open: nodeid:[%d], datasync:[%d], fi:[%+b] \n", nodeid, datasync, fi)
+	fmt.Printf("Open: nodeid:[%d], datasync:[%d], fi:[%+v] \n", nodeid, datasync, fi)
 
 	return result
 }
@@ -359,14 +436,14 @@ var rmdir = func(req fuse.Req, parentid uint64, name string) (res int32) {
 	return errno.SUCCESS
 }
 
-var setxattr = func(req fuse.Req, nodeid uint64, name string, value string, flags uint32) (result int32) {
+var setxattr = func(req fuse.Req, nodeid uint64, name string, value []byte, flags uint32) (result int32) {
 
-	fmt.Printf("Setxattr: nodeid:[%d], name:[%s], value:[%s] \n", nodeid, name, value)
+	fmt.Printf("Setxattr: nodeid:[%d], name:[%s], value:[%x] \n", nodeid, name, value)
 
 	kvMap := xattrMap[nodeid]
 
 	if kvMap == nil {
-		xattrMap[nodeid] = make(map[string]string)
+		xattrMap[nodeid] = make(map[string][]byte)
 		kvMap = xattrMap[nodeid]
 	}
 
@@ -377,19 +454,19 @@ var setxattr = func(req fuse.Req, nodeid uint64, name string, value string, flag
 	return result
 }
 
-var getxattr = func(req fuse.Req, nodeid uint64, name string, size uint32) (value string, result int32) {
+var getxattr = func(req fuse.Req, nodeid uint64, name string, size uint32) (value []byte, result int32) {
 
 	fmt.Printf("Getxattr: nodeid:[%d], name:[%s] \n", nodeid, name)
 
 	kvMap := xattrMap[nodeid]
 
 	if kvMap == nil {
-		return "", errno.ENOATTR
+		return nil, errno.ENOATTR
 	}
 
-	value = kvMap[name]
+	value, ok := kvMap[name]
 
-	if value == "" {
+	if !ok {
 		result = errno.ENOATTR
 	} else {
 		result = errno.SUCCESS
@@ -398,23 +475,17 @@ var getxattr = func(req fuse.Req, nodeid uint64, name string, size uint32) (valu
 	return value, result
 }
 
-var listxattr = func(req fuse.Req, nodeid uint64, size uint32) (listVal string, result int32) {
+var listxattr = func(req fuse.Req, nodeid uint64, size uint32) (names []string, result int32) {
 	fmt.Printf("Listxattr: nodeid:[%d] \n", nodeid)
 	kvMap := xattrMap[nodeid]
 
-	if kvMap == nil {
-		return "", errno.ENOATTR
-	}
-
 	for k := range kvMap {
-		listVal += k + string(byte(0))
+		names = append(names, k)
 	}
-	// remove lastest '\0'
-	listVal = listVal[:len(listVal)-1]
 
 	result = errno.SUCCESS
 
-	return listVal, result
+	return names, result
 }
 
 var removexattr = func(req fuse.Req, nodeid uint64, name string) (result int32) {
@@ -426,7 +497,7 @@ var removexattr = func(req fuse.Req, nodeid uint64, name string) (result int32)
 		return errno.ENOATTR
 	}
 
-	if kvMap[name] == "" {
+	if _, ok := kvMap[name]; !ok {
 		return errno.ENOATTR
 	}
 
@@ -604,15 +675,34 @@ var setlk = func(req fuse.Req, nodeid uint64, fi fuse.FileInfo, lock fuse.Flock,
 
 }
 
+// flockOps : the operations received by flock
+var flockOps = make(chan int, 16)
+
+var flock = func(req fuse.Req, nodeid uint64, fi fuse.FileInfo, op int) (result int32) {
+
+	fmt.Printf("Flock: nodeid:%d, fi:%+v, op:%d \n", nodeid, fi, op)
+
+	if nodeid != rootFile.stat.Nodeid {
+		return errno.EACCES
+	}
+
+	select {
+	case flockOps <- op:
+	default:
+	}
+
+	return errno.SUCCESS
+}
+
 var bmap = func(req fuse.Req, nodeid uint64, blocksize uint32, idx *uint64) (result int32) {
 
 	fmt.Printf("Bmap: nodeid:%d, blocksize:%d, idx:%d \n", nodeid, blocksize, idx)
 	return errno.SUCCESS
 }
 
-var ioctl = func(req fuse.Req, nodeid uint64, cmd uint32, arg uint64, fi fuse.FileInfo, inbuf []byte, outbufsz uint32) (ioctl *fuse.Ioctl, result int32) {
+var ioctl = func(req fuse.Req, nodeid uint64, cmd uint32, arg uint64, fi fuse.FileInfo, flags uint32, inbuf []byte, outbufsz uint32) (ioctl *fuse.Ioctl, result int32) {
 
-	fmt.Printf("Ioctl: nodeid:%d, cmd:%d, arg:%d, fi:%+v \n", nodeid, cmd, arg, fi)
+	fmt.Printf("Ioctl: nodeid:%d, cmd:%d, arg:%d, fi:%+v, flags:%d \n", nodeid, cmd, arg, fi, flags)
 	if nodeid != rootFile.stat.Nodeid {
 		return nil, errno.EBADF
 	}
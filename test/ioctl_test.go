@@ -0,0 +1,161 @@
+package test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+	"github.com/mingforpc/fuse-go/fuse/kernel"
+)
+
+// the commands of testIoctl
+const (
+	ioctlEcho = iota + 1
+	ioctlNil
+	ioctlTooLarge
+	ioctlRetry
+	ioctlManyIovs
+	ioctlHighIovs
+)
+
+// testIoctl : echo inbuf as the output data, or ask for the retry
+var testIoctl = func(req fuse.Req, nodeid uint64, cmd uint32, arg uint64, fi fuse.FileInfo, flags uint32, inbuf []byte, outbufsz uint32) (*fuse.Ioctl, int32) {
+
+	switch cmd {
+	case ioctlEcho:
+		return &fuse.Ioctl{Result: int32(len(inbuf)), OutBuf: inbuf}, errno.SUCCESS
+
+	case ioctlNil:
+		return nil, errno.SUCCESS
+
+	case ioctlTooLarge:
+		return &fuse.Ioctl{OutBuf: make([]byte, outbufsz+1)}, errno.SUCCESS
+
+	case ioctlRetry:
+		if len(inbuf) > 0 {
+			// the second call with the data fetched from InIovs
+			return &fuse.Ioctl{Result: 7}, errno.SUCCESS
+		}
+		return &fuse.Ioctl{
+			Retry:   true,
+			InIovs:  []fuse.IoctlIovec{{Base: uint64(arg), Len: 8}},
+			OutIovs: []fuse.IoctlIovec{{Base: uint64(arg) + 8, Len: 16}, {Base: 0x7000, Len: 4}},
+		}, errno.SUCCESS
+
+	case ioctlManyIovs:
+		return &fuse.Ioctl{Retry: true, InIovs: make([]fuse.IoctlIovec, kernel.FuseIoctlMaxIov+1)}, errno.SUCCESS
+
+	case ioctlHighIovs:
+		return &fuse.Ioctl{Retry: true, OutIovs: []fuse.IoctlIovec{{Base: 1 << 32, Len: 4}}}, errno.SUCCESS
+	}
+
+	return nil, errno.ENOTTY
+}
+
+//TestIoctlOutData : the result and the output data of restricted ioctl
+func TestIoctlOutData(t *testing.T) {
+
+	opts := fuse.Opt{}
+	opts.Ioctl = &testIoctl
+
+	k := newTestKernel(t, opts)
+	defer k.Close()
+
+	in := kernel.FuseIoctlIn{Cmd: ioctlEcho, OutSize: 16, InBuf: []byte("hello")}
+	out, res, err := k.Ioctl(rootFile.stat.Nodeid, in)
+	if err != nil || res != errno.SUCCESS || out.Result != 5 || out.Flags != 0 || !bytes.Equal(out.Buf, []byte("hello")) {
+		t.Errorf("ioctl echo res: %d, out: %+v, err: %+v \n", res, out, err)
+	}
+
+	out, res, err = k.Ioctl(rootFile.stat.Nodeid, kernel.FuseIoctlIn{Cmd: ioctlNil, OutSize: 16})
+	if err != nil || res != errno.SUCCESS || out.Result != 0 || len(out.Buf) != 0 {
+		t.Errorf("ioctl nil res: %d, out: %+v, err: %+v \n", res, out, err)
+	}
+
+	// output data larger than outbufsz
+	_, res, err = k.Ioctl(rootFile.stat.Nodeid, kernel.FuseIoctlIn{Cmd: ioctlTooLarge, OutSize: 16})
+	if err != nil || res != errno.EIO {
+		t.Errorf("ioctl output too large should be EIO, not %d, err: %+v \n", res, err)
+	}
+
+	_, res, err = k.Ioctl(rootFile.stat.Nodeid, kernel.FuseIoctlIn{Cmd: 0xdead})
+	if err != nil || res != errno.ENOTTY {
+		t.Errorf("unknown ioctl should be ENOTTY, not %d, err: %+v \n", res, err)
+	}
+}
+
+//TestIoctlRetry : the retry with iovecs of unrestricted ioctl
+func TestIoctlRetry(t *testing.T) {
+
+	opts := fuse.Opt{}
+	opts.Ioctl = &testIoctl
+
+	k := newTestKernel(t, opts)
+	defer k.Close()
+
+	in := kernel.FuseIoctlIn{Cmd: ioctlRetry, Arg: 0x1000, Flags: kernel.FuseIoctlUnrestricted}
+	out, res, err := k.Ioctl(rootFile.stat.Nodeid, in)
+	if err != nil || res != errno.SUCCESS {
+		t.Fatalf("ioctl retry res: %d, err: %+v \n", res, err)
+	}
+
+	wantIn := []kernel.FuseIoctlIovec{{Base: 0x1000, Len: 8}}
+	wantOut := []kernel.FuseIoctlIovec{{Base: 0x1008, Len: 16}, {Base: 0x7000, Len: 4}}
+	if out.Flags != kernel.FuseIoctlRetry || out.InIovs != 1 || out.OutIovs != 2 {
+		t.Fatalf("ioctl retry out: %+v \n", out)
+	}
+	if out.InIovecs[0] != wantIn[0] || out.OutIovecs[0] != wantOut[0] || out.OutIovecs[1] != wantOut[1] {
+		t.Errorf("ioctl retry iovecs: %+v %+v, should be %+v %+v \n", out.InIovecs, out.OutIovecs, wantIn, wantOut)
+	}
+
+	// the kernel calls again with the data of InIovs
+	in.InBuf = make([]byte, 8)
+	in.OutSize = 20
+	out, res, err = k.Ioctl(rootFile.stat.Nodeid, in)
+	if err != nil || res != errno.SUCCESS || out.Flags != 0 || out.Result != 7 {
+		t.Errorf("ioctl after retry res: %d, out: %+v, err: %+v \n", res, out, err)
+	}
+
+	// retry is only for unrestricted ioctl
+	in = kernel.FuseIoctlIn{Cmd: ioctlRetry, Arg: 0x1000}
+	_, res, err = k.Ioctl(rootFile.stat.Nodeid, in)
+	if err != nil || res != errno.EIO {
+		t.Errorf("restricted ioctl retry should be EIO, not %d, err: %+v \n", res, err)
+	}
+
+	in = kernel.FuseIoctlIn{Cmd: ioctlManyIovs, Flags: kernel.FuseIoctlUnrestricted}
+	_, res, err = k.Ioctl(rootFile.stat.Nodeid, in)
+	if err != nil || res != errno.EIO {
+		t.Errorf("ioctl retry with too many iovecs should be EIO, not %d, err: %+v \n", res, err)
+	}
+}
+
+//TestIoctlCompat : the iovecs of a 32bit caller
+func TestIoctlCompat(t *testing.T) {
+
+	opts := fuse.Opt{}
+	opts.Ioctl = &testIoctl
+
+	k := newTestKernel(t, opts)
+	defer k.Close()
+
+	compat := uint32(kernel.FuseIoctlUnrestricted | kernel.FuseIoctlCompat | kernel.FuseIoctl32bit)
+
+	// the iovecs in 32bit are sent as 64bit fuse_ioctl_iovec
+	out, res, err := k.Ioctl(rootFile.stat.Nodeid, kernel.FuseIoctlIn{Cmd: ioctlRetry, Arg: 0x1000, Flags: compat})
+	if err != nil || res != errno.SUCCESS || out.Flags != kernel.FuseIoctlRetry || out.OutIovecs[1].Base != 0x7000 {
+		t.Errorf("compat ioctl retry res: %d, out: %+v, err: %+v \n", res, out, err)
+	}
+
+	_, res, err = k.Ioctl(rootFile.stat.Nodeid, kernel.FuseIoctlIn{Cmd: ioctlHighIovs, Flags: compat})
+	if err != nil || res != errno.EIO {
+		t.Errorf("compat ioctl iovec beyond 32bit should be EIO, not %d, err: %+v \n", res, err)
+	}
+
+	// the same iovec is fine for a 64bit caller
+	_, res, err = k.Ioctl(rootFile.stat.Nodeid, kernel.FuseIoctlIn{Cmd: ioctlHighIovs, Flags: kernel.FuseIoctlUnrestricted})
+	if err != nil || res != errno.SUCCESS {
+		t.Errorf("ioctl iovec beyond 32bit res: %d, err: %+v \n", res, err)
+	}
+}
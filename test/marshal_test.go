@@ -0,0 +1,255 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/common"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+	"github.com/mingforpc/fuse-go/fuse/fusetest"
+	"github.com/mingforpc/fuse-go/fuse/kernel"
+)
+
+// fill : set the numbers in v to random values
+func fill(v reflect.Value, r *rand.Rand) {
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			fill(v.Field(i), r)
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			fill(v.Index(i), r)
+		}
+	case reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(r.Uint64())
+	case reflect.Int32, reflect.Int64:
+		v.SetInt(r.Int63())
+	}
+}
+
+//TestMarshalLayout : the responses encoded by MarshalTo are the same as encoding/binary
+func TestMarshalLayout(t *testing.T) {
+
+	responses := []kernel.FuseFixedResponsor{
+		&kernel.FuseOutHeader{}, &kernel.FuseInitOut{}, &kernel.FuseAttrOut{},
+		&kernel.FuseStatxOut{}, &kernel.FuseEntryOut{}, &kernel.FuseCreateOut{},
+		&kernel.FuseOpenOut{}, &kernel.FuseWriteOut{}, &kernel.FuseStatfsOut{},
+		&kernel.FuseGetxattrOut{}, &kernel.FuseLkOut{}, &kernel.FusePollOut{},
+		&kernel.FuseLseekOut{}, &kernel.FuseBmapOut{},
+	}
+
+	r := rand.New(rand.NewSource(1))
+
+	for _, resp := range responses {
+		fill(reflect.ValueOf(resp).Elem(), r)
+
+		want, _ := common.ToBinary(resp)
+
+		got := bytes.Repeat([]byte{0xff}, resp.BinarySize())
+		resp.MarshalTo(got)
+
+		if len(want) != resp.BinarySize() || !bytes.Equal(got, want) {
+			t.Errorf("%T: MarshalTo %x, should be %x \n", resp, got, want)
+		}
+	}
+
+	dirent := kernel.FuseDirentplus{}
+	fill(reflect.ValueOf(&dirent.EntryOut).Elem(), r)
+	dirent.Dirent = kernel.FuseDirent{Ino: 2, Off: 3, NameLen: 5, DirType: 8, Name: "hello"}
+
+	got := bytes.Repeat([]byte{0xff}, int(kernel.DirentplusSize(5)))
+	dirent.MarshalTo(got)
+
+	want, _ := common.ToBinary(dirent.EntryOut)
+	want = append(want, 2, 0, 0, 0, 0, 0, 0, 0, 3, 0, 0, 0, 0, 0, 0, 0, 5, 0, 0, 0, 8, 0, 0, 0)
+	want = append(want, "hello\x00\x00\x00"...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("FuseDirentplus: MarshalTo %x, should be %x \n", got, want)
+	}
+}
+
+//TestParseInLayout : the requests parsed by ParseBinary are the same as encoding/binary
+func TestParseInLayout(t *testing.T) {
+
+	requests := []interface {
+		ParseBinary(bcontent []byte) error
+	}{
+		&kernel.FuseInHeader{}, &kernel.FuseGetattrIn{}, &kernel.FuseStatxIn{},
+		&kernel.FuseForgetIn{}, &kernel.FuseSetattrIn{}, &kernel.FuseOpenIn{},
+		&kernel.FuseReadIn{}, &kernel.FuseReleaseIn{}, &kernel.FuseFsyncIn{},
+		&kernel.FuseFlushIn{}, &kernel.FuseLkIn{}, &kernel.FuseAccessIn{},
+		&kernel.FuseInterruptIn{}, &kernel.FuseBmapIn{}, &kernel.FusePollIn{},
+		&kernel.FuseFallocateIn{}, &kernel.FuseLseekIn{}, &kernel.CuseInitIn{},
+	}
+
+	r := rand.New(rand.NewSource(1))
+
+	for _, req := range requests {
+		want := reflect.New(reflect.TypeOf(req).Elem())
+		fill(want.Elem(), r)
+
+		b, _ := common.ToBinary(want.Interface())
+
+		if err := req.ParseBinary(b); err != nil || !reflect.DeepEqual(req, want.Interface()) {
+			t.Errorf("%T: ParseBinary %+v, should be %+v, err: %+v \n", req, req, want.Interface(), err)
+		}
+	}
+}
+
+//BenchmarkParseInHeader : parse the header of every request
+func BenchmarkParseInHeader(b *testing.B) {
+
+	frame, _ := fusetest.Encode(kernel.FuseInHeader{Len: 56, Opcode: kernel.FuseOpGetattr, Unique: 2, Nodeid: 1})
+	header := kernel.FuseInHeader{}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := header.ParseBinary(frame); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+//BenchmarkParseInHeaderReflect : parse the header by reflection, as before
+func BenchmarkParseInHeaderReflect(b *testing.B) {
+
+	frame, _ := fusetest.Encode(kernel.FuseInHeader{Len: 56, Opcode: kernel.FuseOpGetattr, Unique: 2, Nodeid: 1})
+	header := kernel.FuseInHeader{}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := common.ParseBinary(frame, &header); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+//BenchmarkParseReadIn : parse the argument of read
+func BenchmarkParseReadIn(b *testing.B) {
+
+	arg, _ := fusetest.Encode(kernel.FuseReadIn{Fh: 1, Offset: 4096, Size: 4096})
+	in := kernel.FuseReadIn{}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := in.ParseBinary(arg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+//BenchmarkParseReadInReflect : parse the argument of read by reflection, as before
+func BenchmarkParseReadInReflect(b *testing.B) {
+
+	arg, _ := fusetest.Encode(kernel.FuseReadIn{Fh: 1, Offset: 4096, Size: 4096})
+	in := kernel.FuseReadIn{}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := common.ParseBinary(arg, &in); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+//BenchmarkEntryOut : encode the reply of lookup
+func BenchmarkEntryOut(b *testing.B) {
+
+	out := kernel.FuseEntryOut{NodeID: 2, AttrValid: 1, Attr: kernel.FuseAttr{Ino: 2, Size: 13, Mode: 0100644, Nlink: 1}}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := out.ToBinary(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+//BenchmarkEntryOutReflect : encode the reply of lookup by reflection, as before
+func BenchmarkEntryOutReflect(b *testing.B) {
+
+	out := kernel.FuseEntryOut{NodeID: 2, AttrValid: 1, Attr: kernel.FuseAttr{Ino: 2, Size: 13, Mode: 0100644, Nlink: 1}}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := common.ToBinary(out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+//BenchmarkDirentplus : encode an entry of readdirplus
+func BenchmarkDirentplus(b *testing.B) {
+
+	dirent := kernel.FuseDirentplus{}
+	dirent.EntryOut.NodeID = 2
+	dirent.Dirent = kernel.FuseDirent{Ino: 2, Off: 1, NameLen: 4, DirType: 8, Name: "test"}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := dirent.ToBinary(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+//BenchmarkDirentplusReflect : encode an entry of readdirplus by reflection, as before
+func BenchmarkDirentplusReflect(b *testing.B) {
+
+	dirent := kernel.FuseDirentplus{}
+	dirent.EntryOut.NodeID = 2
+	dirent.Dirent = kernel.FuseDirent{Ino: 2, Off: 1, NameLen: 4, DirType: 8, Name: "test"}
+
+	// the entry_out, the fields of fuse_dirent, then the name padded to 8 bytes
+	head := struct {
+		Ino     uint64
+		Off     uint64
+		NameLen uint32
+		DirType uint32
+	}{dirent.Dirent.Ino, dirent.Dirent.Off, dirent.Dirent.NameLen, dirent.Dirent.DirType}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf := bytes.NewBuffer(nil)
+		entry, err := common.ToBinary(dirent.EntryOut)
+		if err != nil {
+			b.Fatal(err)
+		}
+		buf.Write(entry)
+		binary.Write(buf, binary.LittleEndian, head)
+		buf.WriteString(dirent.Dirent.Name)
+		buf.Write(make([]byte, (8-buf.Len()%8)%8))
+	}
+}
+
+//BenchmarkKernelGetattr : a getattr request through the whole session
+func BenchmarkKernelGetattr(b *testing.B) {
+
+	// without the trace of getattr
+	quietGetattr := func(req fuse.Req, nodeid uint64) (*fuse.FileStat, int32) {
+		return getStat(nodeid), errno.SUCCESS
+	}
+
+	opts := fuse.Opt{}
+	opts.Getattr = &quietGetattr
+
+	k := fusetest.NewKernel(fuse.NewFuseSession("", &opts, 16))
+	defer k.Close()
+
+	k.Init(kernel.FuseInitIn{})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, res, err := k.Getattr(rootFile.stat.Nodeid)
+		if err != nil || res != errno.SUCCESS {
+			b.Fatalf("res: %d, err: %+v", res, err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package test
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+	"github.com/mingforpc/fuse-go/fuse/perm"
+)
+
+//TestReqGroups : test the supplementary groups read from the status of the caller
+func TestReqGroups(t *testing.T) {
+
+	req := fuse.Req{Pid: uint32(os.Getpid())}
+
+	groups, err := req.Groups()
+	if err != nil {
+		t.Fatalf("TestReqGroups err: %+v \n", err)
+	}
+
+	expect, err := os.Getgroups()
+	if err != nil {
+		t.Fatalf("TestReqGroups err: %+v \n", err)
+	}
+
+	if len(groups) != len(expect) {
+		t.Fatalf("TestReqGroups groups should be %+v, not %+v \n", expect, groups)
+	}
+	for i := range expect {
+		if groups[i] != uint32(expect[i]) {
+			t.Errorf("TestReqGroups groups should be %+v, not %+v \n", expect, groups)
+		}
+	}
+}
+
+//TestPermCheck : test the POSIX permission check of perm.Check
+func TestPermCheck(t *testing.T) {
+
+	fsStat := &fuse.FileStat{}
+	fsStat.Stat.Mode = syscall.S_IFREG | 0640
+	fsStat.Stat.Uid = 1000
+	fsStat.Stat.Gid = 100
+
+	owner := fuse.Req{UID: 1000, Gid: 1000}
+	group := fuse.Req{UID: 1001, Gid: 100}
+	other := fuse.Req{UID: 1002, Gid: 1002}
+	root := fuse.Req{UID: 0, Gid: 0}
+
+	cases := []struct {
+		req    fuse.Req
+		mask   uint32
+		result int32
+	}{
+		{owner, perm.ROK | perm.WOK, errno.SUCCESS},
+		{owner, perm.XOK, errno.EACCES},
+		{group, perm.ROK, errno.SUCCESS},
+		{group, perm.WOK, errno.EACCES},
+		{other, perm.ROK, errno.EACCES},
+		{other, perm.FOK, errno.SUCCESS},
+		{root, perm.ROK | perm.WOK, errno.SUCCESS},
+		{root, perm.XOK, errno.EACCES},
+	}
+
+	for i, c := range cases {
+		result := perm.Check(fsStat, c.req, c.mask)
+		if result != c.result {
+			t.Errorf("TestPermCheck case[%d] should be %d, not %d \n", i, c.result, result)
+		}
+	}
+
+	// search permission of directory for root
+	fsStat.Stat.Mode = syscall.S_IFDIR | 0600
+	if result := perm.Check(fsStat, root, perm.XOK); result != errno.SUCCESS {
+		t.Errorf("TestPermCheck root should search directory, not %d \n", result)
+	}
+
+	if result := perm.Check(nil, owner, perm.ROK); result != errno.ENOENT {
+		t.Errorf("TestPermCheck nil stat should be ENOENT, not %d \n", result)
+	}
+}
+
+//TestCreateUmask : test the umask of the caller in create
+func TestCreateUmask(t *testing.T) {
+
+	tempPoint, err := createTempPoint()
+
+	if err != nil {
+		t.Fatalf("TestCreateUmask err: %+v \n", err)
+	}
+
+	umasks := make(chan uint32, 1)
+	umaskCreate := func(req fuse.Req, parentid uint64, name string, mode uint32, fi *fuse.FileInfo) (fsStat *fuse.FileStat, result int32) {
+		umasks <- req.Umask
+		return create(req, parentid, name, mode, fi)
+	}
+
+	opts := fuse.Opt{}
+	opts.Getattr = &getattr
+	opts.Lookup = &lookup
+	opts.Release = &release
+	opts.Create = &umaskCreate
+
+	se := NewTestFuse(tempPoint, opts)
+
+	err = preTest(se)
+
+	if err != nil {
+		t.Fatalf("TestCreateUmask err: %+v \n", err)
+	}
+
+	go se.FuseLoop()
+	defer exitTest(se)
+
+	wait.Wait()
+
+	old := syscall.Umask(027)
+	defer syscall.Umask(old)
+
+	f, err := os.OpenFile(tempPoint+"/"+newFile.name, os.O_CREATE, 0666)
+	if err != nil {
+		t.Fatalf("TestCreateUmask err: %+v \n", err)
+	}
+	f.Close()
+
+	if umask := <-umasks; umask != 027 {
+		t.Errorf("TestCreateUmask umask should be %o, not %o \n", 027, umask)
+	}
+}
@@ -0,0 +1,201 @@
+package test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+	"github.com/mingforpc/fuse-go/fuse/fusetest"
+	"github.com/mingforpc/fuse-go/fuse/kernel"
+	"github.com/mingforpc/fuse-go/fuse/record"
+)
+
+//TestRecordFormat : the records are read back as written
+func TestRecordFormat(t *testing.T) {
+
+	buf := bytes.NewBuffer(nil)
+
+	w, err := record.NewWriter(buf)
+	if err != nil {
+		t.Fatalf("new writer err: %+v \n", err)
+	}
+
+	frame, _ := fusetest.Encode(kernel.FuseInHeader{Len: 40, Opcode: kernel.FuseOpGetattr, Unique: 42})
+	start := time.Unix(1600000000, 500)
+
+	recs := []record.Record{
+		{Kind: record.KindRequest, Time: start, Frame: frame},
+		{Kind: record.KindReply, Time: start.Add(time.Millisecond), Frame: frame[:16]},
+		{Kind: record.KindReply, Time: start.Add(-time.Second), Frame: nil},
+	}
+	for _, rec := range recs {
+		if err := w.WriteRecord(rec); err != nil {
+			t.Fatalf("write record err: %+v \n", err)
+		}
+	}
+
+	data := buf.Bytes()
+
+	reader, err := record.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("new reader err: %+v \n", err)
+	}
+	for i, want := range recs {
+		rec, err := reader.Next()
+		if err != nil || rec.Kind != want.Kind || !rec.Time.Equal(want.Time) || !bytes.Equal(rec.Frame, want.Frame) {
+			t.Errorf("record[%d]: %+v, err: %+v, should be %+v \n", i, rec, err, want)
+		}
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("end of recording should be EOF, not %+v \n", err)
+	}
+
+	if recs[0].Unique() != 42 || recs[0].Opcode() != kernel.FuseOpGetattr || recs[1].Opcode() != 0 {
+		t.Errorf("unique[%d], opcode[%d] not correct \n", recs[0].Unique(), recs[0].Opcode())
+	}
+
+	// truncated in the frame of the first record
+	reader, _ = record.NewReader(bytes.NewReader(data[:len(record.Magic)+10]))
+	if _, err := reader.Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated record should be ErrUnexpectedEOF, not %+v \n", err)
+	}
+
+	if _, err := record.NewReader(bytes.NewReader([]byte("FUSE"))); err != record.ErrMagic {
+		t.Errorf("short magic should be ErrMagic, not %+v \n", err)
+	}
+}
+
+// recordOpts : the filesystem recorded and replayed
+func recordOpts() fuse.Opt {
+
+	opts := fuse.Opt{}
+	opts.Getattr = &getattr
+	opts.Lookup = &lookup
+	opts.Open = &open
+	opts.Read = &read
+	opts.Readdir = &readdir
+
+	return opts
+}
+
+// recordSession : record a session of lookup, readdir, read and forget
+func recordSession(t *testing.T) string {
+
+	path := filepath.Join(t.TempDir(), "session.rec")
+
+	opts := recordOpts()
+	se := fuse.NewFuseSession("", &opts, 16)
+	se.FuseConfig.RecordPath = path
+
+	k := fusetest.NewKernel(se)
+
+	if _, res, err := k.Init(kernel.FuseInitIn{}); err != nil || res != errno.SUCCESS {
+		t.Fatalf("init res: %d, err: %+v \n", res, err)
+	}
+	k.Lookup(fusetest.RootID, rootFile.name)
+	k.Lookup(fusetest.RootID, "notexist")
+	k.Getattr(rootDir.stat.Nodeid)
+	k.Readdir(fusetest.RootID, 0, 0, 4096)
+	openOut, _, _ := k.Open(rootFile.stat.Nodeid, 0)
+	k.Read(rootFile.stat.Nodeid, openOut.Fh, 0, 4096)
+	k.Forget(rootFile.stat.Nodeid, 1)
+	k.Close()
+
+	return path
+}
+
+//TestRecordReplay : replay the recording of a session to the same and a changed filesystem
+func TestRecordReplay(t *testing.T) {
+
+	path := recordSession(t)
+
+	// the same filesystem
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open recording err: %+v \n", err)
+	}
+	defer file.Close()
+
+	opts := recordOpts()
+	mismatches, err := fusetest.Replay(fuse.NewFuseSession("", &opts, 16), file)
+	if err != nil || len(mismatches) != 0 {
+		t.Errorf("replay mismatches: %v, err: %+v \n", mismatches, err)
+	}
+
+	// a filesystem with a bug in lookup
+	file.Seek(0, io.SeekStart)
+
+	opts = recordOpts()
+	buggyLookup := func(req fuse.Req, parentId uint64, name string) (*fuse.FileStat, int32) {
+		if name == "notexist" {
+			return nil, errno.EACCES
+		}
+		return lookup(req, parentId, name)
+	}
+	opts.Lookup = &buggyLookup
+
+	mismatches, err = fusetest.Replay(fuse.NewFuseSession("", &opts, 16), file)
+	if err != nil || len(mismatches) != 1 {
+		t.Fatalf("replay mismatches: %v, err: %+v \n", mismatches, err)
+	}
+	m := mismatches[0]
+	if m.Opcode != kernel.FuseOpLookup || m.Want.Errno != errno.ENOENT || m.Got.Errno != errno.EACCES {
+		t.Errorf("mismatch not correct: %s \n", m)
+	}
+}
+
+//TestRecordReplayLeftover : the request recorded without its reply is a mismatch
+func TestRecordReplayLeftover(t *testing.T) {
+
+	file, err := os.Open(recordSession(t))
+	if err != nil {
+		t.Fatalf("open recording err: %+v \n", err)
+	}
+	defer file.Close()
+
+	reader, err := record.NewReader(file)
+	if err != nil {
+		t.Fatalf("new reader err: %+v \n", err)
+	}
+	var recs []record.Record
+	for {
+		rec, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read record err: %+v \n", err)
+		}
+		recs = append(recs, rec)
+	}
+
+	// drop the reply of the last request, as the recording stopped before it
+	last := len(recs) - 1
+	for recs[last].Kind != record.KindReply {
+		last--
+	}
+	unique := recs[last].Unique()
+
+	buf := bytes.NewBuffer(nil)
+	w, _ := record.NewWriter(buf)
+	for i, rec := range recs {
+		if i != last {
+			w.WriteRecord(rec)
+		}
+	}
+
+	opts := recordOpts()
+	mismatches, err := fusetest.Replay(fuse.NewFuseSession("", &opts, 16), buf)
+	if err != nil || len(mismatches) != 1 {
+		t.Fatalf("replay mismatches: %v, err: %+v \n", mismatches, err)
+	}
+	m := mismatches[0]
+	if m.Unique != unique || m.Opcode != kernel.FuseOpRead || m.Err == nil {
+		t.Errorf("mismatch of the leftover not correct: %s \n", m)
+	}
+}
@@ -0,0 +1,232 @@
+package test
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+	"github.com/mingforpc/fuse-go/fuse/fusetest"
+	"github.com/mingforpc/fuse-go/fuse/kernel"
+)
+
+//TestReplyAsync : the requests answered later from other goroutines
+func TestReplyAsync(t *testing.T) {
+
+	replies := make(chan *fuse.Reply, 4)
+	errs := make(chan error, 4)
+
+	asyncGetattr := func(req fuse.Req, nodeid uint64) (*fuse.FileStat, int32) {
+		reply := req.Async()
+		if reply == nil {
+			return getattr(req, nodeid)
+		}
+		replies <- reply
+		return nil, fuse.ReplyLater
+	}
+
+	asyncLookup := func(req fuse.Req, parentId uint64, name string) (*fuse.FileStat, int32) {
+		reply := req.Async()
+		go func() {
+			stat, res := lookup(req, parentId, name)
+			if res != errno.SUCCESS {
+				errs <- reply.ReplyErr(res)
+				return
+			}
+			errs <- reply.ReplyEntry(stat)
+		}()
+		return nil, fuse.ReplyLater
+	}
+
+	opts := fuse.Opt{}
+	opts.Getattr = &asyncGetattr
+	opts.Lookup = &asyncLookup
+
+	k := newTestKernel(t, opts)
+	defer k.Close()
+
+	// the lookup is replied by another goroutine
+	entry, res, err := k.Lookup(fusetest.RootID, rootFile.name)
+	if err != nil || res != errno.SUCCESS || entry.NodeID != rootFile.stat.Nodeid || entry.AttrValid != 1 {
+		t.Errorf("lookup res: %d, entry: %+v, err: %+v \n", res, entry, err)
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("reply entry err: %+v \n", err)
+	}
+
+	_, res, err = k.Lookup(fusetest.RootID, "notexist")
+	if err != nil || res != errno.ENOENT {
+		t.Errorf("lookup notexist res should be ENOENT, not %d, err: %+v \n", res, err)
+	}
+	<-errs
+
+	// two getattr pending at the same time, replied in reverse order
+	first, _ := k.Go(kernel.FuseOpGetattr, rootFile.stat.Nodeid, kernel.FuseGetattrIn{})
+	second, _ := k.Go(kernel.FuseOpGetattr, rootDir.stat.Nodeid, kernel.FuseGetattrIn{})
+
+	replyA, replyB := <-replies, <-replies
+
+	var wg sync.WaitGroup
+	for _, reply := range []*fuse.Reply{replyB, replyA} {
+		wg.Add(1)
+		go func(reply *fuse.Reply) {
+			defer wg.Done()
+			if err := reply.ReplyAttr(&rootDir.stat); err != nil {
+				t.Errorf("reply attr err: %+v \n", err)
+			}
+		}(reply)
+	}
+	wg.Wait()
+
+	for _, p := range []*fusetest.Pending{first, second} {
+		reply, err := p.Wait()
+		out := kernel.FuseAttrOut{}
+		if err != nil || reply.Errno != errno.SUCCESS || reply.Decode(&out) != nil || out.Attr.Mode != rootDir.stat.Stat.Mode {
+			t.Errorf("getattr[%d] reply: %+v, err: %+v \n", p.Unique, reply, err)
+		}
+	}
+
+	// exactly one reply
+	if err := replyA.ReplyErr(errno.EIO); err != fuse.ErrReplied {
+		t.Errorf("second reply should be ErrReplied, not %+v \n", err)
+	}
+}
+
+//TestReplyMismatch : the reply not matching the opcode is EIO
+func TestReplyMismatch(t *testing.T) {
+
+	errs := make(chan error, 1)
+
+	wrongGetattr := func(req fuse.Req, nodeid uint64) (*fuse.FileStat, int32) {
+		errs <- req.Async().ReplyData([]byte("not attr"))
+		return nil, fuse.ReplyLater
+	}
+
+	// ReplyLater without Async
+	wrongLookup := func(req fuse.Req, parentId uint64, name string) (*fuse.FileStat, int32) {
+		return nil, fuse.ReplyLater
+	}
+
+	// Readdir can not be replied later
+	syncReaddir := func(req fuse.Req, nodeid uint64, size uint32, offset uint64, fi fuse.FileInfo) ([]fuse.Dirent, int32) {
+		if req.Async() != nil {
+			return nil, errno.EIO
+		}
+		return readdir(req, nodeid, size, offset, fi)
+	}
+
+	opts := fuse.Opt{}
+	opts.Getattr = &wrongGetattr
+	opts.Lookup = &wrongLookup
+	opts.Readdir = &syncReaddir
+
+	k := newTestKernel(t, opts)
+	defer k.Close()
+
+	_, res, err := k.Getattr(fusetest.RootID)
+	if err != nil || res != errno.EIO {
+		t.Errorf("getattr replied data should be EIO, not %d, err: %+v \n", res, err)
+	}
+	if err := <-errs; err != fuse.ErrReplyType {
+		t.Errorf("reply data to getattr should be ErrReplyType, not %+v \n", err)
+	}
+
+	_, res, err = k.Lookup(fusetest.RootID, rootFile.name)
+	if err != nil || res != errno.EIO {
+		t.Errorf("lookup ReplyLater without Async should be EIO, not %d, err: %+v \n", res, err)
+	}
+
+	_, res, err = k.Readdir(fusetest.RootID, 0, 0, 4096)
+	if err != nil || res != errno.SUCCESS {
+		t.Errorf("readdir res: %d, err: %+v \n", res, err)
+	}
+}
+
+//TestReplyInterrupt : the request replied later can be interrupted
+func TestReplyInterrupt(t *testing.T) {
+
+	started := make(chan uint64, 1)
+	slowRead := func(req fuse.Req, nodeid uint64, size uint32, offset uint64, fi fuse.FileInfo) ([]byte, int32) {
+		reply := req.Async()
+		go func() {
+			<-req.Interrupted()
+			reply.ReplyErr(errno.EINTR)
+		}()
+		started <- req.Unique
+		return nil, fuse.ReplyLater
+	}
+
+	opts := fuse.Opt{}
+	opts.Read = &slowRead
+
+	k := newTestKernel(t, opts)
+	defer k.Close()
+
+	pending, err := k.Go(kernel.FuseOpRead, rootFile.stat.Nodeid, kernel.FuseReadIn{Size: 16})
+	if err != nil {
+		t.Fatalf("read err: %+v \n", err)
+	}
+	<-started
+
+	if err := k.Interrupt(pending.Unique); err != nil {
+		t.Fatalf("interrupt err: %+v \n", err)
+	}
+
+	reply, err := pending.Wait()
+	if err != nil || reply.Errno != errno.EINTR {
+		t.Errorf("interrupted read should be EINTR, not %d, err: %+v \n", reply.Errno, err)
+	}
+}
+
+//TestReplyAsyncWrite : the buf of an async Write is kept until it is replied
+func TestReplyAsyncWrite(t *testing.T) {
+
+	type pendingWrite struct {
+		buf   []byte
+		reply *fuse.Reply
+	}
+	pendings := make(chan pendingWrite, 1)
+
+	asyncWrite := func(req fuse.Req, nodeid uint64, buf []byte, offset uint64, fi fuse.FileInfo) (uint32, int32) {
+		if offset != 0 {
+			return uint32(len(buf)), errno.SUCCESS
+		}
+		pendings <- pendingWrite{buf: buf, reply: req.Async()}
+		return 0, fuse.ReplyLater
+	}
+
+	opts := fuse.Opt{}
+	opts.Write = &asyncWrite
+
+	k := newTestKernel(t, opts)
+	defer k.Close()
+
+	first := bytes.Repeat([]byte("a"), 64)
+	in := kernel.FuseWriteIn{Size: uint32(len(first)), Buf: first}
+	pending, err := k.Go(kernel.FuseOpWrite, rootFile.stat.Nodeid, in)
+	if err != nil {
+		t.Fatalf("write err: %+v \n", err)
+	}
+	write := <-pendings
+
+	// the requests read meanwhile do not take the buffer of the pending one
+	for i := 0; i < 8; i++ {
+		_, res, err := k.Write(rootFile.stat.Nodeid, 0, 4096, bytes.Repeat([]byte("b"), 64))
+		if err != nil || res != errno.SUCCESS {
+			t.Fatalf("write res: %d, err: %+v \n", res, err)
+		}
+	}
+	if !bytes.Equal(write.buf, first) {
+		t.Errorf("buf of the pending write changed: %q \n", write.buf)
+	}
+
+	if err := write.reply.ReplyWrite(uint32(len(write.buf))); err != nil {
+		t.Errorf("reply write err: %+v \n", err)
+	}
+	reply, err := pending.Wait()
+	out := kernel.FuseWriteOut{}
+	if err != nil || reply.Errno != errno.SUCCESS || reply.Decode(&out) != nil || out.Size != uint32(len(first)) {
+		t.Errorf("async write reply: %+v, err: %+v \n", reply, err)
+	}
+}
@@ -0,0 +1,252 @@
+package test
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+	"github.com/mingforpc/fuse-go/fuse/fusetest"
+	"github.com/mingforpc/fuse-go/fuse/kernel"
+)
+
+// devPair : a socketpair of packets standing for "/dev/fuse", the session
+// serves one end by the dev transport, the test is the kernel on the other
+type devPair struct {
+	t      *testing.T
+	fd     int // the end of the session
+	kernel int // the end of the test
+	unique uint64
+}
+
+func newDevPair(t *testing.T) *devPair {
+
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_SEQPACKET|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatalf("socketpair err: %+v \n", err)
+	}
+
+	tv := syscall.NsecToTimeval(int64(10 * time.Second))
+	syscall.SetsockoptTimeval(fds[1], syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv)
+
+	return &devPair{t: t, fd: fds[0], kernel: fds[1]}
+}
+
+// call : send the request and read its reply
+func (dev *devPair) call(opcode uint32, nodeid uint64, in interface{}) fusetest.Reply {
+
+	dev.unique += 2
+
+	arg, err := fusetest.Encode(in)
+	if err != nil {
+		dev.t.Fatalf("encode err: %+v \n", err)
+	}
+	header, _ := fusetest.Encode(kernel.FuseInHeader{
+		Len:    uint32(kernel.InHeaderLen + len(arg)),
+		Opcode: opcode,
+		Unique: dev.unique,
+		Nodeid: nodeid,
+	})
+
+	_, err = syscall.Write(dev.kernel, append(header, arg...))
+	if err != nil {
+		dev.t.Fatalf("write request err: %+v \n", err)
+	}
+
+	buf := make([]byte, 1<<16)
+	n, err := syscall.Read(dev.kernel, buf)
+	if err != nil || n < kernel.OutHeaderLen {
+		dev.t.Fatalf("read reply n: %d, err: %+v \n", n, err)
+	}
+
+	reply := fusetest.Reply{
+		Errno:  int32(binary.LittleEndian.Uint32(buf[4:8])),
+		Unique: binary.LittleEndian.Uint64(buf[8:16]),
+		Data:   buf[kernel.OutHeaderLen:n],
+	}
+	if reply.Unique != dev.unique || binary.LittleEndian.Uint32(buf[0:4]) != uint32(n) {
+		dev.t.Fatalf("reply of unique[%d] not correct: %+v \n", dev.unique, reply)
+	}
+
+	return reply
+}
+
+// unixConnPair : the unix socket between the two processes of takeover
+func unixConnPair(t *testing.T) (*net.UnixConn, *net.UnixConn) {
+
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatalf("socketpair err: %+v \n", err)
+	}
+
+	var conns [2]*net.UnixConn
+	for i, fd := range fds {
+		file := os.NewFile(uintptr(fd), "takeover")
+		conn, err := net.FileConn(file)
+		file.Close()
+		if err != nil {
+			t.Fatalf("file conn err: %+v \n", err)
+		}
+		conns[i] = conn.(*net.UnixConn)
+	}
+
+	return conns[0], conns[1]
+}
+
+//TestTakeoverResume : hand the connection and the state over to a new session
+func TestTakeoverResume(t *testing.T) {
+
+	dev := newDevPair(t)
+	defer syscall.Close(dev.kernel)
+
+	initFs := func(conn *fuse.ConnInfo) interface{} {
+		return "filesystem state"
+	}
+	takeover := func(userdata interface{}) []byte {
+		return []byte(userdata.(string))
+	}
+	var resumed *fuse.ConnInfo
+	var resumedState string
+	resume := func(conn *fuse.ConnInfo, state []byte) interface{} {
+		resumed = conn
+		resumedState = string(state)
+		return resumedState
+	}
+
+	opts := fuse.Opt{}
+	opts.Init = &initFs
+	opts.Lookup = &lookup
+	opts.Takeover = &takeover
+	opts.Resume = &resume
+
+	old := fuse.NewFuseSession("", &opts, 16)
+	old.SetDev(dev.fd)
+
+	go old.FuseLoop()
+
+	reply := dev.call(kernel.FuseOpInit, 0, kernel.FuseInitIn{Major: 7, Minor: 31, MaxReadahead: 4096})
+	if reply.Errno != errno.SUCCESS {
+		t.Fatalf("init res: %d \n", reply.Errno)
+	}
+
+	gen := old.BumpGeneration(rootFile.stat.Nodeid)
+	old.SetUsage(1024, 3)
+
+	connA, connB := unixConnPair(t)
+	defer connA.Close()
+	defer connB.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- old.Takeover(connA)
+	}()
+
+	fd, state, err := fuse.ReceiveTakeover(connB)
+	if err != nil {
+		t.Fatalf("receive takeover err: %+v \n", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("takeover err: %+v \n", err)
+	}
+	if old.IsRunning() {
+		t.Errorf("session should not be running after takeover \n")
+	}
+
+	// the state after the JSON round trip
+	if state.ConnInfo.Major != 7 || state.ConnInfo.Minor != 31 || state.ConnInfo.MaxReadahead != 4096 {
+		t.Errorf("conn info not correct: %+v \n", state.ConnInfo)
+	}
+	if state.Generations[rootFile.stat.Nodeid] != gen || state.UsedBytes != 1024 || state.UsedInodes != 3 {
+		t.Errorf("state not correct: %+v \n", state)
+	}
+	if string(state.Data) != "filesystem state" {
+		t.Errorf("state data: %q \n", state.Data)
+	}
+
+	successor := fuse.NewFuseSession("", &opts, 16)
+	successor.Resume(fd, state)
+	defer successor.Close()
+
+	if resumed == nil || resumed.Minor != 31 || resumedState != "filesystem state" {
+		t.Fatalf("resume conn: %+v, state: %q \n", resumed, resumedState)
+	}
+	if used, inodes := successor.Usage(); used != 1024 || inodes != 3 {
+		t.Errorf("usage after resume: %d, %d \n", used, inodes)
+	}
+
+	go successor.FuseLoop()
+
+	// no INIT again, the successor serves the same connection
+	reply = dev.call(kernel.FuseOpLookup, fusetest.RootID, kernel.FuseLookupIn{Name: rootFile.name})
+	entry := kernel.FuseEntryOut{}
+	if reply.Errno != errno.SUCCESS || reply.Decode(&entry) != nil {
+		t.Fatalf("lookup after resume res: %d \n", reply.Errno)
+	}
+	if entry.NodeID != rootFile.stat.Nodeid || entry.Generation != gen {
+		t.Errorf("lookup after resume entry: %+v, generation should be %d \n", entry, gen)
+	}
+}
+
+//TestTakeoverClose : Close after takeover does not close the file reusing the fd of "/dev/fuse"
+func TestTakeoverClose(t *testing.T) {
+
+	dev := newDevPair(t)
+	defer syscall.Close(dev.kernel)
+
+	opts := fuse.Opt{}
+	opts.Lookup = &lookup
+
+	old := fuse.NewFuseSession("", &opts, 16)
+	old.SetDev(dev.fd)
+
+	go old.FuseLoop()
+
+	reply := dev.call(kernel.FuseOpInit, 0, kernel.FuseInitIn{Major: 7, Minor: 31})
+	if reply.Errno != errno.SUCCESS {
+		t.Fatalf("init res: %d \n", reply.Errno)
+	}
+
+	connA, connB := unixConnPair(t)
+	defer connA.Close()
+	defer connB.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- old.Takeover(connA)
+	}()
+
+	fd, _, err := fuse.ReceiveTakeover(connB)
+	if err != nil {
+		t.Fatalf("receive takeover err: %+v \n", err)
+	}
+	defer syscall.Close(fd)
+	if err := <-done; err != nil {
+		t.Fatalf("takeover err: %+v \n", err)
+	}
+
+	// the fd of the old session is free, the pipe takes it
+	var pipe [2]int
+	if err := syscall.Pipe(pipe[:]); err != nil {
+		t.Fatalf("pipe err: %+v \n", err)
+	}
+	defer syscall.Close(pipe[0])
+	defer syscall.Close(pipe[1])
+	if pipe[0] != dev.fd && pipe[1] != dev.fd {
+		t.Logf("fd[%d] of the old session not reused by the pipe %v \n", dev.fd, pipe)
+	}
+
+	old.Close()
+	old.Close()
+
+	if _, err := syscall.Write(pipe[1], []byte("x")); err != nil {
+		t.Errorf("pipe closed by Close after takeover: %+v \n", err)
+	}
+	buf := make([]byte, 1)
+	if _, err := syscall.Read(pipe[0], buf); err != nil {
+		t.Errorf("pipe closed by Close after takeover: %+v \n", err)
+	}
+}
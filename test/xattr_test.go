@@ -1,11 +1,15 @@
 package test
 
 import (
+	"bytes"
+	"sort"
 	"strings"
 	"syscall"
 	"testing"
 
 	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+	"github.com/mingforpc/fuse-go/fuse/xattr"
 )
 
 func TestSetGetxattr(t *testing.T) {
@@ -18,8 +22,8 @@ func TestSetGetxattr(t *testing.T) {
 	opts := fuse.Opt{}
 	opts.Getattr = &getattr
 	opts.Lookup = &lookup
-	opts.Setxattr = &setxattr
-	opts.Getxattr = &getxattr
+	opts.SetxattrBytes = &setxattr
+	opts.GetxattrBytes = &getxattr
 
 	se := NewTestFuse(tempPoint, opts)
 
@@ -47,8 +51,7 @@ func TestSetGetxattr(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to getxattr: %+v \n", err)
 	}
-	// n-1 to avoid ‘\0'
-	content := string(buf[:n-1])
+	content := string(buf[:n])
 
 	if content != "test" {
 		t.Fatalf("xattr value shoud be %s \n", "test")
@@ -65,8 +68,8 @@ func TestListxattr(t *testing.T) {
 	opts := fuse.Opt{}
 	opts.Getattr = &getattr
 	opts.Lookup = &lookup
-	opts.Setxattr = &setxattr
-	opts.Listxattr = &listxattr
+	opts.SetxattrBytes = &setxattr
+	opts.ListxattrNames = &listxattr
 
 	se := NewTestFuse(tempPoint, opts)
 
@@ -120,8 +123,8 @@ func TestRemovexattr(t *testing.T) {
 	opts.Getattr = &getattr
 	opts.Lookup = &lookup
 	opts.Readdir = &readdir
-	opts.Setxattr = &setxattr
-	opts.Getxattr = &getxattr
+	opts.SetxattrBytes = &setxattr
+	opts.GetxattrBytes = &getxattr
 	opts.Removexattr = &removexattr
 
 	se := NewTestFuse(tempPoint, opts)
@@ -150,8 +153,7 @@ func TestRemovexattr(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to getxattr: %+v \n", err)
 	}
-	// n-1 to avoid ‘\0'
-	content := string(buf[:n-1])
+	content := string(buf[:n])
 
 	if content != "test" {
 		t.Errorf("xattr value shoud be %s \n", "test")
@@ -169,3 +171,167 @@ func TestRemovexattr(t *testing.T) {
 		t.Fatalf("Failed to getxattr: %+v \n", err)
 	}
 }
+
+//TestXattrBinary : test binary value of xattr, the size probe and ERANGE
+func TestXattrBinary(t *testing.T) {
+	tempPoint, err := createTempPoint()
+
+	if err != nil {
+		t.Fatalf("TestXattrBinary err: %+v \n", err)
+	}
+
+	opts := fuse.Opt{}
+	opts.Getattr = &getattr
+	opts.Lookup = &lookup
+	opts.SetxattrBytes = &setxattr
+	opts.GetxattrBytes = &getxattr
+	opts.ListxattrNames = &listxattr
+
+	se := NewTestFuse(tempPoint, opts)
+
+	err = preTest(se)
+
+	if err != nil {
+		t.Fatalf("TestXattrBinary err: %+v \n", err)
+	}
+
+	go se.FuseLoop()
+	defer exitTest(se)
+
+	wait.Wait()
+
+	path := tempPoint + "/" + rootFile.path
+
+	value := make([]byte, 300)
+	for i := range value {
+		value[i] = byte(i)
+	}
+
+	err = syscall.Setxattr(path, "user.binary", value, 0)
+	if err != nil {
+		t.Fatalf("Failed to setxattr: %+v \n", err)
+	}
+
+	// the size probe
+	n, err := syscall.Getxattr(path, "user.binary", nil)
+	if err != nil {
+		t.Fatalf("Failed to getxattr size: %+v \n", err)
+	}
+	if n != len(value) {
+		t.Errorf("xattr size should be %d, not %d \n", len(value), n)
+	}
+
+	buf := make([]byte, n)
+	n, err = syscall.Getxattr(path, "user.binary", buf)
+	if err != nil {
+		t.Fatalf("Failed to getxattr: %+v \n", err)
+	}
+	if !bytes.Equal(buf[:n], value) {
+		t.Errorf("xattr value should be %x, not %x \n", value, buf[:n])
+	}
+
+	// the buffer is too small
+	_, err = syscall.Getxattr(path, "user.binary", make([]byte, 16))
+	if err != syscall.ERANGE {
+		t.Errorf("getxattr with small buffer should be ERANGE, not %+v \n", err)
+	}
+
+	// the empty value
+	err = syscall.Setxattr(path, "user.empty", []byte{}, 0)
+	if err != nil {
+		t.Fatalf("Failed to setxattr: %+v \n", err)
+	}
+	n, err = syscall.Getxattr(path, "user.empty", buf)
+	if err != nil || n != 0 {
+		t.Errorf("empty xattr should be read with size 0, not %d, err: %+v \n", n, err)
+	}
+
+	// listxattr
+	n, err = syscall.Listxattr(path, nil)
+	if err != nil {
+		t.Fatalf("Failed to listxattr size: %+v \n", err)
+	}
+	if n != len("user.binary\x00user.empty\x00") {
+		t.Errorf("listxattr size should be %d, not %d \n", len("user.binary\x00user.empty\x00"), n)
+	}
+
+	_, err = syscall.Listxattr(path, make([]byte, 4))
+	if err != syscall.ERANGE {
+		t.Errorf("listxattr with small buffer should be ERANGE, not %+v \n", err)
+	}
+}
+
+//TestXattrRouter : test the xattr router by namespace and name
+func TestXattrRouter(t *testing.T) {
+
+	user := make(map[string][]byte)
+
+	r := xattr.NewRouter()
+	r.HandleNamespace(xattr.NamespaceUser, &xattr.Handler{
+		Get: func(req fuse.Req, nodeid uint64, name string) ([]byte, int32) {
+			value, ok := user[name]
+			if !ok {
+				return nil, errno.ENODATA
+			}
+			return value, errno.SUCCESS
+		},
+		Set: func(req fuse.Req, nodeid uint64, name string, value []byte, flags uint32) int32 {
+			user[name] = value
+			return errno.SUCCESS
+		},
+		List: func(req fuse.Req, nodeid uint64) ([]string, int32) {
+			var names []string
+			for name := range user {
+				names = append(names, name)
+			}
+			return names, errno.SUCCESS
+		},
+	})
+	// read-only system attribute
+	r.HandleName("system.version", &xattr.Handler{
+		Get: func(req fuse.Req, nodeid uint64, name string) ([]byte, int32) {
+			return []byte("1"), errno.SUCCESS
+		},
+	})
+
+	opts := fuse.Opt{}
+	xattr.Install(&opts, r)
+
+	req := fuse.Req{}
+
+	if res := (*opts.SetxattrBytes)(req, 1, "user.a", []byte("a"), fuse.XattrReplace); res != errno.ENODATA {
+		t.Errorf("replace not exist xattr should be ENODATA, not %d \n", res)
+	}
+	if res := (*opts.SetxattrBytes)(req, 1, "user.a", []byte("a"), fuse.XattrCreate); res != errno.SUCCESS {
+		t.Errorf("create xattr should be SUCCESS, not %d \n", res)
+	}
+	if res := (*opts.SetxattrBytes)(req, 1, "user.a", []byte("b"), fuse.XattrCreate); res != errno.EEXIST {
+		t.Errorf("create exist xattr should be EEXIST, not %d \n", res)
+	}
+	if res := (*opts.SetxattrBytes)(req, 1, "system.version", []byte("2"), 0); res != errno.ENOTSUP {
+		t.Errorf("set read-only xattr should be ENOTSUP, not %d \n", res)
+	}
+	if res := (*opts.SetxattrBytes)(req, 1, "trusted.a", []byte("a"), 0); res != errno.ENOTSUP {
+		t.Errorf("set xattr without handler should be ENOTSUP, not %d \n", res)
+	}
+
+	value, res := (*opts.GetxattrBytes)(req, 1, "system.version", 0)
+	if res != errno.SUCCESS || string(value) != "1" {
+		t.Errorf("system.version should be 1, not %s, res %d \n", value, res)
+	}
+	if _, res = (*opts.GetxattrBytes)(req, 1, "user.b", 0); res != errno.ENODATA {
+		t.Errorf("get not exist xattr should be ENODATA, not %d \n", res)
+	}
+	if res = (*opts.Removexattr)(req, 1, "user.a"); res != errno.ENOTSUP {
+		t.Errorf("remove without Remove should be ENOTSUP, not %d \n", res)
+	}
+
+	names, res := (*opts.ListxattrNames)(req, 1, 0)
+	if res != errno.SUCCESS {
+		t.Fatalf("listxattr err: %d \n", res)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "system.version,user.a" {
+		t.Errorf("listxattr should be [system.version user.a], not %+v \n", names)
+	}
+}